@@ -1,22 +1,36 @@
 package account
 
 import (
+	"atlas-account/ban"
+	"atlas-account/database"
+	"atlas-account/login"
+	"atlas-account/wallet"
+	"errors"
 	tenant "github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
 type EntityUpdateFunction func() ([]string, func(e *Entity))
 
-func create(db *gorm.DB) func(tenant tenant.Model, name string, password string, gender byte) (Model, error) {
-	return func(tenant tenant.Model, name string, password string, gender byte) (Model, error) {
+func create(db *gorm.DB) func(tenant tenant.Model, name string, password string, gender byte, modifiers ...EntityUpdateFunction) (Model, error) {
+	return func(tenant tenant.Model, name string, password string, gender byte, modifiers ...EntityUpdateFunction) (Model, error) {
 		a := &Entity{
 			TenantId: tenant.Id(),
 			Name:     name,
 			Password: password,
 			Gender:   gender,
 		}
+		for _, modifier := range modifiers {
+			_, u := modifier()
+			u(a)
+		}
 
 		err := db.Create(a).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return Model{}, ErrAlreadyExists
+		}
 		if err != nil {
 			return Model{}, err
 		}
@@ -40,6 +54,40 @@ func update(db *gorm.DB) func(modifiers ...EntityUpdateFunction) IdOperator {
 	}
 }
 
+func updatePassword(password string) EntityUpdateFunction {
+	return func() ([]string, func(e *Entity)) {
+		var cs = []string{"password"}
+
+		uf := func(e *Entity) {
+			e.Password = password
+		}
+		return cs, uf
+	}
+}
+
+// updateCredential replaces the password of an account, and bumps the credential version so that anything issued
+// against the previous password may be recognized as stale.
+func updateCredential(db *gorm.DB) func(tenant tenant.Model, id uint32, password string) error {
+	return func(tenant tenant.Model, id uint32, password string) error {
+		return db.Model(&Entity{TenantId: tenant.Id(), ID: id}).Updates(map[string]interface{}{
+			"password":           password,
+			"credential_version": gorm.Expr("credential_version + 1"),
+		}).Error
+	}
+}
+
+func updateLastLogin(at time.Time, ipAddress string) EntityUpdateFunction {
+	return func() ([]string, func(e *Entity)) {
+		var cs = []string{"last_login", "last_login_ip"}
+
+		uf := func(e *Entity) {
+			e.LastLogin = at.UnixMilli()
+			e.LastLoginIP = ipAddress
+		}
+		return cs, uf
+	}
+}
+
 func updatePic(pic string) EntityUpdateFunction {
 	return func() ([]string, func(e *Entity)) {
 		var cs = []string{"pic"}
@@ -84,18 +132,265 @@ func updateGender(gender byte) EntityUpdateFunction {
 	}
 }
 
+func updateLanguage(language string) EntityUpdateFunction {
+	return func() ([]string, func(e *Entity)) {
+		var cs = []string{"language"}
+
+		uf := func(e *Entity) {
+			e.Language = language
+		}
+		return cs, uf
+	}
+}
+
+func updateCountry(country string) EntityUpdateFunction {
+	return func() ([]string, func(e *Entity)) {
+		var cs = []string{"country"}
+
+		uf := func(e *Entity) {
+			e.Country = country
+		}
+		return cs, uf
+	}
+}
+
+func updateCharacterSlots(characterSlots int16) EntityUpdateFunction {
+	return func() ([]string, func(e *Entity)) {
+		var cs = []string{"character_slots"}
+
+		uf := func(e *Entity) {
+			e.CharacterSlots = characterSlots
+		}
+		return cs, uf
+	}
+}
+
+// grantCharacterSlots adds character slots to an account, up to the maximum given. Should the idempotency key have been
+// applied already, the original grant is returned as replayed and nothing further is changed.
+func grantCharacterSlots(db *gorm.DB) func(tenant tenant.Model, accountId uint32, amount int16, defaultSlots int16, maximum int16, idempotencyKey string) (CharacterSlotGrantEntity, bool, error) {
+	return func(tenant tenant.Model, accountId uint32, amount int16, defaultSlots int16, maximum int16, idempotencyKey string) (CharacterSlotGrantEntity, bool, error) {
+		var result CharacterSlotGrantEntity
+		var replayed bool
+		err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+			if idempotencyKey != "" {
+				existing, err := grantByIdempotencyKey(tenant, idempotencyKey)(tx)()
+				if err == nil {
+					if existing.AccountId != accountId {
+						return ErrIdempotencyConflict
+					}
+					result = existing
+					replayed = true
+					return nil
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			}
+
+			e, err := entityById(tenant, accountId)(tx)()
+			if err != nil {
+				return err
+			}
+			previous := e.CharacterSlots
+			if previous <= 0 {
+				previous = defaultSlots
+			}
+			if int(previous)+int(amount) > int(maximum) {
+				return ErrCharacterSlotLimit
+			}
+
+			// Guard against a concurrent change to the slots read.
+			res := tx.Model(&Entity{}).Where("tenant_id = ? AND id = ? AND character_slots = ?", tenant.Id(), accountId, e.CharacterSlots).Update("character_slots", previous+amount)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrConcurrentModification
+			}
+
+			result = CharacterSlotGrantEntity{
+				TenantId:       tenant.Id(),
+				AccountId:      accountId,
+				Amount:         amount,
+				Previous:       previous,
+				CharacterSlots: previous + amount,
+			}
+			if idempotencyKey != "" {
+				result.IdempotencyKey = &idempotencyKey
+			}
+			return tx.Create(&result).Error
+		})
+		if err != nil {
+			return replayGrant(db)(tenant, accountId, idempotencyKey, err)
+		}
+		return result, replayed, nil
+	}
+}
+
+// replayGrant recovers from a grant which failed as a concurrent grant under the same idempotency key won the race,
+// either raising the slots first or recording its grant first. The grant which won is returned as replayed. Any other
+// failure is returned as is.
+func replayGrant(db *gorm.DB) func(tenant tenant.Model, accountId uint32, idempotencyKey string, cause error) (CharacterSlotGrantEntity, bool, error) {
+	return func(tenant tenant.Model, accountId uint32, idempotencyKey string, cause error) (CharacterSlotGrantEntity, bool, error) {
+		if idempotencyKey == "" || !(errors.Is(cause, gorm.ErrDuplicatedKey) || errors.Is(cause, ErrConcurrentModification)) {
+			return CharacterSlotGrantEntity{}, false, cause
+		}
+		existing, err := grantByIdempotencyKey(tenant, idempotencyKey)(db)()
+		if err != nil {
+			return CharacterSlotGrantEntity{}, false, cause
+		}
+		if existing.AccountId != accountId {
+			return CharacterSlotGrantEntity{}, false, ErrIdempotencyConflict
+		}
+		return existing, true, nil
+	}
+}
+
+// softDelete tombstones an account. The row is retained, but no longer visible to ordinary queries.
+func softDelete(db *gorm.DB) func(tenant tenant.Model, accountId uint32) error {
+	return func(tenant tenant.Model, accountId uint32) error {
+		res := db.Where("tenant_id = ? AND id = ?", tenant.Id(), accountId).Delete(&Entity{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+}
+
+func restore(db *gorm.DB) func(tenant tenant.Model, accountId uint32) error {
+	return func(tenant tenant.Model, accountId uint32) error {
+		res := db.Unscoped().Model(&Entity{}).Where("tenant_id = ? AND id = ? AND deleted_at IS NOT NULL", tenant.Id(), accountId).Update("deleted_at", nil)
+		if errors.Is(res.Error, gorm.ErrDuplicatedKey) {
+			return ErrNameTaken
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+}
+
+// purge permanently removes a deleted account, along with its character slot grants, privilege audit trail, login
+// history, account bans and wallet balances. The wallet ledger is retained as a financial record.
+func purge(db *gorm.DB) func(tenantId uuid.UUID, accountId uint32) error {
+	return func(tenantId uuid.UUID, accountId uint32) error {
+		return database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+			res := tx.Unscoped().Where("tenant_id = ? AND id = ? AND deleted_at IS NOT NULL", tenantId, accountId).Delete(&Entity{})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			err := tx.Where("tenant_id = ? AND account_id = ?", tenantId, accountId).Delete(&CharacterSlotGrantEntity{}).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Where("tenant_id = ? AND account_id = ?", tenantId, accountId).Delete(&PrivilegeChangeEntity{}).Error
+			if err != nil {
+				return err
+			}
+			err = login.PurgeByAccountId(tx)(tenantId, accountId)
+			if err != nil {
+				return err
+			}
+			err = ban.PurgeByAccountId(tx)(tenantId, accountId)
+			if err != nil {
+				return err
+			}
+			return wallet.PurgeByAccountId(tx)(tenantId, accountId)
+		})
+	}
+}
+
+// setPrivileges changes the GM level and roles of an account, recording the change for audit. Nothing is recorded, and
+// changed is unset, when the account already holds exactly the privileges requested.
+func setPrivileges(db *gorm.DB) func(tenant tenant.Model, accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (PrivilegeChangeEntity, bool, error) {
+	return func(tenant tenant.Model, accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (PrivilegeChangeEntity, bool, error) {
+		var result PrivilegeChangeEntity
+		var changed bool
+		err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+			e, err := entityById(tenant, accountId)(tx)()
+			if err != nil {
+				return err
+			}
+			joined := joinRoles(roles)
+			if e.GMLevel == gmLevel && e.Roles == joined {
+				return nil
+			}
+
+			res := tx.Model(&Entity{}).Where("tenant_id = ? AND id = ? AND gm_level = ? AND roles = ?", tenant.Id(), accountId, e.GMLevel, e.Roles).Updates(map[string]interface{}{"gm_level": gmLevel, "roles": joined})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrConcurrentModification
+			}
+
+			result = PrivilegeChangeEntity{
+				TenantId:        tenant.Id(),
+				AccountId:       accountId,
+				PreviousGMLevel: e.GMLevel,
+				GMLevel:         gmLevel,
+				PreviousRoles:   e.Roles,
+				Roles:           joined,
+				Issuer:          issuer,
+				Reason:          reason,
+			}
+			changed = true
+			return tx.Create(&result).Error
+		})
+		if err != nil {
+			return PrivilegeChangeEntity{}, false, err
+		}
+		return result, changed, nil
+	}
+}
+
 func Make(a Entity) (Model, error) {
 	r := Model{
-		tenantId:  a.TenantId,
-		id:        a.ID,
-		name:      a.Name,
-		password:  a.Password,
-		pin:       a.PIN,
-		pic:       a.PIC,
-		gender:    a.Gender,
-		banned:    false,
-		tos:       a.TOS,
-		updatedAt: a.UpdatedAt,
+		tenantId:          a.TenantId,
+		id:                a.ID,
+		name:              a.Name,
+		password:          a.Password,
+		credentialVersion: a.CredentialVersion,
+		pin:               a.PIN,
+		pic:               a.PIC,
+		gender:            a.Gender,
+		banned:            false,
+		tos:               a.TOS,
+		lastLogin:         a.LastLogin,
+		lastLoginIP:       a.LastLoginIP,
+		language:          a.Language,
+		country:           a.Country,
+		characterSlots:    a.CharacterSlots,
+		gmLevel:           a.GMLevel,
+		roles:             splitRoles(a.Roles),
+		updatedAt:         a.UpdatedAt,
+	}
+	if a.DeletedAt.Valid {
+		r.deletedAt = a.DeletedAt.Time
 	}
 	return r, nil
 }
+
+func MakePrivilegeChange(e PrivilegeChangeEntity) (PrivilegeChange, error) {
+	return PrivilegeChange{
+		tenantId:        e.TenantId,
+		id:              e.ID,
+		accountId:       e.AccountId,
+		previousGMLevel: e.PreviousGMLevel,
+		gmLevel:         e.GMLevel,
+		previousRoles:   splitRoles(e.PreviousRoles),
+		roles:           splitRoles(e.Roles),
+		issuer:          e.Issuer,
+		reason:          e.Reason,
+		createdAt:       e.CreatedAt,
+	}, nil
+}
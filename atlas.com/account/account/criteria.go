@@ -0,0 +1,116 @@
+package account
+
+import (
+	"atlas-account/ban"
+	"github.com/Chronicle20/atlas-tenant"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	SortId        = "id"
+	SortName      = "name"
+	SortCreatedAt = "createdAt"
+	SortLastLogin = "lastLogin"
+	SortGMLevel   = "gmLevel"
+)
+
+var sortColumns = map[string]string{
+	SortId:        "id",
+	SortName:      "LOWER(name)",
+	SortCreatedAt: "created_at",
+	SortLastLogin: "last_login",
+	SortGMLevel:   "gm_level",
+}
+
+// Criteria narrows and orders a listing of the accounts of a tenant. Unset fields do not narrow the listing.
+type Criteria struct {
+	NamePrefix      string // Matched ignoring case.
+	LoggedIn        *bool
+	Banned          *bool
+	GMLevel         *byte
+	MinGMLevel      *byte
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	LastLoginAfter  *time.Time
+	LastLoginBefore *time.Time
+	Sort            []Sort // Ties, and an empty sort, are ordered by id.
+}
+
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// ValidSort reports whether accounts may be ordered by the field.
+func ValidSort(field string) bool {
+	_, ok := sortColumns[field]
+	return ok
+}
+
+// criteriaScope restricts a query of the accounts of the tenant to those meeting the criteria. Whether an account is
+// logged in is known only to the registry, so it restricts the query to the accounts which are, or are not.
+func criteriaScope(tenant tenant.Model, c Criteria, r Registry, now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("tenant_id = ?", tenant.Id())
+		if c.NamePrefix != "" {
+			db = db.Where("LOWER(name) LIKE ? ESCAPE '\\'", escapeLike(strings.ToLower(c.NamePrefix))+"%")
+		}
+		if c.LoggedIn != nil {
+			db = r.LoggedInScope(tenant, *c.LoggedIn)(db)
+		}
+		if c.Banned != nil {
+			active := "EXISTS (SELECT 1 FROM bans WHERE bans.tenant_id = accounts.tenant_id AND bans.account_id = accounts.id AND bans.type = ? AND bans.starts_at <= ? AND (bans.revoked_at IS NULL OR bans.revoked_at > ?) AND (bans.expires_at IS NULL OR bans.expires_at > ?))"
+			if !*c.Banned {
+				active = "NOT " + active
+			}
+			db = db.Where(active, ban.TypeAccount, now, now, now)
+		}
+		if c.GMLevel != nil {
+			db = db.Where("gm_level = ?", *c.GMLevel)
+		}
+		if c.MinGMLevel != nil {
+			db = db.Where("gm_level >= ?", *c.MinGMLevel)
+		}
+		if c.CreatedAfter != nil {
+			db = db.Where("created_at >= ?", *c.CreatedAfter)
+		}
+		if c.CreatedBefore != nil {
+			db = db.Where("created_at < ?", *c.CreatedBefore)
+		}
+		if c.LastLoginAfter != nil {
+			db = db.Where("last_login >= ?", c.LastLoginAfter.UnixMilli())
+		}
+		if c.LastLoginBefore != nil {
+			db = db.Where("last_login < ?", c.LastLoginBefore.UnixMilli())
+		}
+		return db
+	}
+}
+
+// sortScope orders a query of accounts by the criteria, then by id.
+func sortScope(c Criteria) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		byId := false
+		for _, s := range c.Sort {
+			column, ok := sortColumns[s.Field]
+			if !ok {
+				continue
+			}
+			byId = byId || s.Field == SortId
+			if s.Descending {
+				column += " desc"
+			}
+			db = db.Order(column)
+		}
+		if !byId {
+			db = db.Order("id")
+		}
+		return db
+	}
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("\\", "\\\\", "%", "\\%", "_", "\\_").Replace(value)
+}
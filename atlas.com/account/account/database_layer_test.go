@@ -1,22 +1,32 @@
 package account
 
 import (
+	"atlas-account/ban"
+	"atlas-account/login"
+	"atlas-account/wallet"
+	"errors"
 	"github.com/Chronicle20/atlas-tenant"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"testing"
 )
 
 func setupTestDatabase(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
-	err = db.AutoMigrate(Entity{})
+	err = db.AutoMigrate(Entity{}, CharacterSlotGrantEntity{}, PrivilegeChangeEntity{}, SessionEntity{}, SessionVersionEntity{}, SessionSnapshotEntity{}, ban.Entity{}, login.Entity{}, wallet.Entity{}, wallet.TransactionEntity{})
 	if err != nil {
 		t.Fatalf("Failed to auto migrate: %v", err)
 	}
+	err = migrateUniqueNames(db)
+	if err != nil {
+		t.Fatalf("Failed to migrate unique names: %v", err)
+	}
 	return db
 }
 
@@ -148,3 +158,114 @@ func TestInternalGetByName(t *testing.T) {
 		t.Fatalf("Password mismatch. Expected %v, got %v", testName, r.Password())
 	}
 }
+
+func TestInternalCreateDuplicateName(t *testing.T) {
+	db := setupTestDatabase(t)
+
+	st := sampleTenant()
+	_, err := create(db)(st, "Name", "password", 0)
+	if err != nil {
+		t.Fatalf("Failed to create account: %v", err)
+	}
+	_, err = create(db)(st, "nAME", "password", 0)
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected already exists, got %v", err)
+	}
+
+	ot, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	_, err = create(db)(ot, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Name should be available in another tenant: %v", err)
+	}
+
+	re, err := entitiesByName(st, "NAME")(db)()
+	if err != nil || len(re) != 1 || re[0].Name != "Name" {
+		t.Fatalf("Name lookup should ignore case.")
+	}
+}
+
+func TestInternalMigrateDuplicateNames(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	err = db.AutoMigrate(Entity{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+
+	st := sampleTenant()
+	for _, name := range []string{"name", "NAME", "other"} {
+		_, err = create(db)(st, name, "password", 0)
+		if err != nil {
+			t.Fatalf("Failed to create account: %v", err)
+		}
+	}
+
+	err = migrateUniqueNames(db)
+	var de DuplicateNamesError
+	if !errors.As(err, &de) {
+		t.Fatalf("Expected duplicate names to be reported, got %v", err)
+	}
+	if len(de.Duplicates) != 1 || de.Duplicates[0].Name != "name" || de.Duplicates[0].Count != 2 || de.Duplicates[0].TenantId != st.Id() {
+		t.Fatalf("Duplicate names not reported as expected: %v", de.Duplicates)
+	}
+
+	l, hook := test.NewNullLogger()
+	err = Migration(l)(db)
+	if err != nil {
+		t.Fatalf("Duplicate names should not fail the migration: %v", err)
+	}
+	if len(hook.Entries) != 1 || hook.LastEntry().Level != logrus.WarnLevel {
+		t.Fatalf("Duplicate names should be logged.")
+	}
+	_, err = create(db)(st, "Other", "password", 0)
+	if err != nil {
+		t.Fatalf("Names should not be made unique while duplicated: %v", err)
+	}
+	db.Where("name = ?", "Other").Delete(&Entity{})
+
+	db.Where("name = ?", "NAME").Delete(&Entity{})
+	err = migrateUniqueNames(db)
+	if err != nil {
+		t.Fatalf("Deleted accounts should not count as duplicates: %v", err)
+	}
+}
+
+func TestInternalReplayGrant(t *testing.T) {
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	o, err := create(db)(st, "other", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	// A concurrent grant under the same key, which committed first.
+	won, _, err := grantCharacterSlots(db)(st, a.Id(), 2, 4, 15, "purchase-1")
+	if err != nil {
+		t.Fatalf("Unable to grant character slots: %v", err)
+	}
+
+	for _, cause := range []error{gorm.ErrDuplicatedKey, ErrConcurrentModification} {
+		g, replayed, err := replayGrant(db)(st, a.Id(), "purchase-1", cause)
+		if err != nil {
+			t.Fatalf("Grant losing the race with [%v] should be replayed, got %v", cause, err)
+		}
+		if !replayed || g.ID != won.ID {
+			t.Fatalf("Expected grant [%d] to be replayed.", won.ID)
+		}
+	}
+	if _, _, err = replayGrant(db)(st, o.Id(), "purchase-1", gorm.ErrDuplicatedKey); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("Expected idempotency conflict, got %v", err)
+	}
+	if _, _, err = replayGrant(db)(st, a.Id(), "purchase-2", ErrConcurrentModification); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("Concurrent modification without a grant to replay should be returned, got %v", err)
+	}
+	if _, _, err = replayGrant(db)(st, a.Id(), "purchase-1", ErrCharacterSlotLimit); !errors.Is(err, ErrCharacterSlotLimit) {
+		t.Fatalf("Other failures should be returned, got %v", err)
+	}
+}
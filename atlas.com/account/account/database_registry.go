@@ -0,0 +1,321 @@
+package account
+
+import (
+	"errors"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// maxRegistryAttempts bounds how often a change is decided again after losing a race with another replica.
+const maxRegistryAttempts = 5
+
+// DatabaseRegistry holds sessions in a database table, so replicas of the service share them and they survive restarts.
+// A change to the sessions of an account is written only if the version of the sessions it was decided from is still
+// current, and is otherwise decided again from the sessions now held.
+type DatabaseRegistry struct {
+	l  logrus.FieldLogger
+	db *gorm.DB
+}
+
+func NewDatabaseRegistry(l logrus.FieldLogger, db *gorm.DB) *DatabaseRegistry {
+	return &DatabaseRegistry{l: l, db: db}
+}
+
+func (r *DatabaseRegistry) GetStates(key AccountKey) map[ServiceKey]StateValue {
+	_, states, err := r.read(key)
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to read sessions of account [%d].", key.AccountId)
+		return map[ServiceKey]StateValue{}
+	}
+	return states
+}
+
+func (r *DatabaseRegistry) MaximalState(key AccountKey) State {
+	return maximalState(GetServices(), r.GetStates(key))
+}
+
+func (r *DatabaseRegistry) IsLoggedIn(key AccountKey) bool {
+	return r.MaximalState(key) > 0
+}
+
+func (r *DatabaseRegistry) Login(key AccountKey, sk ServiceKey, instanceId uuid.UUID) error {
+	return r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		return true, applyLogin(GetServices(), states, sk, instanceId, time.Now())
+	})
+}
+
+func (r *DatabaseRegistry) Transition(key AccountKey, sk ServiceKey) error {
+	return r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		return true, applyTransition(GetServices(), states, sk, time.Now())
+	})
+}
+
+func (r *DatabaseRegistry) ExpireTransition(key AccountKey, timeouts map[Service]time.Duration) {
+	err := r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		return applyExpireTransition(states, timeouts, time.Now()), nil
+	})
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to expire transition of account [%d].", key.AccountId)
+	}
+}
+
+func (r *DatabaseRegistry) Heartbeat(key AccountKey, sk ServiceKey) error {
+	return r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		return true, applyHeartbeat(states, sk, time.Now())
+	})
+}
+
+func (r *DatabaseRegistry) ExpireIdle(key AccountKey, timeouts map[Service]time.Duration) bool {
+	var result bool
+	err := r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		var expired bool
+		expired, result = applyExpireIdle(GetServices(), states, timeouts, time.Now())
+		return expired, nil
+	})
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to expire idle sessions of account [%d].", key.AccountId)
+		return false
+	}
+	return result
+}
+
+func (r *DatabaseRegistry) Logout(key AccountKey, sk ServiceKey) bool {
+	var result bool
+	err := r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		_, ok := states[sk]
+		result = applyLogout(states, sk)
+		return result && ok, nil
+	})
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to log out session [%s] of account [%d].", sk.SessionId.String(), key.AccountId)
+		return false
+	}
+	return result
+}
+
+func (r *DatabaseRegistry) Terminate(key AccountKey) bool {
+	err := r.modify(key, func(states map[ServiceKey]StateValue) (bool, error) {
+		if len(states) == 0 {
+			return false, nil
+		}
+		clear(states)
+		return true, nil
+	})
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to terminate sessions of account [%d].", key.AccountId)
+		return false
+	}
+	return true
+}
+
+func (r *DatabaseRegistry) TerminateInstance(t tenant.Model, instanceId uuid.UUID) []uint32 {
+	var candidates []uint32
+	err := r.db.Model(&SessionEntity{}).Where("tenant_id = ? AND instance_id = ? AND state <> ?", t.Id(), instanceId, StateTransition).Distinct().Pluck("account_id", &candidates).Error
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to read sessions of instance [%s].", instanceId.String())
+		return make([]uint32, 0)
+	}
+
+	var ids = make([]uint32, 0)
+	for _, id := range candidates {
+		var loggedOut bool
+		err = r.modify(AccountKey{Tenant: t, AccountId: id}, func(states map[ServiceKey]StateValue) (bool, error) {
+			var terminated bool
+			terminated, loggedOut = applyTerminateInstance(GetServices(), states, instanceId)
+			return terminated, nil
+		})
+		if err != nil {
+			r.l.WithError(err).Errorf("Unable to terminate sessions of account [%d] held by instance [%s].", id, instanceId.String())
+			continue
+		}
+		if loggedOut {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (r *DatabaseRegistry) GetExpiredInTransition(timeouts map[Service]time.Duration) []AccountKey {
+	var results []SessionEntity
+	err := r.db.Where("state = ?", StateTransition).Find(&results).Error
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to read sessions in transition.")
+		return make([]AccountKey, 0)
+	}
+
+	now := time.Now()
+	accounts := make([]AccountKey, 0)
+	for _, e := range results {
+		sk := ServiceKey{SessionId: e.SessionId, Service: Service(e.Service)}
+		if !transitionExpired(sk, StateValue{State: State(e.State), UpdatedAt: e.UpdatedAt}, timeouts, now) {
+			continue
+		}
+		t, err := tenant.Create(e.TenantId, e.Region, e.MajorVersion, e.MinorVersion)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, AccountKey{Tenant: t, AccountId: e.AccountId})
+	}
+	return accounts
+}
+
+func (r *DatabaseRegistry) GetExpiredIdle(timeouts map[Service]time.Duration) []AccountKey {
+	now := time.Now()
+	seen := make(map[AccountKey]bool)
+	accounts := make([]AccountKey, 0)
+	for service, timeout := range timeouts {
+		var results []SessionEntity
+		err := r.db.Where("service = ? AND state = ? AND updated_at < ?", string(service), StateLoggedIn, now.Add(-timeout)).Find(&results).Error
+		if err != nil {
+			r.l.WithError(err).Errorf("Unable to read idle sessions of service [%s].", service)
+			continue
+		}
+		for _, e := range results {
+			t, err := tenant.Create(e.TenantId, e.Region, e.MajorVersion, e.MinorVersion)
+			if err != nil {
+				continue
+			}
+			ak := AccountKey{Tenant: t, AccountId: e.AccountId}
+			if !seen[ak] {
+				seen[ak] = true
+				accounts = append(accounts, ak)
+			}
+		}
+	}
+	return accounts
+}
+
+func (r *DatabaseRegistry) Tenants() []tenant.Model {
+	var results []SessionEntity
+	err := r.db.Distinct("tenant_id", "region", "major_version", "minor_version").Find(&results).Error
+	if err != nil {
+		r.l.WithError(err).Errorf("Unable to read tenants holding sessions.")
+		return make([]tenant.Model, 0)
+	}
+
+	var tenants = make([]tenant.Model, 0)
+	for _, e := range results {
+		t, err := tenant.Create(e.TenantId, e.Region, e.MajorVersion, e.MinorVersion)
+		if err != nil {
+			continue
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants
+}
+
+// LoggedInScope restricts a query of the accounts of the tenant to those which are, or are not, logged in, as IsLoggedIn
+// decides. Sessions are removed rather than kept logged out, so an account is logged in while it holds a session of an
+// exclusive service.
+func (r *DatabaseRegistry) LoggedInScope(_ tenant.Model, loggedIn bool) func(db *gorm.DB) *gorm.DB {
+	nonExclusive := GetServices().nonExclusive()
+	return func(db *gorm.DB) *gorm.DB {
+		query := "EXISTS (SELECT 1 FROM account_sessions WHERE account_sessions.tenant_id = accounts.tenant_id AND account_sessions.account_id = accounts.id"
+		var args []interface{}
+		if len(nonExclusive) > 0 {
+			query += " AND account_sessions.service NOT IN ?"
+			args = append(args, nonExclusive)
+		}
+		query += ")"
+		if !loggedIn {
+			query = "NOT " + query
+		}
+		return db.Where(query, args...)
+	}
+}
+
+// modify decides a change to the sessions of an account with f, which reports whether it changed them, and writes it
+// if the sessions have not changed since being read. Otherwise, the change is decided again.
+func (r *DatabaseRegistry) modify(key AccountKey, f func(states map[ServiceKey]StateValue) (bool, error)) error {
+	for i := 0; i < maxRegistryAttempts; i++ {
+		version, states, err := r.read(key)
+		if err != nil {
+			return err
+		}
+		changed, err := f(states)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		err = compareAndSetSessions(r.db)(key, version, states)
+		if !errors.Is(err, ErrConcurrentModification) {
+			return err
+		}
+		r.l.Debugf("Sessions of account [%d] changed concurrently. Retrying.", key.AccountId)
+	}
+	return ErrConcurrentModification
+}
+
+// read retrieves the sessions of an account, and the version they were read at. The version is read first, so sessions
+// changed in between are written back only after being read again.
+func (r *DatabaseRegistry) read(key AccountKey) (uint64, map[ServiceKey]StateValue, error) {
+	var ve SessionVersionEntity
+	err := r.db.Where(&SessionVersionEntity{TenantId: key.Tenant.Id(), AccountId: key.AccountId}).Limit(1).Find(&ve).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var results []SessionEntity
+	err = r.db.Where(&SessionEntity{TenantId: key.Tenant.Id(), AccountId: key.AccountId}).Find(&results).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	states := make(map[ServiceKey]StateValue)
+	for _, e := range results {
+		states[ServiceKey{SessionId: e.SessionId, Service: Service(e.Service)}] = StateValue{State: State(e.State), UpdatedAt: e.UpdatedAt, InstanceId: e.InstanceId}
+	}
+	return ve.Version, states, nil
+}
+
+// compareAndSetSessions replaces the sessions of an account, provided they are still at the version given.
+func compareAndSetSessions(db *gorm.DB) func(key AccountKey, version uint64, states map[ServiceKey]StateValue) error {
+	return func(key AccountKey, version uint64, states map[ServiceKey]StateValue) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			var res *gorm.DB
+			if version == 0 {
+				res = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&SessionVersionEntity{TenantId: key.Tenant.Id(), AccountId: key.AccountId, Version: 1})
+			} else {
+				res = tx.Model(&SessionVersionEntity{}).
+					Where("tenant_id = ? AND account_id = ? AND version = ?", key.Tenant.Id(), key.AccountId, version).
+					Update("version", gorm.Expr("version + 1"))
+			}
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrConcurrentModification
+			}
+
+			err := tx.Where(&SessionEntity{TenantId: key.Tenant.Id(), AccountId: key.AccountId}).Delete(&SessionEntity{}).Error
+			if err != nil {
+				return err
+			}
+			if len(states) == 0 {
+				return nil
+			}
+			es := make([]SessionEntity, 0, len(states))
+			for sk, sv := range states {
+				es = append(es, SessionEntity{
+					TenantId:     key.Tenant.Id(),
+					AccountId:    key.AccountId,
+					SessionId:    sk.SessionId,
+					Service:      string(sk.Service),
+					Region:       key.Tenant.Region(),
+					MajorVersion: key.Tenant.MajorVersion(),
+					MinorVersion: key.Tenant.MinorVersion(),
+					State:        byte(sv.State),
+					UpdatedAt:    sv.UpdatedAt,
+					InstanceId:   sv.InstanceId,
+				})
+			}
+			return tx.Create(&es).Error
+		})
+	}
+}
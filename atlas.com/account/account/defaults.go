@@ -0,0 +1,189 @@
+package account
+
+import (
+	"atlas-account/configuration"
+	"fmt"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	defaultCharacterSlots        = int16(4)
+	defaultMaximumCharacterSlots = int16(15)
+	defaultDeletionGracePeriod   = 30 * 24 * time.Hour
+	defaultNameMinLength         = 4
+	defaultNameMaxLength         = 12
+	defaultNameCharacters        = "^[A-Za-z0-9]+$"
+	defaultPasswordMinLength     = 4
+	defaultPasswordMaxLength     = 12
+	defaultSnapshotMaxAge        = 5 * time.Minute
+)
+
+type locale struct {
+	language string
+	country  string
+}
+
+// regionLocales are the language and country clients of each region expect, absent configuration.
+var regionLocales = map[string]locale{
+	"GMS": {language: "en", country: "us"},
+	"JMS": {language: "ja", country: "jp"},
+	"KMS": {language: "ko", country: "kr"},
+	"CMS": {language: "zh", country: "cn"},
+	"TMS": {language: "zh", country: "tw"},
+}
+
+// accountDefaults resolves the configured account defaults of a tenant, deriving anything left unconfigured from its
+// region.
+func accountDefaults(t tenant.Model, c configuration.AccountDefaults) configuration.AccountDefaults {
+	l, ok := regionLocales[t.Region()]
+	if !ok {
+		l = regionLocales["GMS"]
+	}
+	if c.Language == "" {
+		c.Language = l.language
+	}
+	if c.Country == "" {
+		c.Country = l.country
+	}
+	if c.CharacterSlots <= 0 {
+		c.CharacterSlots = defaultCharacterSlots
+	}
+	return c
+}
+
+func characterSlotMaximum(c configuration.CharacterSlots) int16 {
+	if c.Maximum <= 0 {
+		return defaultMaximumCharacterSlots
+	}
+	return c.Maximum
+}
+
+func deletionPolicy(c configuration.Deletion) configuration.Deletion {
+	if c.GracePeriod <= 0 {
+		c.GracePeriod = defaultDeletionGracePeriod
+	}
+	return c
+}
+
+func sessionRegistry(c configuration.SessionRegistry) configuration.SessionRegistry {
+	if c.SnapshotMaxAge <= 0 {
+		c.SnapshotMaxAge = defaultSnapshotMaxAge
+	}
+	return c
+}
+
+// sessionServices resolves the services sessions may be held with, replacing the built in definition of each service
+// configured.
+func sessionServices(c configuration.SessionRegistry) Services {
+	var results = DefaultServices()
+	for name, sc := range c.Services {
+		d := ServiceDefinition{
+			Exclusive:         sc.Exclusive,
+			TransitionsFrom:   make([]Service, 0),
+			TransitionsTo:     make([]Service, 0),
+			TransitionTimeout: sc.TransitionTimeout,
+			IdleTimeout:       sc.IdleTimeout,
+		}
+		for _, from := range sc.TransitionsFrom {
+			d.TransitionsFrom = append(d.TransitionsFrom, Service(strings.ToUpper(from)))
+		}
+		for _, to := range sc.TransitionsTo {
+			d.TransitionsTo = append(d.TransitionsTo, Service(strings.ToUpper(to)))
+		}
+		if d.TransitionTimeout <= 0 {
+			d.TransitionTimeout = defaultTransitionTimeout
+		}
+		results[Service(strings.ToUpper(name))] = d
+	}
+	return results
+}
+
+func nameRequirements(c configuration.NameRequirements) configuration.NameRequirements {
+	if c.MinLength <= 0 {
+		c.MinLength = defaultNameMinLength
+	}
+	if c.MaxLength <= 0 {
+		c.MaxLength = defaultNameMaxLength
+	}
+	if c.Characters == "" {
+		c.Characters = defaultNameCharacters
+	}
+	return c
+}
+
+func passwordRequirements(c configuration.PasswordRequirements) configuration.PasswordRequirements {
+	if c.MinLength <= 0 {
+		c.MinLength = defaultPasswordMinLength
+	}
+	if c.MaxLength <= 0 {
+		c.MaxLength = defaultPasswordMaxLength
+	}
+	return c
+}
+
+// decorateDefaults fills in values an account has not chosen with the tenant defaults.
+func decorateDefaults(d configuration.AccountDefaults) model.Transformer[Model, Model] {
+	return func(m Model) (Model, error) {
+		if m.language == "" {
+			m.language = d.Language
+		}
+		if m.country == "" {
+			m.country = d.Country
+		}
+		if m.characterSlots <= 0 {
+			m.characterSlots = d.CharacterSlots
+		}
+		return m, nil
+	}
+}
+
+// ValidateConfiguration verifies the policies of every tenant may be applied, so that a misconfigured service refuses to
+// start rather than failing the first player affected. Should configuration be unavailable, the defaults used in its
+// place are valid.
+func ValidateConfiguration(l logrus.FieldLogger) error {
+	c, err := configuration.Get()
+	if err != nil {
+		l.WithError(err).Warnf("Error reading needed configuration. Unable to validate it.")
+		return nil
+	}
+	return validateConfiguration(c)
+}
+
+func validateConfiguration(c *configuration.Configuration) error {
+	err := validateTenantConfiguration(c.Defaults)
+	if err != nil {
+		return fmt.Errorf("defaults: %w", err)
+	}
+	for id := range c.Tenants {
+		tenantId, err := uuid.Parse(id)
+		if err != nil {
+			return fmt.Errorf("tenant [%s]: %w", id, err)
+		}
+		tc, err := c.ForTenant(tenantId)
+		if err != nil {
+			return fmt.Errorf("tenant [%s]: %w", id, err)
+		}
+		err = validateTenantConfiguration(tc)
+		if err != nil {
+			return fmt.Errorf("tenant [%s]: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func validateTenantConfiguration(tc configuration.TenantConfiguration) error {
+	err := hashPolicy(tc.PasswordHashing).Validate()
+	if err != nil {
+		return fmt.Errorf("password hashing: %w", err)
+	}
+	_, err = namePattern(nameRequirements(tc.NameRequirements).Characters)
+	if err != nil {
+		return fmt.Errorf("name requirements: %w", err)
+	}
+	return nil
+}
@@ -1,29 +1,180 @@
 package account
 
 import (
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
-func Migration(db *gorm.DB) error {
-	return db.AutoMigrate(&Entity{})
+// Migration migrates the account schema. Should names already be duplicated, the unique index is not created and the
+// duplicates are logged for resolution by hand, leaving names to be kept unique by the service alone until then.
+func Migration(l logrus.FieldLogger) func(db *gorm.DB) error {
+	return func(db *gorm.DB) error {
+		err := db.AutoMigrate(&Entity{}, &CharacterSlotGrantEntity{}, &PrivilegeChangeEntity{}, &SessionEntity{}, &SessionVersionEntity{}, &SessionSnapshotEntity{})
+		if err != nil {
+			return err
+		}
+		err = migrateUniqueNames(db)
+		var de DuplicateNamesError
+		if errors.As(err, &de) {
+			l.WithError(err).Warnf("Unable to make account names unique. Names will be checked only as accounts are created.")
+			return nil
+		}
+		return err
+	}
+}
+
+// migrateUniqueNames makes account names unique per tenant, ignoring case. Deleted accounts are exempt, so a freed name
+// may be registered again. Names already duplicated must be resolved by hand, so they are reported rather than altered.
+func migrateUniqueNames(db *gorm.DB) error {
+	var duplicates []DuplicateName
+	err := db.Model(&Entity{}).
+		Select("tenant_id, LOWER(name) AS name, COUNT(*) AS count").
+		Group("tenant_id, LOWER(name)").
+		Having("COUNT(*) > 1").
+		Order("tenant_id, LOWER(name)").
+		Scan(&duplicates).Error
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return DuplicateNamesError{Duplicates: duplicates}
+	}
+	return db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_tenant_name ON accounts (tenant_id, LOWER(name)) WHERE deleted_at IS NULL").Error
+}
+
+// DuplicateName is a name, lower cased, held by more than one live account of a tenant.
+type DuplicateName struct {
+	TenantId uuid.UUID
+	Name     string
+	Count    int64
+}
+
+// DuplicateNamesError prevents names being made unique until the accounts reported are renamed or deleted.
+type DuplicateNamesError struct {
+	Duplicates []DuplicateName
+}
+
+func (e DuplicateNamesError) Error() string {
+	ds := make([]string, 0, len(e.Duplicates))
+	for _, d := range e.Duplicates {
+		ds = append(ds, fmt.Sprintf("tenant [%s] name [%s] held by [%d] accounts", d.TenantId.String(), d.Name, d.Count))
+	}
+	return "duplicate account names must be resolved before names can be made unique: " + strings.Join(ds, ", ")
 }
 
 type Entity struct {
-	TenantId  uuid.UUID `gorm:"not null"`
-	ID        uint32    `gorm:"primaryKey;autoIncrement;not null"`
-	Name      string    `gorm:"not null"`
-	Password  string    `gorm:"not null"`
-	PIN       string
-	PIC       string
-	Gender    byte `gorm:"not null;default=0"`
-	TOS       bool `gorm:"not null;default=false"`
-	LastLogin int64
-	CreatedAt time.Time // Automatically managed by GORM for creation time
-	UpdatedAt time.Time // Automatically managed by GORM for update time
+	TenantId          uuid.UUID `gorm:"not null"`
+	ID                uint32    `gorm:"primaryKey;autoIncrement;not null"`
+	Name              string    `gorm:"not null"`
+	Password          string    `gorm:"not null"`
+	CredentialVersion uint32    `gorm:"not null;default:0"`
+	PIN               string
+	PIC               string
+	Gender            byte  `gorm:"not null;default=0"`
+	TOS               bool  `gorm:"not null;default=false"`
+	LastLogin         int64 // Unix time in milliseconds of the last successful login.
+	LastLoginIP       string
+	Language          string         `gorm:"not null;default:''"`
+	Country           string         `gorm:"not null;default:''"`
+	CharacterSlots    int16          `gorm:"not null;default:0"` // Zero denotes the tenant default.
+	GMLevel           byte           `gorm:"not null;default:0"`
+	Roles             string         `gorm:"not null;default:''"` // Sorted, comma separated role names.
+	CreatedAt         time.Time      // Automatically managed by GORM for creation time
+	UpdatedAt         time.Time      // Automatically managed by GORM for update time
+	DeletedAt         gorm.DeletedAt `gorm:"index"` // Tombstone of a deleted account, retained until purged.
 }
 
 func (e Entity) TableName() string {
 	return "accounts"
 }
+
+// CharacterSlotGrantEntity records character slots added to an account. The idempotency key, when given, ensures a
+// grant is applied once no matter how often it is requested.
+type CharacterSlotGrantEntity struct {
+	TenantId       uuid.UUID `gorm:"not null;uniqueIndex:idx_character_slot_grants_key"`
+	ID             uint32    `gorm:"primaryKey;autoIncrement;not null"`
+	AccountId      uint32    `gorm:"not null;index"`
+	IdempotencyKey *string   `gorm:"uniqueIndex:idx_character_slot_grants_key"`
+	Amount         int16     `gorm:"not null"`
+	Previous       int16     `gorm:"not null"`
+	CharacterSlots int16     `gorm:"not null"`
+	CreatedAt      time.Time // Automatically managed by GORM for creation time
+}
+
+func (e CharacterSlotGrantEntity) TableName() string {
+	return "character_slot_grants"
+}
+
+// PrivilegeChangeEntity is an append-only audit record of a change to the GM level or roles of an account.
+type PrivilegeChangeEntity struct {
+	TenantId        uuid.UUID `gorm:"not null;index:idx_privilege_changes_tenant_account"`
+	ID              uint64    `gorm:"primaryKey;autoIncrement;not null"`
+	AccountId       uint32    `gorm:"not null;index:idx_privilege_changes_tenant_account"`
+	PreviousGMLevel byte      `gorm:"not null"`
+	GMLevel         byte      `gorm:"not null"`
+	PreviousRoles   string    `gorm:"not null;default:''"`
+	Roles           string    `gorm:"not null;default:''"`
+	Issuer          string    `gorm:"not null"`
+	Reason          string    `gorm:"not null;default:''"`
+	CreatedAt       time.Time // Automatically managed by GORM for creation time
+}
+
+func (e PrivilegeChangeEntity) TableName() string {
+	return "privilege_changes"
+}
+
+// SessionEntity is a session held in the database registry. The tenant is recorded in full, so tasks which act on
+// sessions can act on behalf of the tenant.
+type SessionEntity struct {
+	TenantId     uuid.UUID `gorm:"primaryKey"`
+	AccountId    uint32    `gorm:"primaryKey;autoIncrement:false"`
+	SessionId    uuid.UUID `gorm:"primaryKey"`
+	Service      string    `gorm:"primaryKey"`
+	Region       string    `gorm:"not null"`
+	MajorVersion uint16    `gorm:"not null"`
+	MinorVersion uint16    `gorm:"not null"`
+	State        byte      `gorm:"not null;index"`
+	UpdatedAt    time.Time `gorm:"not null;autoUpdateTime:false"`
+	InstanceId   uuid.UUID `gorm:"index"`
+}
+
+func (e SessionEntity) TableName() string {
+	return "account_sessions"
+}
+
+// SessionVersionEntity counts the changes made to the sessions of an account, so a change decided from sessions which
+// have since changed is refused.
+type SessionVersionEntity struct {
+	TenantId  uuid.UUID `gorm:"primaryKey"`
+	AccountId uint32    `gorm:"primaryKey;autoIncrement:false"`
+	Version   uint64    `gorm:"not null;default:0"`
+}
+
+func (e SessionVersionEntity) TableName() string {
+	return "account_session_versions"
+}
+
+// SessionSnapshotEntity is a session held in memory by an instance of the service when it last shut down.
+type SessionSnapshotEntity struct {
+	Instance     string    `gorm:"primaryKey"`
+	TenantId     uuid.UUID `gorm:"primaryKey"`
+	AccountId    uint32    `gorm:"primaryKey;autoIncrement:false"`
+	SessionId    uuid.UUID `gorm:"primaryKey"`
+	Service      string    `gorm:"primaryKey"`
+	Region       string    `gorm:"not null"`
+	MajorVersion uint16    `gorm:"not null"`
+	MinorVersion uint16    `gorm:"not null"`
+	State        byte      `gorm:"not null"`
+	UpdatedAt    time.Time `gorm:"not null;autoUpdateTime:false"`
+	InstanceId   uuid.UUID
+	TakenAt      time.Time `gorm:"not null"`
+}
+
+func (e SessionSnapshotEntity) TableName() string {
+	return "account_session_snapshots"
+}
@@ -2,6 +2,7 @@ package account
 
 import (
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
@@ -14,17 +15,26 @@ const (
 )
 
 type Model struct {
-	tenantId  uuid.UUID
-	id        uint32
-	name      string
-	password  string
-	pin       string
-	pic       string
-	state     State
-	gender    byte
-	banned    bool
-	tos       bool
-	updatedAt time.Time
+	tenantId          uuid.UUID
+	id                uint32
+	name              string
+	password          string
+	credentialVersion uint32
+	pin               string
+	pic               string
+	state             State
+	gender            byte
+	banned            bool
+	tos               bool
+	lastLogin         int64
+	lastLoginIP       string
+	language          string
+	country           string
+	characterSlots    int16
+	gmLevel           byte
+	roles             []string
+	updatedAt         time.Time
+	deletedAt         time.Time
 }
 
 func (a Model) Id() uint32 {
@@ -39,6 +49,10 @@ func (a Model) Password() string {
 	return a.password
 }
 
+func (a Model) CredentialVersion() uint32 {
+	return a.credentialVersion
+}
+
 func (a Model) Banned() bool {
 	return a.banned
 }
@@ -51,6 +65,26 @@ func (a Model) TOS() bool {
 	return a.tos
 }
 
+func (a Model) LastLogin() int64 {
+	return a.lastLogin
+}
+
+func (a Model) LastLoginIP() string {
+	return a.lastLoginIP
+}
+
+func (a Model) Language() string {
+	return a.language
+}
+
+func (a Model) Country() string {
+	return a.country
+}
+
+func (a Model) CharacterSlots() int16 {
+	return a.characterSlots
+}
+
 func (a Model) UpdatedAt() time.Time {
 	return a.updatedAt
 }
@@ -67,6 +101,94 @@ func (a Model) Pic() string {
 	return a.pic
 }
 
+func (a Model) PinSet() bool {
+	return a.pin != ""
+}
+
+func (a Model) PicSet() bool {
+	return a.pic != ""
+}
+
 func LoggedIn(m Model) bool {
 	return m.state != StateNotLoggedIn
 }
+
+// DeletedAt is when the account was deleted, or zero for a live account.
+func (a Model) DeletedAt() time.Time {
+	return a.deletedAt
+}
+
+func (a Model) Deleted() bool {
+	return !a.deletedAt.IsZero()
+}
+
+// GMLevel is the privilege level of the account. Zero denotes a player, while any higher level marks a staff account.
+func (a Model) GMLevel() byte {
+	return a.gmLevel
+}
+
+func (a Model) GM() bool {
+	return a.gmLevel > 0
+}
+
+// Roles are the named privileges granted to the account, sorted.
+func (a Model) Roles() []string {
+	return a.roles
+}
+
+func (a Model) HasRole(role string) bool {
+	return slices.Contains(a.roles, normalizeRole(role))
+}
+
+type PrivilegeChange struct {
+	tenantId        uuid.UUID
+	id              uint64
+	accountId       uint32
+	previousGMLevel byte
+	gmLevel         byte
+	previousRoles   []string
+	roles           []string
+	issuer          string
+	reason          string
+	createdAt       time.Time
+}
+
+func (c PrivilegeChange) Id() uint64 {
+	return c.id
+}
+
+func (c PrivilegeChange) TenantId() uuid.UUID {
+	return c.tenantId
+}
+
+func (c PrivilegeChange) AccountId() uint32 {
+	return c.accountId
+}
+
+func (c PrivilegeChange) PreviousGMLevel() byte {
+	return c.previousGMLevel
+}
+
+func (c PrivilegeChange) GMLevel() byte {
+	return c.gmLevel
+}
+
+func (c PrivilegeChange) PreviousRoles() []string {
+	return c.previousRoles
+}
+
+func (c PrivilegeChange) Roles() []string {
+	return c.roles
+}
+
+func (c PrivilegeChange) Issuer() string {
+	return c.issuer
+}
+
+func (c PrivilegeChange) Reason() string {
+	return c.reason
+}
+
+func (c PrivilegeChange) CreatedAt() time.Time {
+	return c.createdAt
+}
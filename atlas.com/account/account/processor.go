@@ -1,19 +1,26 @@
 package account
 
 import (
+	"atlas-account/attempt"
+	"atlas-account/ban"
 	"atlas-account/configuration"
+	"atlas-account/credential"
+	"atlas-account/filetime"
 	"atlas-account/kafka/message"
 	account2 "atlas-account/kafka/message/account"
 	"atlas-account/kafka/producer"
+	"atlas-account/login"
 	"context"
+	"crypto/subtle"
 	"errors"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/Chronicle20/atlas-tenant"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
-	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,23 +31,106 @@ const (
 	AlreadyLoggedIn   = "ALREADY_LOGGED_IN"
 	IncorrectPassword = "INCORRECT_PASSWORD"
 	TooManyAttempts   = "TOO_MANY_ATTEMPTS"
+	BlockedClient     = "BLOCKED_CLIENT"
+	IncorrectPin      = "INCORRECT_PIN"
+	IncorrectPic      = "INCORRECT_PIC"
+	PinNotSet         = "PIN_NOT_SET"
+	PicNotSet         = "PIC_NOT_SET"
+	PinLocked         = "PIN_LOCKED"
+	PicLocked         = "PIC_LOCKED"
+	InvalidName       = "INVALID_NAME"
+	InvalidPassword   = "INVALID_PASSWORD"
 )
 
+var (
+	ErrSecretNotSet   = errors.New("secret not set")
+	ErrSecretMismatch = errors.New("secret mismatch")
+
+	ErrInvalidCharacterSlots  = errors.New("invalid character slots")
+	ErrCharacterSlotLimit     = errors.New("character slot limit reached")
+	ErrIdempotencyConflict    = errors.New("idempotency key used for another account")
+	ErrConcurrentModification = errors.New("account modified concurrently")
+	ErrInvalidName            = errors.New("invalid name")
+	ErrInvalidGender          = errors.New("invalid gender")
+	ErrInvalidPassword        = errors.New("invalid password")
+	ErrPasswordMismatch       = errors.New("password mismatch")
+	ErrInvalidRole            = errors.New("invalid role")
+	ErrIssuerRequired         = errors.New("issuer required")
+	ErrAlreadyExists          = errors.New("account already exists")
+	ErrNameReserved           = errors.New("name reserved by a deleted account")
+	ErrNameTaken              = errors.New("name taken")
+	ErrGracePeriodElapsed     = errors.New("deletion grace period elapsed")
+	ErrInvalidInstance        = errors.New("invalid service instance")
+)
+
+// SecretLockedError reports a secondary password which may not be verified until the lockout expires.
+type SecretLockedError struct {
+	Until time.Time
+}
+
+func (e SecretLockedError) Error() string {
+	return "secret locked until " + e.Until.String()
+}
+
+// PasswordLockedError reports a password which may not be verified until the lockout expires.
+type PasswordLockedError struct {
+	Until time.Time
+}
+
+func (e PasswordLockedError) Error() string {
+	return "password locked until " + e.Until.String()
+}
+
 type Processor interface {
 	GetOrCreate(mb *message.Buffer) func(name string, password string, automaticRegister bool) (Model, error)
+	Validate(name string, password string) error
 	CreateAndEmit(name string, password string) (Model, error)
 	Create(mb *message.Buffer) func(name string) func(password string) (Model, error)
-	Update(accountId uint32, input Model) (Model, error)
-	Login(mb *message.Buffer) func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) error
+	CreateWithGenderAndEmit(name string, password string, gender byte) (Model, error)
+	CreateWithGender(mb *message.Buffer) func(name string, password string, gender byte) (Model, error)
+	CreateForTransactionAndEmit(transactionId uuid.UUID, name string, password string, gender *byte) (Model, error)
+	CreateForTransaction(mb *message.Buffer) func(transactionId uuid.UUID, name string, password string, gender *byte) (Model, error)
+	UpdateAndEmit(accountId uint32, input Model) (Model, error)
+	Update(mb *message.Buffer) func(accountId uint32, input Model) (Model, error)
+	AddCharacterSlotsAndEmit(accountId uint32, amount int16, idempotencyKey string) (Model, error)
+	AddCharacterSlots(mb *message.Buffer) func(accountId uint32, amount int16, idempotencyKey string) (Model, error)
+	ChangePasswordAndEmit(accountId uint32, oldPassword string, newPassword string) error
+	ChangePassword(mb *message.Buffer) func(accountId uint32, oldPassword string, newPassword string) error
+	ResetPasswordAndEmit(accountId uint32, password string, issuer string) error
+	ResetPassword(mb *message.Buffer) func(accountId uint32, password string, issuer string) error
+	DeleteAndEmit(accountId uint32) error
+	Delete(mb *message.Buffer) func(accountId uint32) error
+	RestoreAndEmit(accountId uint32) (Model, error)
+	Restore(mb *message.Buffer) func(accountId uint32) (Model, error)
+	SetPrivilegesAndEmit(accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (Model, error)
+	SetPrivileges(mb *message.Buffer) func(accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (Model, error)
+	GetPrivilegeChanges(accountId uint32, offset int, limit int) ([]PrivilegeChange, error)
+	PrivilegeChangesProvider(accountId uint32, offset int, limit int) model.Provider[[]PrivilegeChange]
+	CountPrivilegeChanges(accountId uint32) (int64, error)
+	Login(mb *message.Buffer) func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) func(instanceId uuid.UUID) error
 	LogoutAndEmit(sessionId uuid.UUID, accountId uint32, issuer string) error
 	Logout(mb *message.Buffer) func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) error
-	AttemptLoginAndEmit(sessionId uuid.UUID, name string, password string) error
-	AttemptLogin(mb *message.Buffer) func(sessionId uuid.UUID, name string, password string) error
-	ProgressStateAndEmit(sessionId uuid.UUID, issuer string, accountId uint32, state State, params interface{}) error
-	ProgressState(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, accountId uint32, state State, params interface{}) error
+	Heartbeat(sessionId uuid.UUID, accountId uint32, issuer string) error
+	TerminateInstanceAndEmit(instanceId uuid.UUID) error
+	TerminateInstance(mb *message.Buffer) func(instanceId uuid.UUID) error
+	ExpireIdleAndEmit(accountId uint32, timeouts map[Service]time.Duration) error
+	ExpireIdle(mb *message.Buffer) func(accountId uint32, timeouts map[Service]time.Duration) error
+	AttemptLoginAndEmit(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, name string, password string, ipAddress string, macAddress string, hwid string) error
+	AttemptLogin(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, name string, password string, ipAddress string, macAddress string, hwid string) error
+	ProgressStateAndEmit(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, accountId uint32, state State, params interface{}) error
+	ProgressState(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, accountId uint32, state State, params interface{}) error
+	VerifyPin(accountId uint32, pin string) error
+	VerifyPic(accountId uint32, pic string) error
+	AttemptPinAndEmit(sessionId uuid.UUID, accountId uint32, pin string) error
+	AttemptPin(mb *message.Buffer) func(sessionId uuid.UUID, accountId uint32, pin string) error
+	AttemptPicAndEmit(sessionId uuid.UUID, accountId uint32, pic string) error
+	AttemptPic(mb *message.Buffer) func(sessionId uuid.UUID, accountId uint32, pic string) error
 	GetById(accountId uint32) (Model, error)
 	GetByName(name string) (Model, error)
 	GetByTenant() ([]Model, error)
+	GetByCriteria(c Criteria, offset int, limit int) ([]Model, error)
+	ByCriteriaProvider(c Criteria, offset int, limit int) model.Provider[[]Model]
+	CountByCriteria(c Criteria) (int64, error)
 	ByIdProvider(accountId uint32) model.Provider[Model]
 	ByNameProvider(name string) model.Provider[Model]
 	ByTenantProvider() ([]Model, error)
@@ -72,7 +162,7 @@ func (p *ProcessorImpl) GetById(accountId uint32) (Model, error) {
 }
 
 func (p *ProcessorImpl) ByIdProvider(accountId uint32) model.Provider[Model] {
-	return model.Map(decorateState(p.t))(model.Map(Make)(entityById(p.t, accountId)(p.db)))
+	return model.Map(p.decorateBan)(model.Map(decorateState(p.t))(model.Map(decorateDefaults(p.accountDefaults()))(model.Map(Make)(entityById(p.t, accountId)(p.db)))))
 }
 
 func (p *ProcessorImpl) GetByName(name string) (Model, error) {
@@ -80,7 +170,7 @@ func (p *ProcessorImpl) GetByName(name string) (Model, error) {
 }
 
 func (p *ProcessorImpl) ByNameProvider(name string) model.Provider[Model] {
-	return model.Map(decorateState(p.t))(model.FirstProvider(model.SliceMap(Make)(entitiesByName(p.t, name)(p.db))(model.ParallelMap()), model.Filters[Model]()))
+	return model.Map(p.decorateBan)(model.Map(decorateState(p.t))(model.Map(decorateDefaults(p.accountDefaults()))(model.FirstProvider(model.SliceMap(Make)(entitiesByName(p.t, name)(p.db))(model.ParallelMap()), model.Filters[Model]()))))
 }
 
 func (p *ProcessorImpl) GetByTenant() ([]Model, error) {
@@ -88,7 +178,22 @@ func (p *ProcessorImpl) GetByTenant() ([]Model, error) {
 }
 
 func (p *ProcessorImpl) ByTenantProvider() ([]Model, error) {
-	return model.SliceMap(decorateState(p.t))(model.SliceMap(Make)(allInTenant(p.t)(p.db))(model.ParallelMap()))(model.ParallelMap())()
+	return model.Map(p.decorateBans)(model.SliceMap(decorateState(p.t))(model.SliceMap(decorateDefaults(p.accountDefaults()))(model.SliceMap(Make)(allInTenant(p.t)(p.db))(model.ParallelMap()))(model.ParallelMap()))(model.ParallelMap()))()
+}
+
+func (p *ProcessorImpl) GetByCriteria(c Criteria, offset int, limit int) ([]Model, error) {
+	return p.ByCriteriaProvider(c, offset, limit)()
+}
+
+// ByCriteriaProvider provides a window of the accounts of the tenant meeting the criteria, in the order they specify.
+// Only the window is read from the database.
+func (p *ProcessorImpl) ByCriteriaProvider(c Criteria, offset int, limit int) model.Provider[[]Model] {
+	ep := entitiesByCriteria(p.t, c, Get(), offset, limit)(p.db)
+	return model.Map(p.decorateBans)(model.SliceMap(decorateState(p.t))(model.SliceMap(decorateDefaults(p.accountDefaults()))(model.SliceMap(Make)(ep)(model.ParallelMap()))(model.ParallelMap()))(model.ParallelMap()))
+}
+
+func (p *ProcessorImpl) CountByCriteria(c Criteria) (int64, error) {
+	return countByCriteria(p.t, c, Get())(p.db)()
 }
 
 func (p *ProcessorImpl) LoggedInTenantProvider() ([]Model, error) {
@@ -96,7 +201,9 @@ func (p *ProcessorImpl) LoggedInTenantProvider() ([]Model, error) {
 }
 
 // allTenants Retrieves all tenants with accounts associated.
-var allTenants = model.FixedProvider(Get().Tenants())
+func allTenants() ([]tenant.Model, error) {
+	return Get().Tenants(), nil
+}
 
 func decorateState(tenant tenant.Model) model.Transformer[Model, Model] {
 	return func(m Model) (Model, error) {
@@ -106,8 +213,33 @@ func decorateState(tenant tenant.Model) model.Transformer[Model, Model] {
 	}
 }
 
-func GetInTransition(timeout time.Duration) ([]AccountKey, error) {
-	return model.FixedProvider(Get().GetExpiredInTransition(timeout))()
+func (p *ProcessorImpl) decorateBan(m Model) (Model, error) {
+	_, err := ban.NewProcessor(p.l, p.ctx, p.db).GetActiveByAccountId(m.Id())
+	if err != nil && !errors.Is(err, ban.ErrNotBanned) {
+		return Model{}, err
+	}
+	m.banned = err == nil
+	return m, nil
+}
+
+// decorateBans marks which of the accounts are banned, looking them up together rather than one account at a time.
+func (p *ProcessorImpl) decorateBans(ms []Model) ([]Model, error) {
+	ids := make([]uint32, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.Id())
+	}
+	banned, err := ban.NewProcessor(p.l, p.ctx, p.db).GetBannedAccountIds(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ms {
+		ms[i].banned = banned[ms[i].Id()]
+	}
+	return ms, nil
+}
+
+func GetInTransition(timeouts map[Service]time.Duration) ([]AccountKey, error) {
+	return model.FixedProvider(Get().GetExpiredInTransition(timeouts))()
 }
 
 func (p *ProcessorImpl) GetOrCreate(mb *message.Buffer) func(name string, password string, automaticRegister bool) (Model, error) {
@@ -121,10 +253,25 @@ func (p *ProcessorImpl) GetOrCreate(mb *message.Buffer) func(name string, passwo
 			p.l.Errorf("Unable to locate account by name [%s], and automatic account creation is not enabled.", name)
 			return Model{}, errors.New("account not found")
 		}
-		return p.Create(mb)(name)(password)
+		m, err = p.Create(mb)(name)(password)
+		if errors.Is(err, ErrAlreadyExists) {
+			// Another session registered the name concurrently.
+			return p.GetByName(name)
+		}
+		return m, err
 	}
 }
 
+// Validate checks a name and password chosen for a new account against the tenant requirements. A RequirementsError
+// lists every requirement not met.
+func (p *ProcessorImpl) Validate(name string, password string) error {
+	nvs, err := validateName(p.nameRequirements(), name)
+	if err != nil {
+		return err
+	}
+	return requirementsError(nvs, validatePassword(p.passwordRequirements(), password))
+}
+
 func (p *ProcessorImpl) CreateAndEmit(name string, password string) (Model, error) {
 	return message.EmitWithResult[Model, string](p.p)(model.Flip(p.Create)(name))(password)
 }
@@ -132,87 +279,584 @@ func (p *ProcessorImpl) CreateAndEmit(name string, password string) (Model, erro
 func (p *ProcessorImpl) Create(mb *message.Buffer) func(name string) func(password string) (Model, error) {
 	return func(name string) func(password string) (Model, error) {
 		return func(password string) (Model, error) {
-			p.l.Debugf("Attempting to create account [%s] with password [%s].", name, password)
-			hashPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			gender := defaultGender(p.t)
+			p.l.Debugf("Defaulting gender to [%d]. 0 = Male, 1 = Female, 10 = UI Choose. This is determined by Region and Version capabilities.", gender)
+			return p.CreateWithGender(mb)(name, password, gender)
+		}
+	}
+}
+
+func (p *ProcessorImpl) CreateWithGenderAndEmit(name string, password string, gender byte) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.CreateWithGender(buf)(name, password, gender)
+		return err
+	})
+	return result, err
+}
+
+// CreateWithGender creates an account as Create does, but with the gender requested rather than the tenant default.
+func (p *ProcessorImpl) CreateWithGender(mb *message.Buffer) func(name string, password string, gender byte) (Model, error) {
+	return func(name string, password string, gender byte) (Model, error) {
+		return p.createAccount(mb)(uuid.Nil, name, password, gender)
+	}
+}
+
+func (p *ProcessorImpl) CreateForTransactionAndEmit(transactionId uuid.UUID, name string, password string, gender *byte) (Model, error) {
+	var result Model
+	var cerr error
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		result, cerr = p.CreateForTransaction(buf)(transactionId, name, password, gender)
+		return nil
+	})
+	if cerr != nil {
+		return Model{}, cerr
+	}
+	return result, err
+}
+
+// CreateForTransaction creates an account on behalf of the create command with the transaction id, reporting the outcome
+// as a CREATED or CREATE_FAILED status event carrying it. The tenant default gender applies when none is requested.
+func (p *ProcessorImpl) CreateForTransaction(mb *message.Buffer) func(transactionId uuid.UUID, name string, password string, gender *byte) (Model, error) {
+	return func(transactionId uuid.UUID, name string, password string, gender *byte) (Model, error) {
+		g := defaultGender(p.t)
+		if gender != nil {
+			g = *gender
+		}
+		m, err := p.createAccount(mb)(transactionId, name, password, g)
+		if err != nil {
+			code, violations := createErrorCode(err)
+			_ = mb.Put(account2.EnvEventTopicStatus, createFailedEventProvider(transactionId, name, code, violations))
+			return Model{}, err
+		}
+		return m, nil
+	}
+}
+
+func (p *ProcessorImpl) createAccount(mb *message.Buffer) func(transactionId uuid.UUID, name string, password string, gender byte) (Model, error) {
+	return func(transactionId uuid.UUID, name string, password string, gender byte) (Model, error) {
+		p.l.Debugf("Attempting to create account [%s].", name)
+		if !validGender(p.t, gender) {
+			p.l.Errorf("Unable to create account [%s] with gender [%d].", name, gender)
+			return Model{}, ErrInvalidGender
+		}
+		if err := p.Validate(name, password); err != nil {
+			p.l.WithError(err).Errorf("Unable to create account [%s].", name)
+			return Model{}, err
+		}
+		if _, err := p.GetByName(name); err == nil {
+			p.l.Errorf("Unable to create account [%s], as the name is taken.", name)
+			return Model{}, ErrAlreadyExists
+		}
+		if _, ok := p.reservedBy(name); ok {
+			p.l.Errorf("Unable to create account [%s], as the name is reserved by a deleted account.", name)
+			return Model{}, ErrNameReserved
+		}
+		hashPass, err := credential.Hash(password, p.passwordPolicy())
+		if err != nil {
+			p.l.WithError(err).Errorf("Error generating hash when creating account [%s].", name)
+			return Model{}, err
+		}
+
+		d := p.accountDefaults()
+		p.l.Debugf("Defaulting language to [%s], country to [%s] and character slots to [%d].", d.Language, d.Country, d.CharacterSlots)
+
+		m, err := create(p.db)(p.t, name, hashPass, gender, updateLanguage(d.Language), updateCountry(d.Country), updateCharacterSlots(d.CharacterSlots))
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to create account [%s].", name)
+			return Model{}, err
+		}
+		p.l.Debugf("Created account [%d] for [%s].", m.Id(), m.Name())
+		_ = mb.Put(account2.EnvEventTopicStatus, createdEventProvider(transactionId, m.Id(), name))
+		return m, nil
+	}
+}
+
+// createErrorCode describes why an account could not be created, along with the codes of any requirements violated.
+func createErrorCode(err error) (string, []string) {
+	var violations []string
+	var re RequirementsError
+	if errors.As(err, &re) {
+		for _, v := range re.Violations {
+			violations = append(violations, v.Code)
+		}
+	}
+	switch {
+	case errors.Is(err, ErrInvalidName):
+		return account2.CreateErrorInvalidName, violations
+	case errors.Is(err, ErrInvalidPassword):
+		return account2.CreateErrorInvalidPassword, violations
+	case errors.Is(err, ErrInvalidGender):
+		return account2.CreateErrorInvalidGender, violations
+	case errors.Is(err, ErrAlreadyExists):
+		return account2.CreateErrorAlreadyExists, violations
+	case errors.Is(err, ErrNameReserved):
+		return account2.CreateErrorNameReserved, violations
+	}
+	return account2.CreateErrorSystemError, violations
+}
+
+// defaultGender is the gender given to accounts of the tenant which do not request one.
+func defaultGender(t tenant.Model) byte {
+	if t.Region() == "GMS" && t.MajorVersion() > 83 {
+		return byte(10)
+	}
+	return byte(0)
+}
+
+// validGender reports whether an account of the tenant may be created with the gender.
+func validGender(t tenant.Model, gender byte) bool {
+	return gender == 0 || gender == 1 || gender == defaultGender(t)
+}
+
+func (p *ProcessorImpl) UpdateAndEmit(accountId uint32, input Model) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.Update(buf)(accountId, input)
+		return err
+	})
+	return result, err
+}
+
+func (p *ProcessorImpl) Update(mb *message.Buffer) func(accountId uint32, input Model) (Model, error) {
+	return func(accountId uint32, input Model) (Model, error) {
+		a, err := p.GetById(accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate account being updated.")
+			return Model{}, err
+		}
+		if input.characterSlots < 0 || input.characterSlots > p.characterSlotMaximum() {
+			return Model{}, ErrInvalidCharacterSlots
+		}
+		// Values left to the tenant defaults are compared as stored, so that choosing the default explicitly persists it.
+		stored, err := model.Map(Make)(entityById(p.t, accountId)(p.db))()
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate account being updated.")
+			return Model{}, err
+		}
+
+		var modifiers = make([]EntityUpdateFunction, 0)
+
+		if input.pin != "" {
+			hashPin, err := credential.Hash(input.pin, p.passwordPolicy())
 			if err != nil {
-				p.l.WithError(err).Errorf("Error generating hash when creating account [%s].", name)
+				p.l.WithError(err).Errorf("Error generating hash when updating PIN of account [%d].", accountId)
 				return Model{}, err
 			}
-
-			gender := byte(0)
-			if p.t.Region() == "GMS" && p.t.MajorVersion() > 83 {
-				gender = byte(10)
-			}
-			p.l.Debugf("Defaulting gender to [%d]. 0 = Male, 1 = Female, 10 = UI Choose. This is determined by Region and Version capabilities.", gender)
-
-			m, err := create(p.db)(p.t, name, string(hashPass), gender)
+			p.l.Debugf("Updating PIN of account [%d].", accountId)
+			modifiers = append(modifiers, updatePin(hashPin))
+		}
+		if input.pic != "" {
+			hashPic, err := credential.Hash(input.pic, p.passwordPolicy())
 			if err != nil {
-				p.l.WithError(err).Errorf("Unable to create account [%s].", name)
+				p.l.WithError(err).Errorf("Error generating hash when updating PIC of account [%d].", accountId)
 				return Model{}, err
 			}
-			p.l.Debugf("Created account [%d] for [%s].", m.Id(), m.Name())
-			_ = mb.Put(account2.EnvEventTopicStatus, createdEventProvider()(m.Id(), name))
-			return m, nil
+			p.l.Debugf("Updating PIC of account [%d].", accountId)
+			modifiers = append(modifiers, updatePic(hashPic))
+		}
+		if a.tos != input.tos && input.tos != false {
+			p.l.Debugf("Updating TOS [%t] of account [%d].", input.tos, accountId)
+			modifiers = append(modifiers, updateTos(input.tos))
+		}
+		if a.gender != input.gender {
+			p.l.Debugf("Updating Gender [%d] of account [%d].", input.gender, accountId)
+			modifiers = append(modifiers, updateGender(input.gender))
 		}
+		if stored.language != input.language && input.language != "" {
+			p.l.Debugf("Updating Language [%s] of account [%d].", input.language, accountId)
+			modifiers = append(modifiers, updateLanguage(input.language))
+		}
+		if stored.country != input.country && input.country != "" {
+			p.l.Debugf("Updating Country [%s] of account [%d].", input.country, accountId)
+			modifiers = append(modifiers, updateCountry(input.country))
+		}
+		if stored.characterSlots != input.characterSlots && input.characterSlots != 0 {
+			p.l.Debugf("Updating Character Slots [%d] of account [%d].", input.characterSlots, accountId)
+			modifiers = append(modifiers, updateCharacterSlots(input.characterSlots))
+		}
+		slotsChanged := a.characterSlots != input.characterSlots && input.characterSlots != 0
+
+		if len(modifiers) == 0 {
+			return a, nil
+		}
+
+		err = update(p.db)(modifiers...)(p.t, accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to update account.")
+			return Model{}, err
+		}
+
+		if slotsChanged {
+			_ = mb.Put(account2.EnvEventTopicStatus, characterSlotsChangedEventProvider(a.Id(), a.Name(), a.characterSlots, input.characterSlots, ""))
+		}
+		return p.GetById(accountId)
 	}
 }
 
-func (p *ProcessorImpl) Update(accountId uint32, input Model) (Model, error) {
-	a, err := p.GetById(accountId)
-	if err != nil {
-		p.l.WithError(err).Errorf("Unable to locate account being updated.")
-		return Model{}, err
+func (p *ProcessorImpl) AddCharacterSlotsAndEmit(accountId uint32, amount int16, idempotencyKey string) (Model, error) {
+	var result Model
+	var aerr error
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		result, aerr = p.AddCharacterSlots(buf)(accountId, amount, idempotencyKey)
+		return nil
+	})
+	if aerr != nil {
+		return Model{}, aerr
 	}
+	return result, err
+}
+
+// AddCharacterSlots grants character slots to an account, such as for a slot expansion sold by the cash shop. Repeating
+// a request with the same idempotency key has no further effect. A grant which is refused is reported by a
+// CHARACTER_SLOTS_FAILED status event.
+func (p *ProcessorImpl) AddCharacterSlots(mb *message.Buffer) func(accountId uint32, amount int16, idempotencyKey string) (Model, error) {
+	return func(accountId uint32, amount int16, idempotencyKey string) (Model, error) {
+		a, err := p.addCharacterSlots(mb)(accountId, amount, idempotencyKey)
+		if err != nil {
+			_ = mb.Put(account2.EnvEventTopicStatus, characterSlotsFailedEventProvider(accountId, amount, idempotencyKey, characterSlotsErrorCode(err)))
+			return Model{}, err
+		}
+		return a, nil
+	}
+}
 
-	var modifiers = make([]EntityUpdateFunction, 0)
+// characterSlotsErrorCode classifies why a grant of character slots was refused.
+func characterSlotsErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCharacterSlots):
+		return account2.CharacterSlotsErrorInvalidAmount
+	case errors.Is(err, ErrCharacterSlotLimit):
+		return account2.CharacterSlotsErrorLimitReached
+	case errors.Is(err, ErrIdempotencyConflict):
+		return account2.CharacterSlotsErrorIdempotencyConflict
+	case errors.Is(err, ErrConcurrentModification):
+		return account2.CharacterSlotsErrorConcurrentModification
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return account2.CharacterSlotsErrorNotFound
+	}
+	return account2.CharacterSlotsErrorSystemError
+}
+
+func (p *ProcessorImpl) addCharacterSlots(mb *message.Buffer) func(accountId uint32, amount int16, idempotencyKey string) (Model, error) {
+	return func(accountId uint32, amount int16, idempotencyKey string) (Model, error) {
+		if amount <= 0 {
+			return Model{}, ErrInvalidCharacterSlots
+		}
+		maximum := p.characterSlotMaximum()
+		if amount > maximum {
+			return Model{}, ErrCharacterSlotLimit
+		}
+
+		g, replayed, err := grantCharacterSlots(p.db)(p.t, accountId, amount, p.accountDefaults().CharacterSlots, maximum, idempotencyKey)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to add [%d] character slots to account [%d].", amount, accountId)
+			return Model{}, err
+		}
+		a, err := p.GetById(accountId)
+		if err != nil {
+			return Model{}, err
+		}
+		if replayed {
+			p.l.Debugf("Character slot grant [%s] for account [%d] already applied.", idempotencyKey, accountId)
+			return a, nil
+		}
 
-	if a.pin != input.pin && input.pin != "" {
-		p.l.Debugf("Updating PIN [%s] of account [%d].", input.pin, accountId)
-		modifiers = append(modifiers, updatePin(input.pin))
+		p.l.Infof("Added [%d] character slots to account [%d], now [%d].", amount, accountId, g.CharacterSlots)
+		_ = mb.Put(account2.EnvEventTopicStatus, characterSlotsChangedEventProvider(a.Id(), a.Name(), g.Previous, g.CharacterSlots, idempotencyKey))
+		return a, nil
 	}
-	if a.pic != input.pic && input.pic != "" {
-		p.l.Debugf("Updating PIC [%s] of account [%d].", input.pic, accountId)
-		modifiers = append(modifiers, updatePic(input.pic))
+}
+
+func (p *ProcessorImpl) ChangePasswordAndEmit(accountId uint32, oldPassword string, newPassword string) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.ChangePassword(buf)(accountId, oldPassword, newPassword)
+	})
+}
+
+// ChangePassword replaces the password of an account on behalf of the player, who must present the current password.
+func (p *ProcessorImpl) ChangePassword(mb *message.Buffer) func(accountId uint32, oldPassword string, newPassword string) error {
+	return func(accountId uint32, oldPassword string, newPassword string) error {
+		a, err := p.GetById(accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate account [%d] changing password.", accountId)
+			return err
+		}
+		var policy configuration.AttemptPolicy
+		tc, err := p.tenantConfiguration()
+		if err != nil {
+			p.l.WithError(err).Warnf("Error reading needed tenant configuration. Password entries will not be limited.")
+		} else {
+			policy = tc.LoginAttempts.Name
+		}
+		err = p.guardPassword(attempt.Policy(policy))(a, oldPassword)
+		if err != nil {
+			return err
+		}
+		return p.setPassword(mb)(a, newPassword, "")
 	}
-	if a.tos != input.tos && input.tos != false {
-		p.l.Debugf("Updating TOS [%t] of account [%d].", input.tos, accountId)
-		modifiers = append(modifiers, updateTos(input.tos))
+}
+
+// guardPassword limits incorrect passwords presented for an account, counting them against the same name key as failed
+// logins so that changing the password offers no way around the login lockout.
+func (p *ProcessorImpl) guardPassword(policy attempt.Policy) func(a Model, password string) error {
+	return func(a Model, password string) error {
+		key := attempt.Key{Tenant: p.t, Type: attempt.TypeName, Value: strings.ToLower(a.Name())}
+		if until, ok := attempt.Get().LockedUntil(key); ok {
+			return PasswordLockedError{Until: until}
+		}
+		if _, err := credential.Verify(a.Password(), password); err != nil {
+			p.l.Warnf("Account [%d] attempted to change password with an incorrect password.", a.Id())
+			if until, ok := attempt.Get().Fail(key, policy); ok {
+				p.l.Warnf("Password entries for account [%d] exceeded threshold. Locked out until [%s].", a.Id(), until.String())
+				return PasswordLockedError{Until: until}
+			}
+			return ErrPasswordMismatch
+		}
+		attempt.Get().Reset(key)
+		return nil
 	}
-	if a.gender != input.gender {
-		p.l.Debugf("Updating Gender [%d] of account [%d].", input.gender, accountId)
-		modifiers = append(modifiers, updateGender(input.gender))
+}
+
+func (p *ProcessorImpl) ResetPasswordAndEmit(accountId uint32, password string, issuer string) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.ResetPassword(buf)(accountId, password, issuer)
+	})
+}
+
+// ResetPassword administratively replaces the password of an account, without knowledge of the current one. The issuer
+// is required and reported in the password changed event, setting the reset apart from a change by the player.
+func (p *ProcessorImpl) ResetPassword(mb *message.Buffer) func(accountId uint32, password string, issuer string) error {
+	return func(accountId uint32, password string, issuer string) error {
+		if strings.TrimSpace(issuer) == "" {
+			return ErrIssuerRequired
+		}
+		a, err := p.GetById(accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate account [%d] having password reset.", accountId)
+			return err
+		}
+		p.l.Infof("Password of account [%d] is being reset by [%s].", accountId, issuer)
+		return p.setPassword(mb)(a, password, issuer)
 	}
+}
 
-	if len(modifiers) == 0 {
+func (p *ProcessorImpl) DeleteAndEmit(accountId uint32) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.Delete(buf)(accountId)
+	})
+}
+
+// Delete soft deletes an account, terminating its sessions. The account may be restored until the tenant deletion grace
+// period elapses, after which it is purged.
+func (p *ProcessorImpl) Delete(mb *message.Buffer) func(accountId uint32) error {
+	return func(accountId uint32) error {
+		a, err := p.GetById(accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate account [%d] being deleted.", accountId)
+			return err
+		}
+		if p.holdsSessions(a.Id()) {
+			err = p.Logout(mb)(uuid.Nil)(a.Id())(account2.SessionCommandIssuerInternal)
+			if err != nil {
+				p.l.WithError(err).Errorf("Unable to terminate sessions of account [%d] being deleted.", a.Id())
+			}
+		}
+		err = softDelete(p.db)(p.t, a.Id())
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to delete account [%d].", a.Id())
+			return err
+		}
+		d, err := model.Map(Make)(deletedEntityById(p.t, a.Id())(p.db))()
+		if err != nil {
+			return err
+		}
+
+		p.l.Infof("Deleted account [%d] [%s].", a.Id(), a.Name())
+		return mb.Put(account2.EnvEventTopicStatus, deletedEventProvider(a.Id(), a.Name(), d.DeletedAt(), d.DeletedAt().Add(p.deletionPolicy().GracePeriod)))
+	}
+}
+
+func (p *ProcessorImpl) RestoreAndEmit(accountId uint32) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.Restore(buf)(accountId)
+		return err
+	})
+	return result, err
+}
+
+// Restore undoes the deletion of an account within the deletion grace period. An account whose name was freed cannot be
+// restored once another account has taken the name.
+func (p *ProcessorImpl) Restore(mb *message.Buffer) func(accountId uint32) (Model, error) {
+	return func(accountId uint32) (Model, error) {
+		d, err := model.Map(Make)(deletedEntityById(p.t, accountId)(p.db))()
+		if err != nil {
+			return Model{}, err
+		}
+		if time.Now().After(d.DeletedAt().Add(p.deletionPolicy().GracePeriod)) {
+			return Model{}, ErrGracePeriodElapsed
+		}
+		if _, err = p.GetByName(d.Name()); err == nil {
+			return Model{}, ErrNameTaken
+		}
+
+		err = restore(p.db)(p.t, accountId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to restore account [%d].", accountId)
+			return Model{}, err
+		}
+		a, err := p.GetById(accountId)
+		if err != nil {
+			return Model{}, err
+		}
+
+		p.l.Infof("Restored account [%d] [%s].", a.Id(), a.Name())
+		_ = mb.Put(account2.EnvEventTopicStatus, restoredEventProvider()(a.Id(), a.Name()))
 		return a, nil
 	}
+}
 
-	err = update(p.db)(modifiers...)(p.t, accountId)
+// reservedBy provides the deleted account holding a name, when the tenant reserves the names of deleted accounts.
+func (p *ProcessorImpl) reservedBy(name string) (Model, bool) {
+	if !p.deletionPolicy().ReserveName {
+		return Model{}, false
+	}
+	return p.deletedBy(name)
+}
+
+// loginDeletedBy provides the deleted account a login by name is refused for, being one holding the name while no live
+// account does. This holds even when the tenant does not reserve the names of deleted accounts, so that a login is not
+// taken for a registration of the name anew.
+func (p *ProcessorImpl) loginDeletedBy(name string) (Model, bool) {
+	if _, err := p.GetByName(name); err == nil {
+		return Model{}, false
+	}
+	return p.deletedBy(name)
+}
+
+// deletedBy provides the most recently deleted account holding a name, until it is purged.
+func (p *ProcessorImpl) deletedBy(name string) (Model, bool) {
+	d, err := model.FirstProvider(model.SliceMap(Make)(deletedEntitiesByName(p.t, name)(p.db))(model.ParallelMap()), model.Filters[Model]())()
 	if err != nil {
-		p.l.WithError(err).Errorf("Unable to update account.")
-		return Model{}, err
+		return Model{}, false
 	}
+	return d, true
+}
 
-	return p.GetById(accountId)
+func (p *ProcessorImpl) SetPrivilegesAndEmit(accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.SetPrivileges(buf)(accountId, gmLevel, roles, issuer, reason)
+		return err
+	})
+	return result, err
 }
 
-func (p *ProcessorImpl) Login(mb *message.Buffer) func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) error {
-	return func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) error {
-		return func(accountId uint32) func(issuer string) error {
-			return func(issuer string) error {
-				a, err := p.GetById(accountId)
-				if err != nil {
-					return err
-				}
+// SetPrivileges replaces the GM level and roles of an account. It is reserved to administrators, so the issuer is
+// required and recorded alongside the reason in the privilege audit trail.
+func (p *ProcessorImpl) SetPrivileges(mb *message.Buffer) func(accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (Model, error) {
+	return func(accountId uint32, gmLevel byte, roles []string, issuer string, reason string) (Model, error) {
+		if strings.TrimSpace(issuer) == "" {
+			return Model{}, ErrIssuerRequired
+		}
+		roles, err := normalizeRoles(roles)
+		if err != nil {
+			return Model{}, err
+		}
 
-				ak := AccountKey{Tenant: p.t, AccountId: accountId}
-				sk := ServiceKey{SessionId: sessionId, Service: Service(issuer)}
-				err = Get().Login(ak, sk)
-				if err != nil {
-					return err
+		c, changed, err := setPrivileges(p.db)(p.t, accountId, gmLevel, roles, issuer, reason)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to change privileges of account [%d].", accountId)
+			return Model{}, err
+		}
+		a, err := p.GetById(accountId)
+		if err != nil {
+			return Model{}, err
+		}
+		if !changed {
+			return a, nil
+		}
+
+		pc, err := MakePrivilegeChange(c)
+		if err != nil {
+			return Model{}, err
+		}
+		p.l.Infof("Privileges of account [%d] changed by [%s] to GM level [%d] and roles [%s].", accountId, issuer, gmLevel, c.Roles)
+		_ = mb.Put(account2.EnvEventTopicStatus, privilegesChangedEventProvider(a.Name(), pc))
+		return a, nil
+	}
+}
+
+func (p *ProcessorImpl) GetPrivilegeChanges(accountId uint32, offset int, limit int) ([]PrivilegeChange, error) {
+	return p.PrivilegeChangesProvider(accountId, offset, limit)()
+}
+
+// PrivilegeChangesProvider provides a window of the privilege audit trail of an account, most recent first.
+func (p *ProcessorImpl) PrivilegeChangesProvider(accountId uint32, offset int, limit int) model.Provider[[]PrivilegeChange] {
+	return model.SliceMap(MakePrivilegeChange)(privilegeChangeEntitiesByAccountId(p.t, accountId, offset, limit)(p.db))(model.ParallelMap())
+}
+
+func (p *ProcessorImpl) CountPrivilegeChanges(accountId uint32) (int64, error) {
+	return countPrivilegeChangesByAccountId(p.t, accountId)(p.db)()
+}
+
+// setPassword stores a new password hashed with the tenant policy, bumps the credential version and terminates any
+// session established with the previous password.
+func (p *ProcessorImpl) setPassword(mb *message.Buffer) func(a Model, password string, issuer string) error {
+	return func(a Model, password string, issuer string) error {
+		if err := requirementsError(validatePassword(p.passwordRequirements(), password)); err != nil {
+			return err
+		}
+		hashPass, err := credential.Hash(password, p.passwordPolicy())
+		if err != nil {
+			p.l.WithError(err).Errorf("Error generating hash when changing password of account [%d].", a.Id())
+			return err
+		}
+		err = updateCredential(p.db)(p.t, a.Id(), hashPass)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to change password of account [%d].", a.Id())
+			return err
+		}
+		if p.holdsSessions(a.Id()) {
+			err = p.Logout(mb)(uuid.Nil)(a.Id())(account2.SessionCommandIssuerInternal)
+			if err != nil {
+				p.l.WithError(err).Errorf("Unable to terminate sessions of account [%d] after password change.", a.Id())
+			}
+		}
+		p.l.Infof("Changed password of account [%d].", a.Id())
+		return mb.Put(account2.EnvEventTopicStatus, passwordChangedEventProvider(a.Id(), a.Name(), issuer))
+	}
+}
+
+func (p *ProcessorImpl) Login(mb *message.Buffer) func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) func(instanceId uuid.UUID) error {
+	return func(sessionId uuid.UUID) func(accountId uint32) func(issuer string) func(instanceId uuid.UUID) error {
+		return func(accountId uint32) func(issuer string) func(instanceId uuid.UUID) error {
+			return func(issuer string) func(instanceId uuid.UUID) error {
+				return func(instanceId uuid.UUID) error {
+					a, err := p.GetById(accountId)
+					if err != nil {
+						return err
+					}
+
+					ak := AccountKey{Tenant: p.t, AccountId: accountId}
+					sk := ServiceKey{SessionId: sessionId, Service: Service(issuer)}
+					before := Get().MaximalState(ak)
+					err = Get().Login(ak, sk, instanceId)
+					if err != nil {
+						return err
+					}
+					// Sessions held alongside the game leave the account as it was.
+					if Get().MaximalState(ak) == before {
+						return nil
+					}
+					p.l.Debugf("State transition triggered a login.")
+					return mb.Put(account2.EnvEventTopicStatus, loggedInEventProvider(a))
 				}
-				p.l.Debugf("State transition triggered a login.")
-				return mb.Put(account2.EnvEventTopicStatus, loggedInEventProvider()(a.Id(), a.Name()))
 			}
 		}
 	}
@@ -233,17 +877,23 @@ func (p *ProcessorImpl) Logout(mb *message.Buffer) func(sessionId uuid.UUID) fun
 					return err
 				}
 
+				ak := AccountKey{Tenant: p.t, AccountId: accountId}
+				before := Get().MaximalState(ak)
 				if sessionId == uuid.Nil {
-					ok := Get().Terminate(AccountKey{Tenant: p.t, AccountId: accountId})
+					ok := Get().Terminate(ak)
 					if !ok {
 						return errors.New("error while logging out")
 					}
 				} else {
-					ok := Get().Logout(AccountKey{Tenant: p.t, AccountId: accountId}, ServiceKey{SessionId: sessionId, Service: Service(issuer)})
+					ok := Get().Logout(ak, ServiceKey{SessionId: sessionId, Service: Service(issuer)})
 					if !ok {
 						return errors.New("error while logging out")
 					}
 				}
+				// Sessions held alongside the game leave the account as it was.
+				if Get().MaximalState(ak) == before {
+					return nil
+				}
 				p.l.Debugf("Logging out [%d] for [%s] via session [%s].", accountId, issuer, sessionId.String())
 				return mb.Put(account2.EnvEventTopicStatus, loggedOutEventProvider()(a.Id(), a.Name()))
 			}
@@ -251,12 +901,87 @@ func (p *ProcessorImpl) Logout(mb *message.Buffer) func(sessionId uuid.UUID) fun
 	}
 }
 
+// Heartbeat marks the service session of an account as still in use, so it is not logged out as idle.
+func (p *ProcessorImpl) Heartbeat(sessionId uuid.UUID, accountId uint32, issuer string) error {
+	return Get().Heartbeat(AccountKey{Tenant: p.t, AccountId: accountId}, ServiceKey{SessionId: sessionId, Service: Service(issuer)})
+}
+
+func (p *ProcessorImpl) TerminateInstanceAndEmit(instanceId uuid.UUID) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.TerminateInstance(buf)(instanceId)
+	})
+}
+
+// TerminateInstance logs out the sessions of the tenant held by a service instance which has started afresh or stopped,
+// and so no longer holds them. Sessions transitioning to another instance are left to complete. Only accounts no longer
+// logged in as a result are reported logged out.
+func (p *ProcessorImpl) TerminateInstance(mb *message.Buffer) func(instanceId uuid.UUID) error {
+	return func(instanceId uuid.UUID) error {
+		if instanceId == uuid.Nil {
+			return ErrInvalidInstance
+		}
+
+		ids := Get().TerminateInstance(p.t, instanceId)
+		p.l.Infof("Terminated sessions of [%d] accounts held by instance [%s].", len(ids), instanceId.String())
+		for _, id := range ids {
+			a, err := p.GetById(id)
+			if err != nil {
+				p.l.WithError(err).Errorf("Unable to locate account [%d] to log out.", id)
+				continue
+			}
+			err = mb.Put(account2.EnvEventTopicStatus, loggedOutEventProvider()(a.Id(), a.Name()))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (p *ProcessorImpl) ExpireIdleAndEmit(accountId uint32, timeouts map[Service]time.Duration) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.ExpireIdle(buf)(accountId, timeouts)
+	})
+}
+
+// ExpireIdle logs out the sessions of an account which have gone without a heartbeat for longer than the idle timeout of
+// their service. The account is reported logged out only once no session logging it in remains.
+func (p *ProcessorImpl) ExpireIdle(mb *message.Buffer) func(accountId uint32, timeouts map[Service]time.Duration) error {
+	return func(accountId uint32, timeouts map[Service]time.Duration) error {
+		a, err := p.GetById(accountId)
+		if err != nil {
+			return err
+		}
+
+		if !Get().ExpireIdle(AccountKey{Tenant: p.t, AccountId: accountId}, timeouts) {
+			return nil
+		}
+		p.l.Infof("Logging out idle sessions of account [%d] [%s].", a.Id(), a.Name())
+		return mb.Put(account2.EnvEventTopicStatus, loggedOutEventProvider()(a.Id(), a.Name()))
+	}
+}
+
+// holdsSessions reports whether the account holds a session with any service, including those which do not log it in.
+func (p *ProcessorImpl) holdsSessions(accountId uint32) bool {
+	return len(Get().GetStates(AccountKey{Tenant: p.t, AccountId: accountId})) > 0
+}
+
 func Teardown(l logrus.FieldLogger, db *gorm.DB) func() {
 	return func() {
+		if _, ok := Get().(*DatabaseRegistry); ok {
+			l.Infof("Sessions are retained by the database registry, so accounts remain logged in through shutdown.")
+			return
+		}
+		err := Snapshot(l, db)
+		if err == nil {
+			return
+		}
+		l.WithError(err).Errorf("Unable to record sessions for restoration. Logging out all accounts.")
+
 		sctx, span := otel.GetTracerProvider().Tracer("atlas-account").Start(context.Background(), "teardown")
 		defer span.End()
 
-		err := model.ForEachSlice(model.SliceMap(model.Always(model.Curry(tenant.WithContext)(sctx)))(allTenants)(model.ParallelMap()), teardownTenant(l)(db))
+		err = model.ForEachSlice(model.SliceMap(model.Always(model.Curry(tenant.WithContext)(sctx)))(allTenants)(model.ParallelMap()), teardownTenant(l)(db))
 		if err != nil {
 			l.WithError(err).Errorf("Error tearing down ")
 		}
@@ -278,71 +1003,149 @@ func teardownTenant(l logrus.FieldLogger) func(db *gorm.DB) model.Operator[conte
 	}
 }
 
-func (p *ProcessorImpl) AttemptLoginAndEmit(sessionId uuid.UUID, name string, password string) error {
+func (p *ProcessorImpl) AttemptLoginAndEmit(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, name string, password string, ipAddress string, macAddress string, hwid string) error {
 	return message.Emit(p.p)(func(buf *message.Buffer) error {
-		return p.AttemptLogin(buf)(sessionId, name, password)
+		return p.AttemptLogin(buf)(sessionId, issuer, instanceId, name, password, ipAddress, macAddress, hwid)
 	})
 }
 
-func (p *ProcessorImpl) AttemptLogin(mb *message.Buffer) func(sessionId uuid.UUID, name string, password string) error {
-	return func(sessionId uuid.UUID, name string, password string) error {
+// AttemptLogin authenticates a session of the issuing service and logs the account in to it. Accounts already in the
+// game may only log in to services held alongside it. Services which are unknown, or entered only by transition, are
+// refused before credentials are considered.
+func (p *ProcessorImpl) AttemptLogin(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, name string, password string, ipAddress string, macAddress string, hwid string) error {
+	return func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, name string, password string, ipAddress string, macAddress string, hwid string) error {
 		p.l.Debugf("Attemting login for [%s].", name)
-		if checkLoginAttempts(sessionId) > 4 {
-			p.l.Warnf("Session [%s] has attempted to log into (or create) an account too many times.", sessionId.String())
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, 0, TooManyAttempts))
+		fail := func(accountId uint32, code string, reason byte, until uint64) error {
+			// Errors of the service itself say nothing of the player, and are left out of their login history.
+			if code != SystemError {
+				p.recordLoginFailure(accountId, name, sessionId, issuer, ipAddress, code)
+			}
+			return mb.Put(account2.EnvEventSessionStatusTopic, errorDetailStatusProvider(sessionId, accountId, code, reason, until))
+		}
+
+		services := GetServices()
+		if _, ok := services[Service(issuer)]; !ok || services.enteredByTransition(Service(issuer)) {
+			p.l.Warnf("Session [%s] attempted to log in through [%s], which does not accept logins.", sessionId.String(), issuer)
+			return fail(0, SystemError, 0, 0)
 		}
 
 		c, err := configuration.Get()
 		if err != nil {
 			p.l.WithError(err).Errorf("Error reading needed configuration.")
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, 0, SystemError))
+			return fail(0, SystemError, 0, 0)
+		}
+		tc, err := c.ForTenant(p.t.Id())
+		if err != nil {
+			p.l.WithError(err).Errorf("Error reading needed tenant configuration.")
+			return fail(0, SystemError, 0, 0)
+		}
+
+		las := loginAttempts(p.t, tc.LoginAttempts, sessionId, name, ipAddress)
+		if until, ok := checkLoginAttempts(las); ok {
+			p.l.Warnf("Session [%s] has attempted to log into (or create) an account too many times.", sessionId.String())
+			return fail(0, TooManyAttempts, 0, filetime.FromTime(until))
+		}
+
+		bp := ban.NewProcessor(p.l, p.ctx, p.db)
+		cb, err := bp.CheckClient(mb)(sessionId, name, ipAddress, macAddress, hwid)
+		if err == nil {
+			return fail(0, BlockedClient, cb.Reason(), filetime.FromTime(cb.ExpiresAt()))
+		}
+		if !errors.Is(err, ban.ErrNotBanned) {
+			p.l.WithError(err).Errorf("Unable to determine if session [%s] is banned.", sessionId.String())
+			return fail(0, SystemError, 0, 0)
+		}
+
+		if d, ok := p.loginDeletedBy(name); ok {
+			p.l.Infof("Deleted account [%d] attempted to login.", d.Id())
+			return fail(d.Id(), DeletedOrBlocked, 0, 0)
 		}
 
 		a, err := p.GetOrCreate(mb)(name, password, c.AutomaticRegister)
 		if err != nil && !c.AutomaticRegister {
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, 0, NotRegistered))
+			p.failLoginAttempt(las)
+			return fail(0, NotRegistered, 0, 0)
+		}
+		if errors.Is(err, ErrInvalidName) {
+			return fail(0, InvalidName, 0, 0)
+		}
+		if errors.Is(err, ErrInvalidPassword) {
+			return fail(0, InvalidPassword, 0, 0)
 		}
 		if err != nil {
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, 0, SystemError))
+			return fail(0, SystemError, 0, 0)
 		}
 
-		if a.Banned() {
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), DeletedOrBlocked))
+		b, err := bp.GetActiveByAccountId(a.Id())
+		if err == nil {
+			p.l.Infof("Account [%d] attempted to login while banned by [%d].", a.Id(), b.Id())
+			return fail(a.Id(), DeletedOrBlocked, b.Reason(), filetime.FromTime(b.ExpiresAt()))
+		}
+		if !errors.Is(err, ban.ErrNotBanned) {
+			p.l.WithError(err).Errorf("Unable to determine if account [%d] is banned.", a.Id())
+			return fail(a.Id(), SystemError, 0, 0)
 		}
 
-		// TODO implement ip, mac, and temporary banning practices
-
-		if a.State() != StateNotLoggedIn {
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), AlreadyLoggedIn))
-		} else if a.Password()[0] == uint8('$') && a.Password()[1] == uint8('2') && bcrypt.CompareHashAndPassword([]byte(a.Password()), []byte(password)) == nil {
-			// TODO implement tos tracking
-		} else {
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), IncorrectPassword))
+		if a.State() != StateNotLoggedIn && GetServices().exclusive(Service(issuer)) {
+			return fail(a.Id(), AlreadyLoggedIn, 0, 0)
+		}
+		f, err := credential.Verify(a.Password(), password)
+		if err != nil {
+			if errors.Is(err, credential.ErrUnknownFormat) {
+				p.l.Warnf("Password of account [%d] is stored in an unrecognized format.", a.Id())
+			}
+			p.failLoginAttempt(las)
+			return fail(a.Id(), IncorrectPassword, 0, 0)
+		}
+		if hp := hashPolicy(tc.PasswordHashing); credential.NeedsRehash(a.Password(), hp) {
+			p.rehashPassword(a.Id(), f, hp, password)
 		}
 
-		err = p.Login(mb)(sessionId)(a.Id())(ServiceLogin)
+		err = p.Login(mb)(sessionId)(a.Id())(issuer)(instanceId)
 		if err != nil {
 			p.l.WithError(err).Errorf("Unable to record login.")
-			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), SystemError))
+			return fail(a.Id(), SystemError, 0, 0)
 		}
 
 		p.l.Debugf("Login successful for [%s].", name)
+		resetLoginAttempts(las)
+		p.recordLoginSuccess(a.Id(), name, sessionId, issuer, ipAddress)
 
 		if !a.TOS() && p.t.Region() != "JMS" {
 			return mb.Put(account2.EnvEventSessionStatusTopic, requestLicenseAgreementStatusProvider(sessionId, a.Id()))
 		}
-		return mb.Put(account2.EnvEventSessionStatusTopic, createdStatusProvider(sessionId, a.Id()))
+		return mb.Put(account2.EnvEventSessionStatusTopic, createdStatusProvider(sessionId, a))
 	}
 }
 
-func (p *ProcessorImpl) ProgressStateAndEmit(sessionId uuid.UUID, issuer string, accountId uint32, state State, params interface{}) error {
+// recordLoginSuccess stamps the last login of the account and appends to its login history. Failure to do so is not
+// fatal to the login.
+func (p *ProcessorImpl) recordLoginSuccess(accountId uint32, name string, sessionId uuid.UUID, issuer string, ipAddress string) {
+	err := update(p.db)(updateLastLogin(time.Now(), ipAddress))(p.t, accountId)
+	if err != nil {
+		p.l.WithError(err).Errorf("Unable to record last login of account [%d].", accountId)
+	}
+	_, err = login.NewProcessor(p.l, p.ctx, p.db).RecordSuccess(accountId, name, sessionId, issuer, ipAddress)
+	if err != nil {
+		p.l.WithError(err).Errorf("Unable to record login history of account [%d].", accountId)
+	}
+}
+
+func (p *ProcessorImpl) recordLoginFailure(accountId uint32, name string, sessionId uuid.UUID, issuer string, ipAddress string, code string) {
+	_, err := login.NewProcessor(p.l, p.ctx, p.db).RecordFailure(accountId, name, sessionId, issuer, ipAddress, code)
+	if err != nil {
+		p.l.WithError(err).Errorf("Unable to record failed login of [%s].", name)
+	}
+}
+
+func (p *ProcessorImpl) ProgressStateAndEmit(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, accountId uint32, state State, params interface{}) error {
 	return message.Emit(p.p)(func(buf *message.Buffer) error {
-		return p.ProgressState(buf)(sessionId, issuer, accountId, state, params)
+		return p.ProgressState(buf)(sessionId, issuer, instanceId, accountId, state, params)
 	})
 }
 
-func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, accountId uint32, state State, params interface{}) error {
-	return func(sessionId uuid.UUID, issuer string, accountId uint32, state State, params interface{}) error {
+func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, accountId uint32, state State, params interface{}) error {
+	return func(sessionId uuid.UUID, issuer string, instanceId uuid.UUID, accountId uint32, state State, params interface{}) error {
 		a, err := p.GetById(accountId)
 		if err != nil {
 			p.l.WithError(err).Errorf("Unable to locate account a session is being created for.")
@@ -353,7 +1156,12 @@ func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UU
 		for k, v := range Get().GetStates(AccountKey{Tenant: p.t, AccountId: accountId}) {
 			p.l.Debugf("Has state [%d] for [%s] via session [%s].", v.State, k.Service, k.SessionId.String())
 		}
-		if a.State() == StateNotLoggedIn {
+		// Only sessions already held may progress, save those beginning by taking over a transitioning session, which the
+		// registry verifies. Others begin by logging in.
+		sk := ServiceKey{SessionId: sessionId, Service: Service(issuer)}
+		_, held := Get().GetStates(AccountKey{Tenant: p.t, AccountId: accountId})[sk]
+		if !held && (state != StateLoggedIn || !GetServices().enteredByTransition(sk.Service)) {
+			p.l.Warnf("Session [%s] of [%s] attempted to progress account [%d] to state [%d] without being held.", sessionId.String(), issuer, accountId, state)
 			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), SystemError))
 		}
 		if state == StateNotLoggedIn {
@@ -365,7 +1173,7 @@ func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UU
 			return mb.Put(account2.EnvEventSessionStatusTopic, stateChangedStatusProvider(sessionId, a.Id(), StateNotLoggedIn, params))
 		}
 		if state == StateLoggedIn {
-			err = p.Login(mb)(sessionId)(accountId)(issuer)
+			err = p.Login(mb)(sessionId)(accountId)(issuer)(instanceId)
 			if err != nil {
 				p.l.WithError(err).Errorf("Unable to login account.")
 				return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, a.Id(), SystemError))
@@ -373,7 +1181,7 @@ func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UU
 			return mb.Put(account2.EnvEventSessionStatusTopic, stateChangedStatusProvider(sessionId, a.Id(), StateLoggedIn, params))
 		}
 		if state == StateTransition {
-			err = Get().Transition(AccountKey{Tenant: p.t, AccountId: accountId}, ServiceKey{SessionId: sessionId, Service: Service(issuer)})
+			err = Get().Transition(AccountKey{Tenant: p.t, AccountId: accountId}, sk)
 			if err == nil {
 				p.l.Debugf("State transition triggered a transition.")
 			}
@@ -383,6 +1191,320 @@ func (p *ProcessorImpl) ProgressState(mb *message.Buffer) func(sessionId uuid.UU
 	}
 }
 
-func checkLoginAttempts(sessionId uuid.UUID) byte {
-	return 0
+// secret describes a secondary password held by an account.
+type secret struct {
+	name        string
+	stored      func(Model) string
+	updater     func(string) EntityUpdateFunction
+	attemptType attempt.Type
+	attempts    func(configuration.SecondaryPasswordAttempts) configuration.AttemptPolicy
+	verified    string
+	incorrect   string
+	notSet      string
+	locked      string
+}
+
+var pinSecret = secret{
+	name:        "PIN",
+	stored:      Model.Pin,
+	updater:     updatePin,
+	attemptType: attempt.TypePin,
+	attempts: func(c configuration.SecondaryPasswordAttempts) configuration.AttemptPolicy {
+		return c.Pin
+	},
+	verified:  account2.SessionEventStatusTypePinVerified,
+	incorrect: IncorrectPin,
+	notSet:    PinNotSet,
+	locked:    PinLocked,
+}
+
+var picSecret = secret{
+	name:        "PIC",
+	stored:      Model.Pic,
+	updater:     updatePic,
+	attemptType: attempt.TypePic,
+	attempts: func(c configuration.SecondaryPasswordAttempts) configuration.AttemptPolicy {
+		return c.Pic
+	},
+	verified:  account2.SessionEventStatusTypePicVerified,
+	incorrect: IncorrectPic,
+	notSet:    PicNotSet,
+	locked:    PicLocked,
+}
+
+func (p *ProcessorImpl) VerifyPin(accountId uint32, pin string) error {
+	return p.verifySecret(pinSecret)(accountId, pin)
+}
+
+func (p *ProcessorImpl) VerifyPic(accountId uint32, pic string) error {
+	return p.verifySecret(picSecret)(accountId, pic)
+}
+
+func (p *ProcessorImpl) AttemptPinAndEmit(sessionId uuid.UUID, accountId uint32, pin string) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.AttemptPin(buf)(sessionId, accountId, pin)
+	})
+}
+
+func (p *ProcessorImpl) AttemptPin(mb *message.Buffer) func(sessionId uuid.UUID, accountId uint32, pin string) error {
+	return p.attemptSecret(mb)(pinSecret)
+}
+
+func (p *ProcessorImpl) AttemptPicAndEmit(sessionId uuid.UUID, accountId uint32, pic string) error {
+	return message.Emit(p.p)(func(buf *message.Buffer) error {
+		return p.AttemptPic(buf)(sessionId, accountId, pic)
+	})
+}
+
+func (p *ProcessorImpl) AttemptPic(mb *message.Buffer) func(sessionId uuid.UUID, accountId uint32, pic string) error {
+	return p.attemptSecret(mb)(picSecret)
+}
+
+func (p *ProcessorImpl) attemptSecret(mb *message.Buffer) func(s secret) func(sessionId uuid.UUID, accountId uint32, value string) error {
+	return func(s secret) func(sessionId uuid.UUID, accountId uint32, value string) error {
+		return func(sessionId uuid.UUID, accountId uint32, value string) error {
+			err := p.verifySecret(s)(accountId, value)
+			if err == nil {
+				return mb.Put(account2.EnvEventSessionStatusTopic, secretVerifiedStatusProvider(sessionId, accountId, s.verified))
+			}
+			if errors.Is(err, ErrSecretNotSet) {
+				return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, accountId, s.notSet))
+			}
+			if errors.Is(err, ErrSecretMismatch) {
+				return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, accountId, s.incorrect))
+			}
+			var le SecretLockedError
+			if errors.As(err, &le) {
+				return mb.Put(account2.EnvEventSessionStatusTopic, errorDetailStatusProvider(sessionId, accountId, s.locked, 0, filetime.FromTime(le.Until)))
+			}
+			p.l.WithError(err).Errorf("Unable to verify %s of account [%d].", s.name, accountId)
+			return mb.Put(account2.EnvEventSessionStatusTopic, errorStatusProvider(sessionId, accountId, SystemError))
+		}
+	}
+}
+
+func (p *ProcessorImpl) verifySecret(s secret) func(accountId uint32, value string) error {
+	return func(accountId uint32, value string) error {
+		var policy configuration.AttemptPolicy
+		tc, err := p.tenantConfiguration()
+		if err != nil {
+			p.l.WithError(err).Warnf("Error reading needed tenant configuration. %s entries will not be limited.", s.name)
+		} else {
+			policy = s.attempts(tc.SecondaryPasswordAttempts)
+		}
+		return p.guardSecret(s, attempt.Policy(policy))(accountId, value)
+	}
+}
+
+// guardSecret limits failed entries of a secondary password per account. Entries are refused while the secondary
+// password is locked out, and the mismatch which reaches the policy threshold reports the expiry of the new lockout.
+func (p *ProcessorImpl) guardSecret(s secret, policy attempt.Policy) func(accountId uint32, value string) error {
+	return func(accountId uint32, value string) error {
+		key := attempt.Key{Tenant: p.t, Type: s.attemptType, Value: strconv.Itoa(int(accountId))}
+		if until, ok := attempt.Get().LockedUntil(key); ok {
+			return SecretLockedError{Until: until}
+		}
+
+		err := p.checkSecret(s)(accountId, value)
+		if errors.Is(err, ErrSecretMismatch) {
+			if until, ok := attempt.Get().Fail(key, policy); ok {
+				p.l.Warnf("%s entries for account [%d] exceeded threshold. Locked out until [%s].", s.name, accountId, until.String())
+				return SecretLockedError{Until: until}
+			}
+			return err
+		}
+		if err == nil {
+			attempt.Get().Reset(key)
+		}
+		return err
+	}
+}
+
+// checkSecret checks a secondary password against the stored hash. Secondary passwords persisted before hashing was
+// introduced are compared as plaintext, and hashed once they have been verified.
+func (p *ProcessorImpl) checkSecret(s secret) func(accountId uint32, value string) error {
+	return func(accountId uint32, value string) error {
+		a, err := p.GetById(accountId)
+		if err != nil {
+			return err
+		}
+		stored := s.stored(a)
+		if stored == "" {
+			return ErrSecretNotSet
+		}
+
+		policy := p.passwordPolicy()
+		_, err = credential.Verify(stored, value)
+		if errors.Is(err, credential.ErrUnknownFormat) {
+			if subtle.ConstantTimeCompare([]byte(stored), []byte(value)) != 1 {
+				return ErrSecretMismatch
+			}
+			p.rehashSecret(s, accountId, policy, value)
+			return nil
+		}
+		if err != nil {
+			return ErrSecretMismatch
+		}
+		if credential.NeedsRehash(stored, policy) {
+			p.rehashSecret(s, accountId, policy, value)
+		}
+		return nil
+	}
+}
+
+func (p *ProcessorImpl) rehashSecret(s secret, accountId uint32, policy credential.Policy, value string) {
+	hashed, err := credential.Hash(value, policy)
+	if err != nil {
+		p.l.WithError(err).Errorf("Error generating hash when upgrading %s of account [%d].", s.name, accountId)
+		return
+	}
+	err = update(p.db)(s.updater(hashed))(p.t, accountId)
+	if err != nil {
+		p.l.WithError(err).Errorf("Unable to upgrade %s of account [%d].", s.name, accountId)
+		return
+	}
+	p.l.Infof("Upgraded %s of account [%d].", s.name, accountId)
+}
+
+// rehashPassword replaces a password stored by a legacy or outdated algorithm, now that the plaintext is known. Failure
+// is not fatal to the login, as the stored hash remains verifiable.
+func (p *ProcessorImpl) rehashPassword(accountId uint32, format credential.Format, policy credential.Policy, password string) {
+	hashPass, err := credential.Hash(password, policy)
+	if err != nil {
+		p.l.WithError(err).Errorf("Error generating hash when upgrading password of account [%d].", accountId)
+		return
+	}
+	err = update(p.db)(updatePassword(hashPass))(p.t, accountId)
+	if err != nil {
+		p.l.WithError(err).Errorf("Unable to upgrade password of account [%d].", accountId)
+		return
+	}
+	p.l.Infof("Upgraded password of account [%d] from [%s].", accountId, format)
+}
+
+// passwordPolicy resolves the hashing policy of the tenant. Should configuration be unavailable, the algorithm defaults
+// are used, as any hash produced remains verifiable and will be upgraded on login once configuration is restored.
+func (p *ProcessorImpl) passwordPolicy() credential.Policy {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using default password hashing.")
+		return credential.Policy{}
+	}
+	return hashPolicy(tc.PasswordHashing)
+}
+
+// accountDefaults resolves the account defaults of the tenant. Should configuration be unavailable, the region derived
+// defaults are used.
+func (p *ProcessorImpl) accountDefaults() configuration.AccountDefaults {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using region account defaults.")
+		return accountDefaults(p.t, configuration.AccountDefaults{})
+	}
+	return accountDefaults(p.t, tc.AccountDefaults)
+}
+
+func (p *ProcessorImpl) characterSlotMaximum() int16 {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using default character slot maximum.")
+		return defaultMaximumCharacterSlots
+	}
+	return characterSlotMaximum(tc.CharacterSlots)
+}
+
+// deletionPolicy provides the deletion policy of the tenant. When the configuration cannot be read, the names of deleted
+// accounts are kept reserved, as the purge task then leaves them in place too.
+func (p *ProcessorImpl) deletionPolicy() configuration.Deletion {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using default deletion policy, reserving names.")
+		return deletionPolicy(configuration.Deletion{ReserveName: true})
+	}
+	return deletionPolicy(tc.Deletion)
+}
+
+func (p *ProcessorImpl) nameRequirements() configuration.NameRequirements {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using default name requirements.")
+		return nameRequirements(configuration.NameRequirements{})
+	}
+	return nameRequirements(tc.NameRequirements)
+}
+
+func (p *ProcessorImpl) passwordRequirements() configuration.PasswordRequirements {
+	tc, err := p.tenantConfiguration()
+	if err != nil {
+		p.l.WithError(err).Warnf("Error reading needed tenant configuration. Using default password requirements.")
+		return passwordRequirements(configuration.PasswordRequirements{})
+	}
+	return passwordRequirements(tc.PasswordRequirements)
+}
+
+func (p *ProcessorImpl) tenantConfiguration() (configuration.TenantConfiguration, error) {
+	c, err := configuration.Get()
+	if err != nil {
+		return configuration.TenantConfiguration{}, err
+	}
+	return c.ForTenant(p.t.Id())
+}
+
+func hashPolicy(c configuration.PasswordHashing) credential.Policy {
+	return credential.Policy{
+		Algorithm: credential.Format(c.Algorithm),
+		Bcrypt:    credential.BcryptParameters(c.Bcrypt),
+		Argon2id:  credential.Argon2idParameters(c.Argon2id),
+	}
+}
+
+type loginAttempt struct {
+	key    attempt.Key
+	policy attempt.Policy
+}
+
+// loginAttempts produces the keys a login attempt is tracked against.
+func loginAttempts(t tenant.Model, c configuration.LoginAttempts, sessionId uuid.UUID, name string, ipAddress string) []loginAttempt {
+	las := []loginAttempt{
+		{key: attempt.Key{Tenant: t, Type: attempt.TypeSession, Value: sessionId.String()}, policy: attempt.Policy(c.Session)},
+		{key: attempt.Key{Tenant: t, Type: attempt.TypeName, Value: strings.ToLower(name)}, policy: attempt.Policy(c.Name)},
+	}
+	if ipAddress != "" {
+		las = append(las, loginAttempt{key: attempt.Key{Tenant: t, Type: attempt.TypeIP, Value: ipAddress}, policy: attempt.Policy(c.IP)})
+	}
+	return las
+}
+
+// checkLoginAttempts reports whether any of the keys are locked out, and until when the longest lockout lasts.
+func checkLoginAttempts(las []loginAttempt) (time.Time, bool) {
+	var until time.Time
+	var locked = false
+	for _, la := range las {
+		if lu, ok := attempt.Get().LockedUntil(la.key); ok {
+			locked = true
+			if lu.After(until) {
+				until = lu
+			}
+		}
+	}
+	return until, locked
+}
+
+func (p *ProcessorImpl) failLoginAttempt(las []loginAttempt) {
+	for _, la := range las {
+		if until, ok := attempt.Get().Fail(la.key, la.policy); ok {
+			p.l.Warnf("Login attempts for [%s] [%s] exceeded threshold. Locked out until [%s].", la.key.Type, la.key.Value, until.String())
+		}
+	}
+}
+
+// resetLoginAttempts clears the session and name counters after a successful login. The IP counter is left to expire
+// on its own, so that a single valid account cannot be used to reset the counter of an address spraying others.
+func resetLoginAttempts(las []loginAttempt) {
+	for _, la := range las {
+		if la.key.Type == attempt.TypeIP {
+			continue
+		}
+		attempt.Get().Reset(la.key)
+	}
 }
@@ -1,12 +1,26 @@
 package account
 
 import (
+	"atlas-account/attempt"
+	"atlas-account/ban"
+	"atlas-account/configuration"
+	"atlas-account/credential"
 	"atlas-account/kafka/message"
+	account2 "atlas-account/kafka/message/account"
+	"atlas-account/login"
+	"atlas-account/wallet"
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus/hooks/test"
 	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreate(t *testing.T) {
@@ -33,3 +47,1195 @@ func TestCreate(t *testing.T) {
 		t.Fatalf("Password does not match")
 	}
 }
+
+func TestRehashPassword(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	testPassword := "password"
+	a, err := create(db)(st, "name", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	p.rehashPassword(a.Id(), credential.FormatSHA1, credential.Policy{}, testPassword)
+
+	m, err := p.GetById(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if credential.NeedsRehash(m.Password(), credential.Policy{}) {
+		t.Fatalf("Password should have been upgraded.")
+	}
+	if _, err = credential.Verify(m.Password(), testPassword); err != nil {
+		t.Fatalf("Upgraded password does not match")
+	}
+}
+
+func TestVerifyPin(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db)
+	if err = p.VerifyPin(a.Id(), "1234"); !errors.Is(err, ErrSecretNotSet) {
+		t.Fatalf("Expected PIN to be unset, got %v", err)
+	}
+
+	m, err := p.Update(message.NewBuffer())(a.Id(), Model{pin: "1234"})
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+	if !m.PinSet() || m.Pin() == "1234" {
+		t.Fatalf("PIN should be stored hashed.")
+	}
+	rm, _ := Transform(m)
+	if rm.Pin != "" || !rm.PinSet {
+		t.Fatalf("REST model should expose only that the PIN is set.")
+	}
+
+	if err = p.VerifyPin(a.Id(), "1234"); err != nil {
+		t.Fatalf("Expected PIN to verify, got %v", err)
+	}
+	if err = p.VerifyPin(a.Id(), "4321"); !errors.Is(err, ErrSecretMismatch) {
+		t.Fatalf("Expected PIN mismatch, got %v", err)
+	}
+}
+
+func TestVerifyLegacyPic(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	err = update(db)(updatePic("123456"))(st, a.Id())
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db)
+	if err = p.VerifyPic(a.Id(), "654321"); !errors.Is(err, ErrSecretMismatch) {
+		t.Fatalf("Expected PIC mismatch, got %v", err)
+	}
+	if err = p.VerifyPic(a.Id(), "123456"); err != nil {
+		t.Fatalf("Expected plaintext PIC to verify, got %v", err)
+	}
+
+	m, err := p.GetById(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if _, err = credential.Verify(m.Pic(), "123456"); err != nil {
+		t.Fatalf("PIC should have been hashed once verified, got %v", err)
+	}
+}
+
+func TestSecretLockout(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	err = update(db)(updatePin("1234"))(st, a.Id())
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	policy := attempt.Policy{Threshold: 2, Window: time.Minute, Cooldown: time.Minute}
+
+	if err = p.guardSecret(pinSecret, policy)(a.Id(), "0000"); !errors.Is(err, ErrSecretMismatch) {
+		t.Fatalf("Expected PIN mismatch, got %v", err)
+	}
+	var le SecretLockedError
+	if err = p.guardSecret(pinSecret, policy)(a.Id(), "0000"); !errors.As(err, &le) {
+		t.Fatalf("Expected PIN to lock on reaching threshold, got %v", err)
+	}
+	if !le.Until.After(time.Now()) {
+		t.Fatalf("Lockout expiry should be in the future.")
+	}
+	if err = p.guardSecret(pinSecret, policy)(a.Id(), "1234"); !errors.As(err, &le) {
+		t.Fatalf("Expected correct PIN to be refused while locked, got %v", err)
+	}
+	if err = p.guardSecret(picSecret, policy)(a.Id(), "0000"); !errors.Is(err, ErrSecretNotSet) {
+		t.Fatalf("PIC should not be affected by PIN lockout, got %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	ak := AccountKey{Tenant: st, AccountId: a.Id()}
+	err = Get().Login(ak, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, uuid.Nil)
+	if err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+
+	if err = p.ChangePassword(message.NewBuffer())(a.Id(), "wrong", "changed"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("Expected password mismatch, got %v", err)
+	}
+	if err = p.ChangePassword(message.NewBuffer())(a.Id(), "password", ""); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Expected invalid password, got %v", err)
+	}
+
+	mb := message.NewBuffer()
+	if err = p.ChangePassword(mb)(a.Id(), "password", "changed"); err != nil {
+		t.Fatalf("Unable to change password: %v", err)
+	}
+	m, err := p.GetById(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if m.CredentialVersion() != 1 {
+		t.Fatalf("Credential version should be bumped. Got %d", m.CredentialVersion())
+	}
+	if _, err = credential.Verify(m.Password(), "changed"); err != nil {
+		t.Fatalf("Changed password does not match")
+	}
+	if m.State() != StateNotLoggedIn {
+		t.Fatalf("Sessions should be terminated on password change.")
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 2 {
+		t.Fatalf("Expected logged out and password changed events.")
+	}
+
+	if err = p.ResetPassword(message.NewBuffer())(a.Id(), "reset", " "); !errors.Is(err, ErrIssuerRequired) {
+		t.Fatalf("Expected issuer required, got %v", err)
+	}
+	mb = message.NewBuffer()
+	if err = p.ResetPassword(mb)(a.Id(), "reset", "admin"); err != nil {
+		t.Fatalf("Unable to reset password: %v", err)
+	}
+	ms := mb.GetAll()[account2.EnvEventTopicStatus]
+	if len(ms) != 1 {
+		t.Fatalf("Expected password changed event.")
+	}
+	var e account2.StatusEvent[account2.PasswordChangedStatusEventBody]
+	if err = json.Unmarshal(ms[0].Value, &e); err != nil {
+		t.Fatalf("Unable to unmarshal event: %v", err)
+	}
+	if e.Status != account2.EventStatusPasswordChanged || e.Body.Issuer != "admin" {
+		t.Fatalf("Unexpected password changed event [%s] issuer [%s].", e.Status, e.Body.Issuer)
+	}
+	m, _ = p.GetById(a.Id())
+	if m.CredentialVersion() != 2 {
+		t.Fatalf("Credential version should be bumped. Got %d", m.CredentialVersion())
+	}
+	if _, err = credential.Verify(m.Password(), "reset"); err != nil {
+		t.Fatalf("Reset password does not match")
+	}
+}
+
+func TestPasswordLockout(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	a, err := p.Create(message.NewBuffer())("Name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	policy := attempt.Policy{Threshold: 2, Window: time.Minute, Cooldown: time.Minute}
+
+	if err = p.guardPassword(policy)(a, "wrong"); !errors.Is(err, ErrPasswordMismatch) {
+		t.Fatalf("Expected password mismatch, got %v", err)
+	}
+	var le PasswordLockedError
+	if err = p.guardPassword(policy)(a, "wrong"); !errors.As(err, &le) {
+		t.Fatalf("Expected password to lock on reaching threshold, got %v", err)
+	}
+	if err = p.guardPassword(policy)(a, "password"); !errors.As(err, &le) {
+		t.Fatalf("Expected correct password to be refused while locked, got %v", err)
+	}
+	las := loginAttempts(st, configuration.LoginAttempts{}, uuid.New(), "name", "")
+	if _, ok := checkLoginAttempts(las); !ok {
+		t.Fatalf("Logins should be locked out along with password changes.")
+	}
+}
+
+func TestRecordLoginSuccess(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	sessionId := uuid.New()
+	p.recordLoginFailure(a.Id(), "name", sessionId, ServiceLogin, "127.0.0.1", IncorrectPassword)
+	p.recordLoginSuccess(a.Id(), "name", sessionId, ServiceLogin, "127.0.0.1")
+
+	m, err := p.GetById(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if m.LastLogin() == 0 || m.LastLoginIP() != "127.0.0.1" {
+		t.Fatalf("Last login not recorded.")
+	}
+
+	total, err := login.NewProcessor(l, tctx, db).CountByAccountId(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to count login history: %v", err)
+	}
+	if total != 2 {
+		t.Fatalf("Expected 2 login history entries, got %d", total)
+	}
+}
+
+func TestRecordLoginService(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	p.recordLoginFailure(a.Id(), "name", uuid.New(), ServiceWebPortal, "127.0.0.1", IncorrectPassword)
+	p.recordLoginSuccess(a.Id(), "name", uuid.New(), ServiceWebPortal, "127.0.0.1")
+
+	ms, err := login.NewProcessor(l, tctx, db).GetByAccountId(a.Id(), 0, 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve login history: %v", err)
+	}
+	if len(ms) != 2 {
+		t.Fatalf("Expected 2 login history entries, got %d", len(ms))
+	}
+	for _, m := range ms {
+		if m.Service() != ServiceWebPortal {
+			t.Fatalf("Expected [%s] login to be recorded through [%s], got [%s].", m.Outcome(), ServiceWebPortal, m.Service())
+		}
+	}
+}
+
+func TestSystemErrorNotRecorded(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	// Configuration is unavailable under test, so the attempt fails with a system error.
+	mb := message.NewBuffer()
+	err := NewProcessor(l, tctx, db).AttemptLogin(mb)(uuid.New(), ServiceLogin, uuid.Nil, "name", "password", "127.0.0.1", "", "")
+	if err != nil {
+		t.Fatalf("Unable to attempt login: %v", err)
+	}
+	if len(mb.GetAll()[account2.EnvEventSessionStatusTopic]) != 1 {
+		t.Fatalf("Expected the failed attempt to be reported.")
+	}
+	var total int64
+	if err = db.Model(&login.Entity{}).Where("tenant_id = ?", st.Id()).Count(&total).Error; err != nil {
+		t.Fatalf("Unable to count login history: %v", err)
+	}
+	if total != 0 {
+		t.Fatalf("System errors should not be recorded as failed logins. Got %d entries", total)
+	}
+}
+
+func TestAttemptLoginRefusedIssuer(t *testing.T) {
+	l, hook := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	for _, issuer := range []string{"UNKNOWN", ServiceChannel} {
+		hook.Reset()
+		mb := message.NewBuffer()
+		err := NewProcessor(l, tctx, db).AttemptLogin(mb)(uuid.New(), issuer, uuid.Nil, "name", "password", "127.0.0.1", "", "")
+		if err != nil {
+			t.Fatalf("Unable to attempt login: %v", err)
+		}
+		if len(mb.GetAll()[account2.EnvEventSessionStatusTopic]) != 1 {
+			t.Fatalf("Expected the refused attempt through [%s] to be reported.", issuer)
+		}
+		if e := hook.LastEntry(); e == nil || !strings.Contains(e.Message, "does not accept logins") {
+			t.Fatalf("Expected login through [%s] to be refused by issuer.", issuer)
+		}
+	}
+}
+
+func TestSessionsHeldAlongsideGame(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	ak := AccountKey{Tenant: st, AccountId: a.Id()}
+	portal := uuid.New()
+	game := uuid.New()
+	statuses := func(mb *message.Buffer) int {
+		return len(mb.GetAll()[account2.EnvEventTopicStatus])
+	}
+
+	mb := message.NewBuffer()
+	if err = p.Login(mb)(portal)(a.Id())(ServiceWebPortal)(uuid.Nil); err != nil || statuses(mb) != 0 {
+		t.Fatalf("Portal login should not report the account logged in, got %v.", err)
+	}
+	if err = p.Login(mb)(game)(a.Id())(ServiceLogin)(uuid.Nil); err != nil || statuses(mb) != 1 {
+		t.Fatalf("Game login should report the account logged in, got %v.", err)
+	}
+	if err = p.Logout(mb)(portal)(a.Id())(ServiceWebPortal); err != nil || statuses(mb) != 1 || !Get().IsLoggedIn(ak) {
+		t.Fatalf("Portal logout should not report the account logged out, got %v.", err)
+	}
+	if err = p.Logout(mb)(game)(a.Id())(ServiceLogin); err != nil || statuses(mb) != 2 {
+		t.Fatalf("Game logout should report the account logged out, got %v.", err)
+	}
+
+	// A portal session progresses although the account is not in the game, while unknown sessions do not.
+	if err = p.Login(message.NewBuffer())(portal)(a.Id())(ServiceWebPortal)(uuid.Nil); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	mb = message.NewBuffer()
+	if err = p.ProgressState(mb)(uuid.New(), ServiceWebPortal, uuid.Nil, a.Id(), StateNotLoggedIn, nil); err != nil {
+		t.Fatalf("Unable to progress state: %v", err)
+	}
+	if len(Get().GetStates(ak)) != 1 {
+		t.Fatalf("Unknown session should not progress.")
+	}
+	if err = p.ProgressState(mb)(portal, ServiceWebPortal, uuid.Nil, a.Id(), StateNotLoggedIn, nil); err != nil {
+		t.Fatalf("Unable to progress state: %v", err)
+	}
+	if len(Get().GetStates(ak)) != 0 || statuses(mb) != 0 {
+		t.Fatalf("Portal session should be logged out without reporting the account logged out.")
+	}
+	if err = p.ProgressState(mb)(game, ServiceLogin, uuid.Nil, a.Id(), StateLoggedIn, nil); err != nil {
+		t.Fatalf("Unable to progress state: %v", err)
+	}
+	if Get().IsLoggedIn(ak) {
+		t.Fatalf("Sessions should log in through credentials rather than progressing.")
+	}
+}
+
+func TestAccountDefaults(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st, _ := tenant.Create(uuid.New(), "JMS", 185, 1)
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	m, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	if m.Language() != "ja" || m.Country() != "jp" || m.CharacterSlots() != defaultCharacterSlots {
+		t.Fatalf("Account not created with region defaults. Got [%s] [%s] [%d].", m.Language(), m.Country(), m.CharacterSlots())
+	}
+
+	// Accounts which predate the columns take on the defaults.
+	a, err := create(db)(st, "legacy", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	m, err = p.GetById(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if m.Language() != "ja" || m.Country() != "jp" || m.CharacterSlots() != defaultCharacterSlots {
+		t.Fatalf("Account not decorated with region defaults. Got [%s] [%s] [%d].", m.Language(), m.Country(), m.CharacterSlots())
+	}
+}
+
+func TestUpdateExplicitDefaults(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st, _ := tenant.Create(uuid.New(), "JMS", 185, 1)
+	tctx := tenant.WithContext(context.Background(), st)
+
+	a, err := create(db)(st, "legacy", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	_, err = NewProcessor(l, tctx, db).Update(message.NewBuffer())(a.Id(), Model{language: "ja", country: "jp", characterSlots: defaultCharacterSlots})
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+	e, err := entityById(st, a.Id())(db)()
+	if err != nil {
+		t.Fatalf("Unable to retrieve account: %v", err)
+	}
+	if e.Language != "ja" || e.Country != "jp" || e.CharacterSlots != defaultCharacterSlots {
+		t.Fatalf("Explicit values matching the defaults should be persisted. Got [%s] [%s] [%d].", e.Language, e.Country, e.CharacterSlots)
+	}
+}
+
+func TestUpdateCharacterSlots(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	m, err := p.Update(mb)(a.Id(), Model{gender: a.gender, language: "en", characterSlots: 6})
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+	if m.CharacterSlots() != 6 {
+		t.Fatalf("Character slots not updated. Got [%d].", m.CharacterSlots())
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected character slots changed event.")
+	}
+
+	mb = message.NewBuffer()
+	_, err = p.Update(mb)(a.Id(), Model{gender: a.gender, country: "ca"})
+	if err != nil {
+		t.Fatalf("Unable to update account: %v", err)
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 0 {
+		t.Fatalf("Character slots changed event should only be emitted when slots change.")
+	}
+
+	if _, err = p.Update(message.NewBuffer())(a.Id(), Model{characterSlots: -1}); !errors.Is(err, ErrInvalidCharacterSlots) {
+		t.Fatalf("Expected invalid character slots, got %v", err)
+	}
+}
+
+func TestAddCharacterSlots(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	o, err := create(db)(st, "other", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	m, err := p.AddCharacterSlots(mb)(a.Id(), 2, "purchase-1")
+	if err != nil {
+		t.Fatalf("Unable to add character slots: %v", err)
+	}
+	if m.CharacterSlots() != defaultCharacterSlots+2 {
+		t.Fatalf("Expected [%d] character slots, got [%d].", defaultCharacterSlots+2, m.CharacterSlots())
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected character slots changed event.")
+	}
+
+	mb = message.NewBuffer()
+	m, err = p.AddCharacterSlots(mb)(a.Id(), 2, "purchase-1")
+	if err != nil {
+		t.Fatalf("Replayed grant should succeed: %v", err)
+	}
+	if m.CharacterSlots() != defaultCharacterSlots+2 {
+		t.Fatalf("Replayed grant should not add slots again. Got [%d].", m.CharacterSlots())
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 0 {
+		t.Fatalf("Replayed grant should not emit an event.")
+	}
+
+	if _, err = p.AddCharacterSlots(message.NewBuffer())(o.Id(), 1, "purchase-1"); !errors.Is(err, ErrIdempotencyConflict) {
+		t.Fatalf("Expected idempotency conflict, got %v", err)
+	}
+	if _, err = p.AddCharacterSlots(message.NewBuffer())(a.Id(), 0, ""); !errors.Is(err, ErrInvalidCharacterSlots) {
+		t.Fatalf("Expected invalid character slots, got %v", err)
+	}
+	mb = message.NewBuffer()
+	if _, err = p.AddCharacterSlots(mb)(a.Id(), defaultMaximumCharacterSlots, "purchase-2"); !errors.Is(err, ErrCharacterSlotLimit) {
+		t.Fatalf("Expected character slot limit, got %v", err)
+	}
+	ms := mb.GetAll()[account2.EnvEventTopicStatus]
+	if len(ms) != 1 {
+		t.Fatalf("Expected character slots failed event.")
+	}
+	var e account2.StatusEvent[account2.CharacterSlotsFailedStatusEventBody]
+	if err = json.Unmarshal(ms[0].Value, &e); err != nil {
+		t.Fatalf("Unable to unmarshal event: %v", err)
+	}
+	if e.Status != account2.EventStatusCharacterSlotsFailed || e.Body.Code != account2.CharacterSlotsErrorLimitReached || e.Body.IdempotencyKey != "purchase-2" {
+		t.Fatalf("Unexpected failed event [%s] [%s] [%s].", e.Status, e.Body.Code, e.Body.IdempotencyKey)
+	}
+
+	m, _ = p.GetById(a.Id())
+	if m.CharacterSlots() != defaultCharacterSlots+2 {
+		t.Fatalf("Refused grants should not change slots. Got [%d].", m.CharacterSlots())
+	}
+}
+
+func TestSetPrivileges(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	if a.GM() || len(a.Roles()) != 0 {
+		t.Fatalf("New account should hold no privileges.")
+	}
+
+	mb := message.NewBuffer()
+	m, err := p.SetPrivileges(mb)(a.Id(), 3, []string{"support", " Event_Host", "SUPPORT"}, "admin", "promotion")
+	if err != nil {
+		t.Fatalf("Unable to set privileges: %v", err)
+	}
+	if m.GMLevel() != 3 || len(m.Roles()) != 2 || m.Roles()[0] != "EVENT_HOST" || !m.HasRole("support") {
+		t.Fatalf("Privileges not applied as expected. Got [%d] [%v].", m.GMLevel(), m.Roles())
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected privileges changed event.")
+	}
+
+	mb = message.NewBuffer()
+	_, err = p.SetPrivileges(mb)(a.Id(), 3, []string{"EVENT_HOST", "SUPPORT"}, "admin", "")
+	if err != nil {
+		t.Fatalf("Unable to set privileges: %v", err)
+	}
+	if len(mb.GetAll()) != 0 {
+		t.Fatalf("Unchanged privileges should not emit.")
+	}
+
+	_, err = p.SetPrivileges(message.NewBuffer())(a.Id(), 0, nil, "admin", "demotion")
+	if err != nil {
+		t.Fatalf("Unable to set privileges: %v", err)
+	}
+
+	cs, err := p.GetPrivilegeChanges(a.Id(), 0, 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve privilege changes: %v", err)
+	}
+	if len(cs) != 2 {
+		t.Fatalf("Expected 2 privilege changes, got %d", len(cs))
+	}
+	if cs[0].GMLevel() != 0 || cs[0].PreviousGMLevel() != 3 || len(cs[0].PreviousRoles()) != 2 || cs[0].Reason() != "demotion" {
+		t.Fatalf("Privilege change not recorded as expected.")
+	}
+	if cs[1].Issuer() != "admin" || cs[1].PreviousGMLevel() != 0 {
+		t.Fatalf("Privilege change not recorded as expected.")
+	}
+
+	if _, err = p.SetPrivileges(message.NewBuffer())(a.Id(), 1, []string{"bad role"}, "admin", ""); !errors.Is(err, ErrInvalidRole) {
+		t.Fatalf("Expected invalid role, got %v", err)
+	}
+	if _, err = p.SetPrivileges(message.NewBuffer())(a.Id(), 1, nil, "", ""); !errors.Is(err, ErrIssuerRequired) {
+		t.Fatalf("Expected issuer required, got %v", err)
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	err = p.Delete(mb)(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to delete account: %v", err)
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected deleted event.")
+	}
+	if _, err = p.GetById(a.Id()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Deleted account should not be found, got %v", err)
+	}
+	if _, err = p.GetByName("name"); err == nil {
+		t.Fatalf("Deleted account should not be found by name.")
+	}
+	if err = p.Delete(message.NewBuffer())(a.Id()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Deleting twice should not find the account, got %v", err)
+	}
+	if _, err = p.Create(message.NewBuffer())("name")("password"); !errors.Is(err, ErrNameReserved) {
+		t.Fatalf("Expected name to be reserved, got %v", err)
+	}
+
+	mb = message.NewBuffer()
+	r, err := p.Restore(mb)(a.Id())
+	if err != nil {
+		t.Fatalf("Unable to restore account: %v", err)
+	}
+	if r.Id() != a.Id() || r.Deleted() {
+		t.Fatalf("Account not restored as expected.")
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected restored event.")
+	}
+	if _, err = p.Restore(message.NewBuffer())(a.Id()); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("Restoring a live account should not find it, got %v", err)
+	}
+
+	_ = p.Delete(message.NewBuffer())(a.Id())
+	db.Unscoped().Model(&Entity{}).Where("id = ?", a.Id()).Update("deleted_at", time.Now().Add(-defaultDeletionGracePeriod-time.Hour))
+	if _, err = p.Restore(message.NewBuffer())(a.Id()); !errors.Is(err, ErrGracePeriodElapsed) {
+		t.Fatalf("Expected grace period to have elapsed, got %v", err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	o, err := create(db)(st, "other", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	_, err = login.NewProcessor(l, tctx, db).RecordSuccess(a.Id(), "name", uuid.New(), "LOGIN", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Unable to record login: %v", err)
+	}
+	_, err = p.AddCharacterSlots(message.NewBuffer())(a.Id(), 1, "")
+	if err != nil {
+		t.Fatalf("Unable to add character slots: %v", err)
+	}
+	_, err = ban.NewProcessor(l, tctx, db).Create(message.NewBuffer())(a.Id(), ban.ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to ban account: %v", err)
+	}
+	_, err = wallet.NewProcessor(l, tctx, db).Credit(message.NewBuffer())(a.Id(), wallet.CurrencyCredit, 100, "", "")
+	if err != nil {
+		t.Fatalf("Unable to credit wallet: %v", err)
+	}
+
+	_ = p.Delete(message.NewBuffer())(a.Id())
+	_ = p.Delete(message.NewBuffer())(o.Id())
+	db.Unscoped().Model(&Entity{}).Where("id = ?", a.Id()).Update("deleted_at", time.Now().Add(-defaultDeletionGracePeriod-time.Hour))
+
+	NewPurge(l, db, time.Hour).Run()
+
+	var count int64
+	db.Unscoped().Model(&Entity{}).Where("id = ?", a.Id()).Count(&count)
+	if count != 1 {
+		t.Fatalf("Accounts of a tenant without readable configuration should not be purged.")
+	}
+
+	NewPurge(l, db, time.Hour).purgeElapsed(func(tenantId uuid.UUID) (configuration.Deletion, bool) {
+		return deletionPolicy(configuration.Deletion{}), true
+	})
+
+	db.Unscoped().Model(&Entity{}).Where("id = ?", a.Id()).Count(&count)
+	if count != 0 {
+		t.Fatalf("Account past the grace period should be purged.")
+	}
+	db.Unscoped().Model(&Entity{}).Where("id = ?", o.Id()).Count(&count)
+	if count != 1 {
+		t.Fatalf("Account within the grace period should be retained.")
+	}
+	db.Model(&CharacterSlotGrantEntity{}).Where("account_id = ?", a.Id()).Count(&count)
+	if count != 0 {
+		t.Fatalf("Character slot grants should be purged.")
+	}
+	if total, _ := login.NewProcessor(l, tctx, db).CountByAccountId(a.Id()); total != 0 {
+		t.Fatalf("Login history should be purged.")
+	}
+	db.Model(&ban.Entity{}).Where("account_id = ?", a.Id()).Count(&count)
+	if count != 0 {
+		t.Fatalf("Account bans should be purged.")
+	}
+	db.Model(&wallet.Entity{}).Where("account_id = ?", a.Id()).Count(&count)
+	if count != 0 {
+		t.Fatalf("Wallet should be purged.")
+	}
+	db.Model(&wallet.TransactionEntity{}).Where("account_id = ?", a.Id()).Count(&count)
+	if count != 1 {
+		t.Fatalf("Wallet ledger should be retained.")
+	}
+}
+
+func TestLoginDeletedBy(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db).(*ProcessorImpl)
+	a, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	if _, ok := p.loginDeletedBy("name"); ok {
+		t.Fatalf("Live account should not be refused as deleted.")
+	}
+	_ = p.Delete(message.NewBuffer())(a.Id())
+	if d, ok := p.loginDeletedBy("NAME"); !ok || d.Id() != a.Id() {
+		t.Fatalf("Login to deleted account should be refused.")
+	}
+
+	// Names of deleted accounts may be registered anew when the tenant does not reserve them.
+	r, err := create(db)(st, "name", "password", 0)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	if _, ok := p.loginDeletedBy("name"); ok {
+		t.Fatalf("Account [%d] registered anew should not be refused as deleted.", r.Id())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	tctx := tenant.WithContext(context.Background(), sampleTenant())
+	p := NewProcessor(l, tctx, db)
+
+	if err := p.Validate("player1", "secret"); err != nil {
+		t.Fatalf("Expected valid name and password, got %v", err)
+	}
+
+	err := p.Validate("a!", "x")
+	var re RequirementsError
+	if !errors.As(err, &re) {
+		t.Fatalf("Expected requirements error, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidName) || !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Expected error to match both invalid name and invalid password.")
+	}
+	codes := make(map[string]bool)
+	for _, v := range re.Violations {
+		codes[v.Code] = true
+	}
+	for _, c := range []string{ViolationNameTooShort, ViolationNameCharacters, ViolationPasswordTooShort} {
+		if !codes[c] {
+			t.Fatalf("Expected violation [%s] in %v.", c, re.Violations)
+		}
+	}
+
+	mb := message.NewBuffer()
+	if _, err = p.Create(mb)("bad name")("secret"); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("Expected invalid name to be rejected, got %v", err)
+	}
+	if _, err = p.GetByName("bad name"); err == nil {
+		t.Fatalf("Account should not have been created.")
+	}
+}
+
+func TestValidateRequirements(t *testing.T) {
+	nc := configuration.NameRequirements{MinLength: 2, MaxLength: 6, Characters: "^[a-z]+$", ReservedWords: []string{"Admin"}}
+	vs, err := validateName(nc, "ADMIN_")
+	if err != nil {
+		t.Fatalf("Unable to validate name: %v", err)
+	}
+	if len(vs) != 2 || vs[0].Code != ViolationNameCharacters || vs[1].Code != ViolationNameNotAllowed {
+		t.Fatalf("Unexpected name violations %v.", vs)
+	}
+	if vs, _ = validateName(nc, "toolongname"); len(vs) != 1 || vs[0].Code != ViolationNameTooLong {
+		t.Fatalf("Unexpected name violations %v.", vs)
+	}
+	for _, name := range []string{"admin", "theAdmin", "admin2", "x-ADMIN"} {
+		if !reserved(nc.ReservedWords, name) {
+			t.Fatalf("Expected [%s] to be reserved.", name)
+		}
+	}
+	for _, name := range []string{"badmin", "administer", "Thead2min"} {
+		if reserved(nc.ReservedWords, name) {
+			t.Fatalf("Expected [%s] not to be reserved.", name)
+		}
+	}
+	if vs, _ = validateName(configuration.NameRequirements{MinLength: 1, MaxLength: 12, Characters: "[a-z]+|[0-9]+"}, "abc123"); len(vs) != 1 || vs[0].Code != ViolationNameCharacters {
+		t.Fatalf("Expected pattern to be anchored, got %v.", vs)
+	}
+	if _, err = validateName(configuration.NameRequirements{Characters: "["}, "name"); err == nil {
+		t.Fatalf("Expected invalid character pattern to fail.")
+	}
+
+	pc := configuration.PasswordRequirements{MinLength: 1, MaxLength: 32, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+	vs = validatePassword(pc, "password")
+	if len(vs) != 3 || vs[0].Code != ViolationPasswordNoUppercase || vs[1].Code != ViolationPasswordNoDigit || vs[2].Code != ViolationPasswordNoSymbol {
+		t.Fatalf("Unexpected password violations %v.", vs)
+	}
+	if vs = validatePassword(pc, "Passw0rd!"); len(vs) != 0 {
+		t.Fatalf("Unexpected password violations %v.", vs)
+	}
+
+	err = requirementsError(validatePassword(pc, "password"))
+	if errors.Is(err, ErrInvalidName) || !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Expected error to match invalid password only.")
+	}
+	if requirementsError(nil, nil) != nil {
+		t.Fatalf("Expected no error without violations.")
+	}
+}
+
+func TestCreateWithGender(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	tctx := tenant.WithContext(context.Background(), sampleTenant())
+	p := NewProcessor(l, tctx, db)
+
+	mb := message.NewBuffer()
+	m, err := p.CreateWithGender(mb)("female", "password", 1)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	if m.gender != 1 {
+		t.Fatalf("Expected gender [1], got [%d].", m.gender)
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected created event.")
+	}
+
+	if _, err = p.CreateWithGender(mb)("other", "password", 7); !errors.Is(err, ErrInvalidGender) {
+		t.Fatalf("Expected invalid gender, got %v", err)
+	}
+	if _, err = p.CreateWithGender(mb)("FEMALE", "password", 0); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("Expected name to be taken, got %v", err)
+	}
+}
+
+func TestCreateForTransaction(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	tctx := tenant.WithContext(context.Background(), sampleTenant())
+	p := NewProcessor(l, tctx, db)
+
+	transactionId := uuid.New()
+	mb := message.NewBuffer()
+	m, err := p.CreateForTransaction(mb)(transactionId, "portal", "password", nil)
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	ms := mb.GetAll()[account2.EnvEventTopicStatus]
+	if len(ms) != 1 {
+		t.Fatalf("Expected created event.")
+	}
+	var ce account2.StatusEvent[account2.CreatedStatusEventBody]
+	if err = json.Unmarshal(ms[0].Value, &ce); err != nil {
+		t.Fatalf("Unable to decode event: %v", err)
+	}
+	if ce.Status != account2.EventStatusCreated || ce.AccountId != m.Id() || ce.Body.TransactionId != transactionId {
+		t.Fatalf("Unexpected created event %+v.", ce)
+	}
+
+	failures := []struct {
+		name     string
+		password string
+		code     string
+	}{
+		{"portal", "password", account2.CreateErrorAlreadyExists},
+		{"no", "password", account2.CreateErrorInvalidName},
+		{"portal2", "pw", account2.CreateErrorInvalidPassword},
+	}
+	for _, f := range failures {
+		transactionId = uuid.New()
+		mb = message.NewBuffer()
+		if _, err = p.CreateForTransaction(mb)(transactionId, f.name, f.password, nil); err == nil {
+			t.Fatalf("Expected creation of [%s] to fail.", f.name)
+		}
+		ms = mb.GetAll()[account2.EnvEventTopicStatus]
+		if len(ms) != 1 {
+			t.Fatalf("Expected create failed event.")
+		}
+		var fe account2.StatusEvent[account2.CreateFailedStatusEventBody]
+		if err = json.Unmarshal(ms[0].Value, &fe); err != nil {
+			t.Fatalf("Unable to decode event: %v", err)
+		}
+		if fe.Status != account2.EventStatusCreateFailed || fe.Body.TransactionId != transactionId || fe.Body.Code != f.code {
+			t.Fatalf("Unexpected create failed event %+v.", fe)
+		}
+	}
+}
+
+func TestGetByCriteria(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+	p := NewProcessor(l, tctx, db)
+
+	ids := make(map[string]uint32)
+	for _, name := range []string{"alpha", "Alpine", "bravo", "al_pha", "charlie"} {
+		// Created directly, as the default name requirements would refuse al_pha.
+		m, err := create(db)(st, name, "password", 0)
+		if err != nil {
+			t.Fatalf("Unable to create account: %v", err)
+		}
+		ids[name] = m.Id()
+	}
+	if _, err := p.SetPrivileges(message.NewBuffer())(ids["bravo"], 3, nil, "admin", ""); err != nil {
+		t.Fatalf("Unable to set privileges: %v", err)
+	}
+	if _, err := p.SetPrivileges(message.NewBuffer())(ids["charlie"], 1, nil, "admin", ""); err != nil {
+		t.Fatalf("Unable to set privileges: %v", err)
+	}
+	if _, err := ban.NewProcessor(l, tctx, db).Create(message.NewBuffer())(ids["Alpine"], 1, "admin", "", time.Time{}); err != nil {
+		t.Fatalf("Unable to ban account: %v", err)
+	}
+	if err := Get().Login(AccountKey{Tenant: st, AccountId: ids["charlie"]}, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, uuid.Nil); err != nil {
+		t.Fatalf("Unable to log in: %v", err)
+	}
+
+	yes, no := true, false
+	one := byte(1)
+	tests := []struct {
+		name     string
+		criteria Criteria
+		expected []string
+	}{
+		{"all", Criteria{}, []string{"alpha", "Alpine", "bravo", "al_pha", "charlie"}},
+		{"prefix", Criteria{NamePrefix: "AL"}, []string{"alpha", "Alpine", "al_pha"}},
+		{"escaped prefix", Criteria{NamePrefix: "al_"}, []string{"al_pha"}},
+		{"logged in", Criteria{LoggedIn: &yes}, []string{"charlie"}},
+		{"not logged in", Criteria{LoggedIn: &no, NamePrefix: "c"}, []string{}},
+		{"banned", Criteria{Banned: &yes}, []string{"Alpine"}},
+		{"not banned", Criteria{Banned: &no, NamePrefix: "alp"}, []string{"alpha"}},
+		{"gm level", Criteria{GMLevel: &one}, []string{"charlie"}},
+		{"minimum gm level", Criteria{MinGMLevel: &one, Sort: []Sort{{Field: SortGMLevel, Descending: true}}}, []string{"bravo", "charlie"}},
+		{"sorted by name", Criteria{Sort: []Sort{{Field: SortName, Descending: true}}}, []string{"charlie", "bravo", "Alpine", "alpha", "al_pha"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, err := p.CountByCriteria(tt.criteria)
+			if err != nil {
+				t.Fatalf("Unable to count accounts: %v", err)
+			}
+			if total != int64(len(tt.expected)) {
+				t.Fatalf("Expected [%d] accounts, counted [%d].", len(tt.expected), total)
+			}
+			as, err := p.GetByCriteria(tt.criteria, 0, 10)
+			if err != nil {
+				t.Fatalf("Unable to retrieve accounts: %v", err)
+			}
+			if len(as) != len(tt.expected) {
+				t.Fatalf("Expected [%d] accounts, got [%d].", len(tt.expected), len(as))
+			}
+			for i, a := range as {
+				if a.Name() != tt.expected[i] {
+					t.Fatalf("Expected [%s] at [%d], got [%s].", tt.expected[i], i, a.Name())
+				}
+			}
+		})
+	}
+
+	as, err := p.GetByCriteria(Criteria{}, 2, 2)
+	if err != nil {
+		t.Fatalf("Unable to retrieve accounts: %v", err)
+	}
+	if len(as) != 2 || as[0].Name() != "bravo" || as[1].Name() != "al_pha" {
+		t.Fatalf("Unexpected page of accounts.")
+	}
+}
+
+func TestExpireIdle(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	ak := AccountKey{Tenant: st, AccountId: a.Id()}
+	sk := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	if err = Get().Login(ak, sk, uuid.Nil); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	if err = p.Heartbeat(sk.SessionId, a.Id(), ServiceLogin); err != nil {
+		t.Fatalf("Unable to heartbeat: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	if err = p.ExpireIdle(mb)(a.Id(), map[Service]time.Duration{ServiceLogin: time.Minute}); err != nil {
+		t.Fatalf("Unable to expire idle sessions: %v", err)
+	}
+	if !Get().IsLoggedIn(ak) || len(mb.GetAll()[account2.EnvEventTopicStatus]) != 0 {
+		t.Fatalf("Session with a recent heartbeat should remain logged in.")
+	}
+
+	if err = p.ExpireIdle(mb)(a.Id(), map[Service]time.Duration{ServiceLogin: -time.Second}); err != nil {
+		t.Fatalf("Unable to expire idle sessions: %v", err)
+	}
+	if Get().IsLoggedIn(ak) {
+		t.Fatalf("Idle session should be logged out.")
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected logged out event.")
+	}
+	if err = p.Heartbeat(sk.SessionId, a.Id(), ServiceLogin); err == nil {
+		t.Fatalf("Heartbeat of a logged out session should fail.")
+	}
+}
+
+func TestExpireIdleOneOfTwoSessions(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	a, err := p.Create(message.NewBuffer())("name")("password")
+	if err != nil {
+		t.Fatalf("Unable to create account: %v", err)
+	}
+	ak := AccountKey{Tenant: st, AccountId: a.Id()}
+	login := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	portal := ServiceKey{SessionId: uuid.New(), Service: ServiceWebPortal}
+	for _, sk := range []ServiceKey{login, portal} {
+		if err = Get().Login(ak, sk, uuid.Nil); err != nil {
+			t.Fatalf("Unable to login: %v", err)
+		}
+	}
+
+	mb := message.NewBuffer()
+	if err = p.ExpireIdle(mb)(a.Id(), map[Service]time.Duration{ServiceWebPortal: -time.Second}); err != nil {
+		t.Fatalf("Unable to expire idle sessions: %v", err)
+	}
+	if states := Get().GetStates(ak); len(states) != 1 || !Get().IsLoggedIn(ak) {
+		t.Fatalf("Only the idle session should be logged out, got %v.", states)
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 0 {
+		t.Fatalf("Account remaining logged in should not be reported logged out.")
+	}
+
+	if err = p.ExpireIdle(mb)(a.Id(), map[Service]time.Duration{ServiceLogin: -time.Second}); err != nil {
+		t.Fatalf("Unable to expire idle sessions: %v", err)
+	}
+	if Get().IsLoggedIn(ak) || len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected account to be logged out once.")
+	}
+}
+
+func TestTerminateInstance(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	st := sampleTenant()
+	tctx := tenant.WithContext(context.Background(), st)
+
+	p := NewProcessor(l, tctx, db)
+	crashed := uuid.New()
+	other := uuid.New()
+	keys := make(map[string]AccountKey)
+	for _, name := range []string{"alpha", "bravo", "charlie", "delta"} {
+		a, err := p.Create(message.NewBuffer())(name)("password")
+		if err != nil {
+			t.Fatalf("Unable to create account: %v", err)
+		}
+		keys[name] = AccountKey{Tenant: st, AccountId: a.Id()}
+	}
+	if err := Get().Login(keys["alpha"], ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, crashed); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	if err := Get().Login(keys["bravo"], ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, other); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	transitioning := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	if err := Get().Login(keys["charlie"], transitioning, crashed); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	if err := Get().Transition(keys["charlie"], transitioning); err != nil {
+		t.Fatalf("Unable to transition: %v", err)
+	}
+	if err := Get().Login(keys["delta"], ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, other); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+	if err := Get().Login(keys["delta"], ServiceKey{SessionId: uuid.New(), Service: ServiceWebPortal}, crashed); err != nil {
+		t.Fatalf("Unable to login: %v", err)
+	}
+
+	if err := p.TerminateInstance(message.NewBuffer())(uuid.Nil); !errors.Is(err, ErrInvalidInstance) {
+		t.Fatalf("Expected invalid instance, got %v", err)
+	}
+	mb := message.NewBuffer()
+	if err := p.TerminateInstance(mb)(crashed); err != nil {
+		t.Fatalf("Unable to terminate instance: %v", err)
+	}
+	if Get().IsLoggedIn(keys["alpha"]) {
+		t.Fatalf("Sessions held by the instance should be logged out.")
+	}
+	if !Get().IsLoggedIn(keys["bravo"]) {
+		t.Fatalf("Sessions held by other instances should remain logged in.")
+	}
+	if !Get().IsLoggedIn(keys["charlie"]) {
+		t.Fatalf("Sessions transitioning away from the instance should remain.")
+	}
+	if states := Get().GetStates(keys["delta"]); len(states) != 1 || !Get().IsLoggedIn(keys["delta"]) {
+		t.Fatalf("Only the session held by the instance should be logged out, got %v.", states)
+	}
+	if len(mb.GetAll()[account2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected a single logged out event.")
+	}
+}
+
+func TestValidateConfiguration(t *testing.T) {
+	var tests = []struct {
+		name  string
+		yaml  string
+		valid bool
+	}{
+		{"defaults", "defaults: {}", true},
+		{"argon2id", "defaults:\n  passwordHashing:\n    algorithm: argon2id", true},
+		{"unknown default", "defaults:\n  passwordHashing:\n    algorithm: scrypt", false},
+		{"legacy tenant", "tenants:\n  083839c6-c47c-42a6-9585-76492795d123:\n    passwordHashing:\n      algorithm: sha1", false},
+		{"malformed tenant", "tenants:\n  tenant:\n    passwordHashing:\n      algorithm: bcrypt", false},
+		{"invalid name pattern", "defaults:\n  nameRequirements:\n    characters: \"[a-z\"", false},
+	}
+	for _, tt := range tests {
+		var c configuration.Configuration
+		if err := yaml.Unmarshal([]byte(tt.yaml), &c); err != nil {
+			t.Fatalf("[%s] unable to parse configuration: %v", tt.name, err)
+		}
+		err := validateConfiguration(&c)
+		if tt.valid && err != nil {
+			t.Errorf("[%s] expected configuration to be valid, got %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("[%s] expected configuration to be refused.", tt.name)
+		}
+	}
+}
@@ -7,33 +7,114 @@ import (
 	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
 	"math/rand"
+	"time"
 )
 
-func createCommandProvider(name string, password string) model.Provider[[]kafka.Message] {
+func createCommandProvider(transactionId uuid.UUID, name string, password string, gender *byte) model.Provider[[]kafka.Message] {
 	key := producer.CreateKey(rand.Int())
 	value := &account2.CreateCommand{
-		Name:     name,
-		Password: password,
+		TransactionId: transactionId,
+		Name:          name,
+		Password:      password,
+		Gender:        gender,
 	}
 	return producer.SingleMessageProvider(key, value)
 }
 
-func createdEventProvider() func(accountId uint32, name string) model.Provider[[]kafka.Message] {
-	return accountStatusEventProvider(account2.EventStatusCreated)
+func createdEventProvider(transactionId uuid.UUID, accountId uint32, name string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.StatusEvent[account2.CreatedStatusEventBody]{
+		AccountId: accountId,
+		Name:      name,
+		Status:    account2.EventStatusCreated,
+		Body: account2.CreatedStatusEventBody{
+			TransactionId: transactionId,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func createFailedEventProvider(transactionId uuid.UUID, name string, code string, violations []string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(rand.Int())
+	value := &account2.StatusEvent[account2.CreateFailedStatusEventBody]{
+		Name:   name,
+		Status: account2.EventStatusCreateFailed,
+		Body: account2.CreateFailedStatusEventBody{
+			TransactionId: transactionId,
+			Code:          code,
+			Violations:    violations,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
 }
 
-func loggedInEventProvider() func(accountId uint32, name string) model.Provider[[]kafka.Message] {
-	return accountStatusEventProvider(account2.EventStatusLoggedIn)
+func loggedInEventProvider(a Model) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(a.Id()))
+	value := &account2.StatusEvent[account2.LoggedInStatusEventBody]{
+		AccountId: a.Id(),
+		Name:      a.Name(),
+		Status:    account2.EventStatusLoggedIn,
+		Body: account2.LoggedInStatusEventBody{
+			GMLevel: a.GMLevel(),
+			Roles:   a.Roles(),
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
 }
 
 func loggedOutEventProvider() func(accountId uint32, name string) model.Provider[[]kafka.Message] {
 	return accountStatusEventProvider(account2.EventStatusLoggedOut)
 }
 
+func passwordChangedEventProvider(accountId uint32, name string, issuer string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.StatusEvent[account2.PasswordChangedStatusEventBody]{
+		AccountId: accountId,
+		Name:      name,
+		Status:    account2.EventStatusPasswordChanged,
+		Body: account2.PasswordChangedStatusEventBody{
+			Issuer: issuer,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func restoredEventProvider() func(accountId uint32, name string) model.Provider[[]kafka.Message] {
+	return accountStatusEventProvider(account2.EventStatusRestored)
+}
+
+func deletedEventProvider(accountId uint32, name string, deletedAt time.Time, purgeAt time.Time) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.StatusEvent[account2.DeletedStatusEventBody]{
+		AccountId: accountId,
+		Name:      name,
+		Status:    account2.EventStatusDeleted,
+		Body: account2.DeletedStatusEventBody{
+			DeletedAt: deletedAt,
+			PurgeAt:   purgeAt,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func characterSlotsFailedEventProvider(accountId uint32, amount int16, idempotencyKey string, code string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.StatusEvent[account2.CharacterSlotsFailedStatusEventBody]{
+		AccountId: accountId,
+		Status:    account2.EventStatusCharacterSlotsFailed,
+		Body: account2.CharacterSlotsFailedStatusEventBody{
+			Amount:         amount,
+			IdempotencyKey: idempotencyKey,
+			Code:           code,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
 func accountStatusEventProvider(status string) func(accountId uint32, name string) model.Provider[[]kafka.Message] {
 	return func(accountId uint32, name string) model.Provider[[]kafka.Message] {
 		key := producer.CreateKey(int(accountId))
-		value := &account2.StatusEvent{
+		value := &account2.StatusEvent[any]{
 			AccountId: accountId,
 			Name:      name,
 			Status:    status,
@@ -42,6 +123,39 @@ func accountStatusEventProvider(status string) func(accountId uint32, name strin
 	}
 }
 
+func characterSlotsChangedEventProvider(accountId uint32, name string, previous int16, characterSlots int16, idempotencyKey string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.StatusEvent[account2.CharacterSlotsChangedStatusEventBody]{
+		AccountId: accountId,
+		Name:      name,
+		Status:    account2.EventStatusCharacterSlotsChanged,
+		Body: account2.CharacterSlotsChangedStatusEventBody{
+			Previous:       previous,
+			CharacterSlots: characterSlots,
+			IdempotencyKey: idempotencyKey,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func privilegesChangedEventProvider(name string, c PrivilegeChange) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(c.AccountId()))
+	value := &account2.StatusEvent[account2.PrivilegesChangedStatusEventBody]{
+		AccountId: c.AccountId(),
+		Name:      name,
+		Status:    account2.EventStatusPrivilegesChanged,
+		Body: account2.PrivilegesChangedStatusEventBody{
+			PreviousGMLevel: c.PreviousGMLevel(),
+			GMLevel:         c.GMLevel(),
+			PreviousRoles:   c.PreviousRoles(),
+			Roles:           c.Roles(),
+			Issuer:          c.Issuer(),
+			Reason:          c.Reason(),
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
 func logoutCommandProvider(accountId uint32) model.Provider[[]kafka.Message] {
 	key := producer.CreateKey(int(accountId))
 	value := &account2.SessionCommand[account2.LogoutSessionCommandBody]{
@@ -54,13 +168,16 @@ func logoutCommandProvider(accountId uint32) model.Provider[[]kafka.Message] {
 	return producer.SingleMessageProvider(key, value)
 }
 
-func createdStatusProvider(sessionId uuid.UUID, accountId uint32) model.Provider[[]kafka.Message] {
-	key := producer.CreateKey(int(accountId))
+func createdStatusProvider(sessionId uuid.UUID, a Model) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(a.Id()))
 	value := &account2.SessionStatusEvent[account2.CreatedSessionStatusEventBody]{
 		SessionId: sessionId,
-		AccountId: accountId,
+		AccountId: a.Id(),
 		Type:      account2.SessionEventStatusTypeCreated,
-		Body:      account2.CreatedSessionStatusEventBody{},
+		Body: account2.CreatedSessionStatusEventBody{
+			GMLevel: a.GMLevel(),
+			Roles:   a.Roles(),
+		},
 	}
 	return producer.SingleMessageProvider(key, value)
 }
@@ -75,6 +192,16 @@ func requestLicenseAgreementStatusProvider(sessionId uuid.UUID, accountId uint32
 	return producer.SingleMessageProvider(key, value)
 }
 
+func secretVerifiedStatusProvider(sessionId uuid.UUID, accountId uint32, eventType string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.SessionStatusEvent[any]{
+		SessionId: sessionId,
+		AccountId: accountId,
+		Type:      eventType,
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
 func stateChangedStatusProvider(sessionId uuid.UUID, accountId uint32, state uint8, params interface{}) model.Provider[[]kafka.Message] {
 	key := producer.CreateKey(int(accountId))
 	value := &account2.SessionStatusEvent[account2.StateChangedSessionStatusEventBody]{
@@ -90,13 +217,19 @@ func stateChangedStatusProvider(sessionId uuid.UUID, accountId uint32, state uin
 }
 
 func errorStatusProvider(sessionId uuid.UUID, accountId uint32, code string) model.Provider[[]kafka.Message] {
+	return errorDetailStatusProvider(sessionId, accountId, code, 0, 0)
+}
+
+func errorDetailStatusProvider(sessionId uuid.UUID, accountId uint32, code string, reason byte, until uint64) model.Provider[[]kafka.Message] {
 	key := producer.CreateKey(int(accountId))
 	value := &account2.SessionStatusEvent[account2.ErrorSessionStatusEventBody]{
 		SessionId: sessionId,
 		AccountId: accountId,
 		Type:      account2.SessionEventStatusTypeError,
 		Body: account2.ErrorSessionStatusEventBody{
-			Code: code,
+			Code:   code,
+			Reason: reason,
+			Until:  until,
 		},
 	}
 	return producer.SingleMessageProvider(key, value)
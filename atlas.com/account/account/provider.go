@@ -5,6 +5,7 @@ import (
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/Chronicle20/atlas-tenant"
 	"gorm.io/gorm"
+	"time"
 )
 
 func entityById(tenant tenant.Model, id uint32) database.EntityProvider[Entity] {
@@ -22,7 +23,7 @@ func entityById(tenant tenant.Model, id uint32) database.EntityProvider[Entity]
 func entitiesByName(tenant tenant.Model, name string) database.EntityProvider[[]Entity] {
 	return func(db *gorm.DB) model.Provider[[]Entity] {
 		var results []Entity
-		err := db.Where(&Entity{TenantId: tenant.Id(), Name: name}).First(&results).Error
+		err := db.Where("tenant_id = ? AND LOWER(name) = LOWER(?)", tenant.Id(), name).First(&results).Error
 		if err != nil {
 			return model.ErrorProvider[[]Entity](err)
 		}
@@ -49,3 +50,90 @@ func allEntities(db *gorm.DB) model.Provider[[]Entity] {
 	}
 	return model.FixedProvider[[]Entity](results)
 }
+
+func privilegeChangeEntitiesByAccountId(tenant tenant.Model, accountId uint32, offset int, limit int) database.EntityProvider[[]PrivilegeChangeEntity] {
+	return func(db *gorm.DB) model.Provider[[]PrivilegeChangeEntity] {
+		var results []PrivilegeChangeEntity
+		err := db.Where(&PrivilegeChangeEntity{TenantId: tenant.Id(), AccountId: accountId}).Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]PrivilegeChangeEntity](err)
+		}
+		return model.FixedProvider[[]PrivilegeChangeEntity](results)
+	}
+}
+
+func countPrivilegeChangesByAccountId(tenant tenant.Model, accountId uint32) database.EntityProvider[int64] {
+	return func(db *gorm.DB) model.Provider[int64] {
+		var result int64
+		err := db.Model(&PrivilegeChangeEntity{}).Where(&PrivilegeChangeEntity{TenantId: tenant.Id(), AccountId: accountId}).Count(&result).Error
+		if err != nil {
+			return model.ErrorProvider[int64](err)
+		}
+		return model.FixedProvider[int64](result)
+	}
+}
+
+func deletedEntityById(tenant tenant.Model, id uint32) database.EntityProvider[Entity] {
+	return func(db *gorm.DB) model.Provider[Entity] {
+		var result = Entity{}
+		err := db.Unscoped().Where("tenant_id = ? AND id = ? AND deleted_at IS NOT NULL", tenant.Id(), id).First(&result).Error
+		if err != nil {
+			return model.ErrorProvider[Entity](err)
+		}
+		return model.FixedProvider[Entity](result)
+	}
+}
+
+func deletedEntitiesByName(tenant tenant.Model, name string) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		err := db.Unscoped().Where("tenant_id = ? AND LOWER(name) = LOWER(?) AND deleted_at IS NOT NULL", tenant.Id(), name).Order("deleted_at desc").Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+// allDeletedEntities retrieves the tombstones of every tenant.
+func allDeletedEntities(db *gorm.DB) model.Provider[[]Entity] {
+	var results []Entity
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").Find(&results).Error
+	if err != nil {
+		return model.ErrorProvider[[]Entity](err)
+	}
+	return model.FixedProvider[[]Entity](results)
+}
+
+func entitiesByCriteria(tenant tenant.Model, c Criteria, r Registry, offset int, limit int) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		err := db.Scopes(criteriaScope(tenant, c, r, time.Now()), sortScope(c)).Offset(offset).Limit(limit).Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+func countByCriteria(tenant tenant.Model, c Criteria, r Registry) database.EntityProvider[int64] {
+	return func(db *gorm.DB) model.Provider[int64] {
+		var result int64
+		err := db.Model(&Entity{}).Scopes(criteriaScope(tenant, c, r, time.Now())).Count(&result).Error
+		if err != nil {
+			return model.ErrorProvider[int64](err)
+		}
+		return model.FixedProvider[int64](result)
+	}
+}
+
+func grantByIdempotencyKey(tenant tenant.Model, idempotencyKey string) database.EntityProvider[CharacterSlotGrantEntity] {
+	return func(db *gorm.DB) model.Provider[CharacterSlotGrantEntity] {
+		var result CharacterSlotGrantEntity
+		err := db.Where(&CharacterSlotGrantEntity{TenantId: tenant.Id(), IdempotencyKey: &idempotencyKey}).First(&result).Error
+		if err != nil {
+			return model.ErrorProvider[CharacterSlotGrantEntity](err)
+		}
+		return model.FixedProvider(result)
+	}
+}
@@ -1,41 +1,114 @@
 package account
 
 import (
+	"atlas-account/configuration"
 	"errors"
 	"github.com/Chronicle20/atlas-tenant"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
 
-var instance *Registry
-var once sync.Once
+const (
+	EnvRegistryBackend      = "REGISTRY_BACKEND"
+	RegistryBackendMemory   = "MEMORY"
+	RegistryBackendDatabase = "DATABASE"
+)
 
-func Get() *Registry {
-	once.Do(func() {
-		instance = &Registry{
-			lock:     sync.RWMutex{},
-			sessions: make(map[AccountKey]map[ServiceKey]StateValue),
-		}
-	})
+// Registry tracks the sessions accounts hold with each service. Every change to the sessions of an account is applied
+// atomically, as a compare-and-set of the sessions it was decided from, so concurrent requests cannot both log an account
+// in.
+type Registry interface {
+	GetStates(key AccountKey) map[ServiceKey]StateValue
+	MaximalState(key AccountKey) State
+	IsLoggedIn(key AccountKey) bool
+	Login(key AccountKey, sk ServiceKey, instanceId uuid.UUID) error
+	Transition(key AccountKey, sk ServiceKey) error
+	ExpireTransition(key AccountKey, timeouts map[Service]time.Duration)
+	Heartbeat(key AccountKey, sk ServiceKey) error
+	ExpireIdle(key AccountKey, timeouts map[Service]time.Duration) bool
+	Logout(key AccountKey, sk ServiceKey) bool
+	Terminate(key AccountKey) bool
+	TerminateInstance(t tenant.Model, instanceId uuid.UUID) []uint32
+	GetExpiredInTransition(timeouts map[Service]time.Duration) []AccountKey
+	GetExpiredIdle(timeouts map[Service]time.Duration) []AccountKey
+	Tenants() []tenant.Model
+	LoggedInScope(t tenant.Model, loggedIn bool) func(db *gorm.DB) *gorm.DB
+}
+
+var instance Registry
+var instanceLock sync.RWMutex
+
+// Get retrieves the registry in use, which is held in memory unless another is configured.
+func Get() Registry {
+	instanceLock.RLock()
+	r := instance
+	instanceLock.RUnlock()
+	if r != nil {
+		return r
+	}
+
+	instanceLock.Lock()
+	defer instanceLock.Unlock()
+	if instance == nil {
+		instance = NewMemoryRegistry()
+	}
 	return instance
 }
 
+// UseRegistry replaces the registry in use. It is meant to be called during startup, before sessions are created.
+func UseRegistry(r Registry) {
+	instanceLock.Lock()
+	defer instanceLock.Unlock()
+	instance = r
+}
+
+// InitRegistry configures the registry backend named by REGISTRY_BACKEND, and the services sessions may be held with.
+// The database backend shares sessions between replicas of the service and survives restarts. The memory backend, the
+// default, does neither.
+func InitRegistry(l logrus.FieldLogger, db *gorm.DB) {
+	backend := strings.ToUpper(os.Getenv(EnvRegistryBackend))
+	switch backend {
+	case RegistryBackendDatabase:
+		UseRegistry(NewDatabaseRegistry(l, db))
+	case "", RegistryBackendMemory:
+		UseRegistry(NewMemoryRegistry())
+		backend = RegistryBackendMemory
+	default:
+		l.Warnf("Unknown registry backend [%s]. Using [%s].", backend, RegistryBackendMemory)
+		UseRegistry(NewMemoryRegistry())
+		backend = RegistryBackendMemory
+	}
+	l.Infof("Using [%s] session registry.", backend)
+
+	c, err := configuration.Get()
+	if err != nil {
+		l.WithError(err).Warnf("Error reading needed configuration. Using default services.")
+		UseServices(DefaultServices())
+		return
+	}
+	services := sessionServices(c.SessionRegistry)
+	if err = services.Validate(); err != nil {
+		l.WithError(err).Warnf("Session registry services are misconfigured. Transitions involving them will be refused.")
+	}
+	UseServices(services)
+}
+
 type AccountKey struct {
 	Tenant    tenant.Model
 	AccountId uint32
 }
 
-type Service string
-
-const (
-	ServiceLogin   = "LOGIN"
-	ServiceChannel = "CHANNEL"
-)
-
+// StateValue is the state of a service session, and the instance of the service which holds it. Sessions of a service
+// which does not identify its instances are held by the nil instance.
 type StateValue struct {
-	State     State
-	UpdatedAt time.Time
+	State      State
+	UpdatedAt  time.Time
+	InstanceId uuid.UUID
 }
 
 type ServiceKey struct {
@@ -43,146 +116,233 @@ type ServiceKey struct {
 	Service   Service
 }
 
-type Registry struct {
-	lock     sync.RWMutex
-	sessions map[AccountKey]map[ServiceKey]StateValue
+// maximalState is the state of the least advanced session of an exclusive service, or StateNotLoggedIn without such
+// sessions. Sessions held alongside them do not log the account in.
+func maximalState(services Services, states map[ServiceKey]StateValue) State {
+	var maximalState = uint8(99)
+	for sk, state := range states {
+		if services.exclusive(sk.Service) && uint8(state.State) < maximalState {
+			maximalState = uint8(state.State)
+		}
+	}
+	if maximalState == 99 {
+		return StateNotLoggedIn
+	}
+	return State(maximalState)
 }
 
-func (l *Registry) GetStates(key AccountKey) map[ServiceKey]StateValue {
-	l.lock.RLock()
-	defer l.lock.RUnlock()
+// applyLogin applies a login of the service session, held by the instance given, to the sessions of an account. A
+// service entered by transition takes over the sessions transitioning to it, and replaces the other sessions of exclusive
+// services.
+func applyLogin(services Services, states map[ServiceKey]StateValue, sk ServiceKey, instanceId uuid.UUID, now time.Time) error {
+	d, ok := services[sk.Service]
+	if !ok {
+		return errors.New("undefined service")
+	}
 
-	var states map[ServiceKey]StateValue
-	var ok bool
-	if states, ok = l.sessions[key]; !ok {
-		return map[ServiceKey]StateValue{}
+	if len(d.TransitionsFrom) == 0 {
+		if d.Exclusive && maximalState(services, states) > 0 {
+			return errors.New("already logged in")
+		}
+		states[sk] = StateValue{State: StateLoggedIn, UpdatedAt: now, InstanceId: instanceId}
+		return nil
 	}
 
-	return states
+	var transition = false
+	for tk, ts := range states {
+		if ts.State == StateTransition && services.mayTransition(tk.Service, sk.Service) {
+			transition = true
+		}
+	}
+	if !transition {
+		return errors.New("no other service transitioning")
+	}
+	for tk, ts := range states {
+		if services.exclusive(tk.Service) || (ts.State == StateTransition && services.mayTransition(tk.Service, sk.Service)) {
+			delete(states, tk)
+		}
+	}
+	states[sk] = StateValue{State: StateLoggedIn, UpdatedAt: now, InstanceId: instanceId}
+	return nil
 }
 
-func (l *Registry) MaximalState(key AccountKey) State {
-	l.lock.RLock()
-	defer l.lock.RUnlock()
-
-	var states map[ServiceKey]StateValue
-	var ok bool
-	if states, ok = l.sessions[key]; !ok {
-		return StateNotLoggedIn
+// applyTransition marks the service session of an account as moving to another service.
+func applyTransition(services Services, states map[ServiceKey]StateValue, sk ServiceKey, now time.Time) error {
+	if d, ok := services[sk.Service]; !ok || len(d.TransitionsTo) == 0 {
+		return errors.New("service may not transition")
 	}
+	if state, ok := states[sk]; ok {
+		if state.State > 0 {
+			state.State = StateTransition
+			state.UpdatedAt = now
+			states[sk] = state
+			return nil
+		}
+	}
+	return errors.New("not logged in")
+}
 
-	var maximalState = uint8(99)
-	if len(states) == 0 {
-		return StateNotLoggedIn
+// transitionExpired reports whether a session has been transitioning for longer than the transition timeout of its
+// service, or the default timeout when its service has none.
+func transitionExpired(sk ServiceKey, state StateValue, timeouts map[Service]time.Duration, now time.Time) bool {
+	timeout, ok := timeouts[sk.Service]
+	if !ok {
+		timeout = defaultTransitionTimeout
 	}
+	return state.State == StateTransition && now.Sub(state.UpdatedAt) > timeout
+}
 
-	for _, state := range states {
-		if uint8(state.State) < maximalState {
-			maximalState = uint8(state.State)
+// applyExpireTransition removes the sessions of an account which have been transitioning longer than the timeout of
+// their service, reporting whether any were.
+func applyExpireTransition(states map[ServiceKey]StateValue, timeouts map[Service]time.Duration, now time.Time) bool {
+	expired := false
+	for sk, state := range states {
+		if transitionExpired(sk, state, timeouts, now) {
+			delete(states, sk)
+			expired = true
 		}
 	}
-	return State(maximalState)
+	return expired
 }
 
-func (l *Registry) IsLoggedIn(key AccountKey) bool {
-	return l.MaximalState(key) > 0
+// applyHeartbeat marks the service session of an account as still in use, unless it is transitioning.
+func applyHeartbeat(states map[ServiceKey]StateValue, sk ServiceKey, now time.Time) error {
+	if state, ok := states[sk]; ok && state.State == StateLoggedIn {
+		state.UpdatedAt = now
+		states[sk] = state
+		return nil
+	}
+	return errors.New("not logged in")
 }
 
-func (l *Registry) Login(key AccountKey, sk ServiceKey) error {
-	l.lock.Lock()
-	defer l.lock.Unlock()
+// idle reports whether a logged in session has gone without use for longer than the idle timeout of its service.
+// Services without a timeout are never idle.
+func idle(sk ServiceKey, state StateValue, timeouts map[Service]time.Duration, now time.Time) bool {
+	timeout, ok := timeouts[sk.Service]
+	return ok && state.State == StateLoggedIn && now.Sub(state.UpdatedAt) > timeout
+}
 
-	var states map[ServiceKey]StateValue
-	var ok bool
-	if states, ok = l.sessions[key]; !ok {
-		l.sessions[key] = make(map[ServiceKey]StateValue)
-		states = l.sessions[key]
+// applyExpireIdle removes the idle sessions of an account, reporting whether any were, and whether the account was
+// logged out as a result.
+func applyExpireIdle(services Services, states map[ServiceKey]StateValue, timeouts map[Service]time.Duration, now time.Time) (bool, bool) {
+	wasLoggedIn := maximalState(services, states) > 0
+	expired := false
+	for sk, state := range states {
+		if idle(sk, state, timeouts, now) {
+			delete(states, sk)
+			expired = true
+		}
 	}
+	return expired, wasLoggedIn && maximalState(services, states) == 0
+}
 
-	if sk.Service == ServiceLogin {
-		for _, state := range states {
-			if state.State > 0 {
-				return errors.New("already logged in")
-			}
-		}
-		states[sk] = StateValue{State: StateLoggedIn, UpdatedAt: time.Now()}
-		return nil
-	} else if sk.Service == ServiceChannel {
-		// Remove ServiceLogin states if we've successfully moved to a ServiceChannel.
-		var transition = false
-		for tk, ts := range states {
-			if ts.State > 1 {
-				transition = true
-			}
-			delete(states, tk)
+// applyTerminateInstance removes the sessions of an account held by the service instance, unless they are transitioning,
+// reporting whether any were, and whether the account was logged out as a result.
+func applyTerminateInstance(services Services, states map[ServiceKey]StateValue, instanceId uuid.UUID) (bool, bool) {
+	wasLoggedIn := maximalState(services, states) > 0
+	terminated := false
+	for sk, state := range states {
+		if state.InstanceId == instanceId && state.State != StateTransition {
+			delete(states, sk)
+			terminated = true
 		}
+	}
+	return terminated, wasLoggedIn && maximalState(services, states) == 0
+}
 
-		if transition {
-			l.sessions[key][sk] = StateValue{State: StateLoggedIn, UpdatedAt: time.Now()}
-			return nil
-		}
-		return errors.New("no other service transitioning")
+// applyLogout removes the service session of an account, unless it is transitioning.
+func applyLogout(states map[ServiceKey]StateValue, sk ServiceKey) bool {
+	if states[sk].State != StateTransition {
+		delete(states, sk)
+		return true
 	}
-	return errors.New("undefined service")
+	return false
 }
 
-func (l *Registry) Transition(key AccountKey, sk ServiceKey) error {
-	l.lock.Lock()
-	defer l.lock.Unlock()
+// MemoryRegistry holds sessions in the memory of this process alone.
+type MemoryRegistry struct {
+	lock     sync.RWMutex
+	sessions map[AccountKey]map[ServiceKey]StateValue
+}
 
-	var states map[ServiceKey]StateValue
-	var ok bool
-	if states, ok = l.sessions[key]; !ok {
-		l.sessions[key] = make(map[ServiceKey]StateValue)
-		states = l.sessions[key]
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		lock:     sync.RWMutex{},
+		sessions: make(map[AccountKey]map[ServiceKey]StateValue),
 	}
+}
 
-	if state, ok := states[sk]; ok {
-		if state.State > 0 {
-			l.sessions[key][sk] = StateValue{State: StateTransition, UpdatedAt: time.Now()}
-			return nil
-		}
+func (l *MemoryRegistry) GetStates(key AccountKey) map[ServiceKey]StateValue {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	var results = make(map[ServiceKey]StateValue)
+	for sk, state := range l.sessions[key] {
+		results[sk] = state
 	}
-	return errors.New("not logged in")
+	return results
 }
 
-func (l *Registry) ExpireTransition(key AccountKey, timeout time.Duration) {
-	l.lock.Lock()
-	defer l.lock.Unlock()
+func (l *MemoryRegistry) MaximalState(key AccountKey) State {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return maximalState(GetServices(), l.sessions[key])
+}
+
+func (l *MemoryRegistry) IsLoggedIn(key AccountKey) bool {
+	return l.MaximalState(key) > 0
+}
 
+// states retrieves the sessions of an account for change. The lock must be held.
+func (l *MemoryRegistry) states(key AccountKey) map[ServiceKey]StateValue {
 	var states map[ServiceKey]StateValue
 	var ok bool
 	if states, ok = l.sessions[key]; !ok {
 		l.sessions[key] = make(map[ServiceKey]StateValue)
 		states = l.sessions[key]
 	}
+	return states
+}
 
-	for sk, state := range states {
-		if state.State == StateTransition && time.Now().Sub(state.UpdatedAt) > timeout {
-			delete(states, sk)
-		}
-	}
+func (l *MemoryRegistry) Login(key AccountKey, sk ServiceKey, instanceId uuid.UUID) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return applyLogin(GetServices(), l.states(key), sk, instanceId, time.Now())
 }
 
-func (l *Registry) Logout(key AccountKey, sk ServiceKey) bool {
+func (l *MemoryRegistry) Transition(key AccountKey, sk ServiceKey) error {
 	l.lock.Lock()
 	defer l.lock.Unlock()
+	return applyTransition(GetServices(), l.states(key), sk, time.Now())
+}
 
-	var states map[ServiceKey]StateValue
-	var ok bool
-	if states, ok = l.sessions[key]; !ok {
-		l.sessions[key] = make(map[ServiceKey]StateValue)
-		states = l.sessions[key]
-	}
+func (l *MemoryRegistry) ExpireTransition(key AccountKey, timeouts map[Service]time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	applyExpireTransition(l.states(key), timeouts, time.Now())
+}
 
-	if states[sk].State != 2 {
-		delete(states, sk)
-		return true
-	}
-	return false
+func (l *MemoryRegistry) Heartbeat(key AccountKey, sk ServiceKey) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return applyHeartbeat(l.states(key), sk, time.Now())
 }
 
-func (l *Registry) Terminate(key AccountKey) bool {
+// ExpireIdle removes the idle sessions of the account, reporting whether doing so logged it out.
+func (l *MemoryRegistry) ExpireIdle(key AccountKey, timeouts map[Service]time.Duration) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	_, loggedOut := applyExpireIdle(GetServices(), l.states(key), timeouts, time.Now())
+	return loggedOut
+}
+
+func (l *MemoryRegistry) Logout(key AccountKey, sk ServiceKey) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return applyLogout(l.states(key), sk)
+}
+
+func (l *MemoryRegistry) Terminate(key AccountKey) bool {
 	l.lock.Lock()
 	defer l.lock.Unlock()
 
@@ -190,27 +350,146 @@ func (l *Registry) Terminate(key AccountKey) bool {
 	return true
 }
 
-func (l *Registry) GetExpiredInTransition(timeout time.Duration) []AccountKey {
+// TerminateInstance removes the sessions of the tenant held by the service instance, reporting the accounts logged out as
+// a result.
+func (l *MemoryRegistry) TerminateInstance(t tenant.Model, instanceId uuid.UUID) []uint32 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	services := GetServices()
+	var ids = make([]uint32, 0)
+	for ak, states := range l.sessions {
+		if ak.Tenant != t {
+			continue
+		}
+		if _, loggedOut := applyTerminateInstance(services, states, instanceId); loggedOut {
+			ids = append(ids, ak.AccountId)
+		}
+	}
+	return ids
+}
+
+func (l *MemoryRegistry) GetExpiredInTransition(timeouts map[Service]time.Duration) []AccountKey {
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 
+	now := time.Now()
 	accounts := make([]AccountKey, 0)
 	for account, session := range l.sessions {
-		for _, state := range session {
-			if state.State == StateTransition && time.Now().Sub(state.UpdatedAt) > timeout {
+		for sk, state := range session {
+			if transitionExpired(sk, state, timeouts, now) {
 				accounts = append(accounts, account)
+				break
 			}
 		}
 	}
 	return accounts
 }
 
-func (l *Registry) Tenants() []tenant.Model {
-	l.lock.Lock()
-	defer l.lock.Unlock()
+func (l *MemoryRegistry) GetExpiredIdle(timeouts map[Service]time.Duration) []AccountKey {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	now := time.Now()
+	accounts := make([]AccountKey, 0)
+	for account, session := range l.sessions {
+		for sk, state := range session {
+			if idle(sk, state, timeouts, now) {
+				accounts = append(accounts, account)
+				break
+			}
+		}
+	}
+	return accounts
+}
+
+func (l *MemoryRegistry) Tenants() []tenant.Model {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	var seen = make(map[tenant.Model]bool)
 	var tenants = make([]tenant.Model, 0)
 	for ak := range l.sessions {
-		tenants = append(tenants, ak.Tenant)
+		if !seen[ak.Tenant] {
+			seen[ak.Tenant] = true
+			tenants = append(tenants, ak.Tenant)
+		}
 	}
 	return tenants
 }
+
+// Sessions retrieves the sessions of every account.
+func (l *MemoryRegistry) Sessions() map[AccountKey]map[ServiceKey]StateValue {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	var results = make(map[AccountKey]map[ServiceKey]StateValue)
+	for ak, states := range l.sessions {
+		if len(states) == 0 {
+			continue
+		}
+		results[ak] = make(map[ServiceKey]StateValue)
+		for sk, state := range states {
+			results[ak][sk] = state
+		}
+	}
+	return results
+}
+
+// Restore reinstates sessions of an account held before a restart, unless sessions updated since are held, reporting
+// whether they were reinstated.
+func (l *MemoryRegistry) Restore(key AccountKey, states map[ServiceKey]StateValue) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	current := l.states(key)
+	if len(current) > 0 && !lastUpdated(states).After(lastUpdated(current)) {
+		return false
+	}
+	clear(current)
+	for sk, state := range states {
+		current[sk] = state
+	}
+	return true
+}
+
+func lastUpdated(states map[ServiceKey]StateValue) time.Time {
+	var result time.Time
+	for _, state := range states {
+		if state.UpdatedAt.After(result) {
+			result = state.UpdatedAt
+		}
+	}
+	return result
+}
+
+// LoggedInScope restricts a query of the accounts of the tenant to those which are, or are not, logged in, as IsLoggedIn
+// decides.
+func (l *MemoryRegistry) LoggedInScope(t tenant.Model, loggedIn bool) func(db *gorm.DB) *gorm.DB {
+	ids := l.loggedInAccountIds(t)
+	return func(db *gorm.DB) *gorm.DB {
+		if loggedIn {
+			if len(ids) == 0 {
+				return db.Where("1 = 0")
+			}
+			return db.Where("id IN ?", ids)
+		}
+		if len(ids) == 0 {
+			return db
+		}
+		return db.Where("id NOT IN ?", ids)
+	}
+}
+
+// loggedInAccountIds retrieves the accounts of the tenant which are logged in, as IsLoggedIn decides.
+func (l *MemoryRegistry) loggedInAccountIds(t tenant.Model) []uint32 {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	services := GetServices()
+	var ids = make([]uint32, 0)
+	for ak, states := range l.sessions {
+		if ak.Tenant == t && maximalState(services, states) > 0 {
+			ids = append(ids, ak.AccountId)
+		}
+	}
+	return ids
+}
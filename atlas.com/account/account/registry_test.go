@@ -1,9 +1,14 @@
 package account
 
 import (
+	"atlas-account/configuration"
+	"errors"
 	"github.com/Chronicle20/atlas-tenant"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCoordinator(t *testing.T) {
@@ -18,7 +23,7 @@ func TestCoordinator(t *testing.T) {
 	if c.IsLoggedIn(ak) {
 		t.Error("IsLoggedIn should return false. not logged in yet")
 	}
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -29,7 +34,7 @@ func TestCoordinator(t *testing.T) {
 	if c.IsLoggedIn(ak) {
 		t.Error("IsLoggedIn should return false. not logged in yet")
 	}
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -43,7 +48,7 @@ func TestCoordinator(t *testing.T) {
 	if !c.IsLoggedIn(ak) {
 		t.Error("IsLoggedIn should return true")
 	}
-	err = c.Login(ak, s2)
+	err = c.Login(ak, s2, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -65,7 +70,7 @@ func TestHappyPath(t *testing.T) {
 
 	var err error
 
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -74,7 +79,7 @@ func TestHappyPath(t *testing.T) {
 		t.Error(err)
 	}
 	c.Logout(ak, s1)
-	err = c.Login(ak, s2)
+	err = c.Login(ak, s2, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -92,7 +97,7 @@ func TestUnhappyPath(t *testing.T) {
 
 	var err error
 
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -100,7 +105,7 @@ func TestUnhappyPath(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	err = c.Login(ak, s2)
+	err = c.Login(ak, s2, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -120,7 +125,7 @@ func TestChangeChannelHappy(t *testing.T) {
 
 	var err error
 
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -130,7 +135,7 @@ func TestChangeChannelHappy(t *testing.T) {
 	}
 	c.Logout(ak, s1)
 
-	err = c.Login(ak, s2)
+	err = c.Login(ak, s2, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -139,7 +144,7 @@ func TestChangeChannelHappy(t *testing.T) {
 		t.Error(err)
 	}
 	c.Logout(ak, s2)
-	err = c.Login(ak, s3)
+	err = c.Login(ak, s3, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -158,7 +163,7 @@ func TestChangeChannelUnhappy(t *testing.T) {
 
 	var err error
 
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -167,7 +172,7 @@ func TestChangeChannelUnhappy(t *testing.T) {
 		t.Error(err)
 	}
 	c.Logout(ak, s1)
-	err = c.Login(ak, s2)
+	err = c.Login(ak, s2, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -175,7 +180,7 @@ func TestChangeChannelUnhappy(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	err = c.Login(ak, s3)
+	err = c.Login(ak, s3, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -193,12 +198,366 @@ func TestDoubleLogin(t *testing.T) {
 
 	var err error
 
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err != nil {
 		t.Error(err)
 	}
-	err = c.Login(ak, s1)
+	err = c.Login(ak, s1, uuid.Nil)
 	if err == nil {
 		t.Errorf("double login should return an error")
 	}
 }
+
+func TestDatabaseRegistry(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	r1 := NewDatabaseRegistry(l, db)
+	r2 := NewDatabaseRegistry(l, db)
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	s1 := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	s2 := ServiceKey{SessionId: uuid.New(), Service: ServiceChannel}
+
+	if err := r1.Login(ak, s1, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if !r2.IsLoggedIn(ak) {
+		t.Fatal("IsLoggedIn should return true on another replica")
+	}
+	if err := r2.Login(ak, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, uuid.Nil); err == nil {
+		t.Fatal("Login should fail on another replica while logged in")
+	}
+	db.Create(&[]Entity{{TenantId: tenant.Id(), ID: ak.AccountId, Name: "alpha"}, {TenantId: tenant.Id(), ID: 2, Name: "bravo"}})
+	wk := AccountKey{Tenant: tenant, AccountId: 2}
+	ws := ServiceKey{SessionId: uuid.New(), Service: ServiceWebPortal}
+	if err := r1.Login(wk, ws, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, loggedIn := range []bool{true, false} {
+		var ids []uint32
+		if err := db.Model(&Entity{}).Scopes(r2.LoggedInScope(tenant, loggedIn)).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[bool]uint32{true: ak.AccountId, false: 2}[loggedIn]; len(ids) != 1 || ids[0] != expected {
+			t.Fatalf("Unexpected accounts %v logged in [%t].", ids, loggedIn)
+		}
+	}
+	r1.Logout(wk, ws)
+
+	if err := r1.Transition(ak, s1); err != nil {
+		t.Fatal(err)
+	}
+	if r2.Logout(ak, s1) {
+		t.Fatal("Logout should not remove a transitioning session")
+	}
+	if err := r2.Login(ak, s2, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	states := r1.GetStates(ak)
+	if len(states) != 1 || states[s2].State != StateLoggedIn {
+		t.Fatalf("Unexpected sessions %v.", states)
+	}
+
+	if err := r1.Transition(ak, s2); err != nil {
+		t.Fatal(err)
+	}
+	expired := map[Service]time.Duration{ServiceChannel: -time.Second}
+	if as := r2.GetExpiredInTransition(expired); len(as) != 1 || as[0] != ak {
+		t.Fatalf("Unexpected accounts in transition %v.", as)
+	}
+	r2.ExpireTransition(ak, expired)
+	if r1.IsLoggedIn(ak) {
+		t.Fatal("IsLoggedIn should return false once the transition expires")
+	}
+	if ts := r1.Tenants(); len(ts) != 0 {
+		t.Fatalf("Unexpected tenants %v.", ts)
+	}
+
+	if err := r1.Login(ak, s1, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if ts := r2.Tenants(); len(ts) != 1 || ts[0] != tenant {
+		t.Fatalf("Unexpected tenants %v.", ts)
+	}
+	if !r2.Terminate(ak) || r1.IsLoggedIn(ak) {
+		t.Fatal("Terminate should log the account out")
+	}
+}
+
+func TestDatabaseRegistryCompareAndSet(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	r := NewDatabaseRegistry(l, db)
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	s1 := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+
+	version, states, err := r.read(ak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Login(ak, s1, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A login decided from the sessions read before the first must be refused.
+	states[ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}] = StateValue{State: StateLoggedIn, UpdatedAt: time.Now()}
+	if err = compareAndSetSessions(db)(ak, version, states); !errors.Is(err, ErrConcurrentModification) {
+		t.Fatalf("Expected concurrent modification, got %v", err)
+	}
+	if current := r.GetStates(ak); len(current) != 1 {
+		t.Fatalf("Unexpected sessions %v.", current)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	now := time.Now()
+	snapshot := func(accountId uint32, service Service, state State, updatedAt time.Time, takenAt time.Time) SessionSnapshotEntity {
+		return SessionSnapshotEntity{TenantId: tenant.Id(), AccountId: accountId, SessionId: uuid.New(), Service: string(service), Region: tenant.Region(), MajorVersion: tenant.MajorVersion(), MinorVersion: tenant.MinorVersion(), State: byte(state), UpdatedAt: updatedAt, TakenAt: takenAt}
+	}
+
+	r := NewMemoryRegistry()
+	held := ServiceKey{SessionId: uuid.New(), Service: ServiceChannel}
+	_ = r.Restore(AccountKey{Tenant: tenant, AccountId: 4}, map[ServiceKey]StateValue{held: {State: StateLoggedIn, UpdatedAt: now}})
+
+	es := []SessionSnapshotEntity{
+		snapshot(1, ServiceChannel, StateLoggedIn, now.Add(-time.Minute), now.Add(-time.Second)),
+		snapshot(2, ServiceLogin, StateTransition, now.Add(-time.Minute), now.Add(-time.Second)),
+		snapshot(3, ServiceChannel, StateLoggedIn, now.Add(-time.Hour), now.Add(-time.Hour)),
+		snapshot(4, ServiceChannel, StateLoggedIn, now.Add(-time.Minute), now.Add(-time.Second)),
+	}
+	restored, loggedOut := restoreSnapshot(r, es, 5*time.Minute, now)
+	if restored != 1 {
+		t.Fatalf("Expected 1 account restored, got %d.", restored)
+	}
+	if !r.IsLoggedIn(AccountKey{Tenant: tenant, AccountId: 1}) {
+		t.Fatal("Account with a recent session should be logged in")
+	}
+	if len(loggedOut) != 2 {
+		t.Fatalf("Unexpected accounts logged out %v.", loggedOut)
+	}
+	for _, ak := range loggedOut {
+		if ak.AccountId != 2 && ak.AccountId != 3 {
+			t.Fatalf("Unexpected account [%d] logged out.", ak.AccountId)
+		}
+		if r.IsLoggedIn(ak) {
+			t.Fatalf("Account [%d] should not be logged in.", ak.AccountId)
+		}
+	}
+	if states := r.GetStates(AccountKey{Tenant: tenant, AccountId: 4}); len(states) != 1 || states[held].State != StateLoggedIn {
+		t.Fatalf("Sessions updated since the snapshot should be kept, got %v.", states)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	previous := Get()
+	defer UseRegistry(previous)
+
+	r := NewMemoryRegistry()
+	UseRegistry(r)
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	sk := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	if err := r.Login(ak, sk, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	other := SessionSnapshotEntity{Instance: "other", TenantId: tenant.Id(), AccountId: 2, SessionId: uuid.New(), Service: ServiceLogin, Region: tenant.Region(), MajorVersion: tenant.MajorVersion(), MinorVersion: tenant.MinorVersion(), State: byte(StateLoggedIn), UpdatedAt: time.Now(), TakenAt: time.Now()}
+	retired := other
+	retired.Instance, retired.AccountId, retired.TakenAt = "retired", 3, time.Now().Add(-time.Hour)
+	db.Create(&[]SessionSnapshotEntity{other, retired})
+
+	t.Setenv(EnvRegistryInstance, "self")
+	for i := 0; i < 2; i++ {
+		if err := Snapshot(l, db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	es, err := consumeSnapshot(db, "self", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 2 {
+		t.Fatalf("Unexpected snapshot %v.", es)
+	}
+	var remaining []SessionSnapshotEntity
+	if err = db.Find(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].Instance != "other" {
+		t.Fatalf("Snapshot of another instance should remain, got %v.", remaining)
+	}
+
+	restored := NewMemoryRegistry()
+	if n, loggedOut := restoreSnapshot(restored, es, time.Minute, time.Now()); n != 1 || len(loggedOut) != 1 || loggedOut[0].AccountId != retired.AccountId {
+		t.Fatalf("Unexpected restoration of [%d] accounts, logging out %v.", n, loggedOut)
+	}
+	if states := restored.GetStates(ak); len(states) != 1 || states[sk].State != StateLoggedIn {
+		t.Fatalf("Unexpected sessions %v.", states)
+	}
+}
+
+func TestIdleSessions(t *testing.T) {
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	s1 := ServiceKey{SessionId: uuid.New(), Service: ServiceChannel}
+	now := time.Now()
+	timeouts := map[Service]time.Duration{ServiceChannel: time.Minute}
+
+	r := NewMemoryRegistry()
+	_ = r.Restore(ak, map[ServiceKey]StateValue{s1: {State: StateLoggedIn, UpdatedAt: now.Add(-time.Hour)}})
+	if as := r.GetExpiredIdle(map[Service]time.Duration{ServiceLogin: time.Minute}); len(as) != 0 {
+		t.Fatalf("Services without a timeout should not be idle, got %v.", as)
+	}
+	if as := r.GetExpiredIdle(timeouts); len(as) != 1 || as[0] != ak {
+		t.Fatalf("Unexpected idle accounts %v.", as)
+	}
+	if err := r.Heartbeat(ak, s1); err != nil {
+		t.Fatal(err)
+	}
+	if r.ExpireIdle(ak, timeouts) || !r.IsLoggedIn(ak) {
+		t.Fatal("Heartbeat should keep the session from being idle")
+	}
+
+	if err := r.Transition(ak, s1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Heartbeat(ak, s1); err == nil {
+		t.Fatal("Heartbeat should not refresh a transitioning session")
+	}
+	if r.ExpireIdle(ak, map[Service]time.Duration{ServiceChannel: -time.Second}) {
+		t.Fatal("Transitioning sessions are expired by the transition timeout alone")
+	}
+
+	db := setupTestDatabase(t)
+	l, _ := test.NewNullLogger()
+	dr := NewDatabaseRegistry(l, db)
+	s2 := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	if err := dr.Login(ak, s2, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	expired := map[Service]time.Duration{ServiceLogin: -time.Second}
+	if as := dr.GetExpiredIdle(expired); len(as) != 1 || as[0] != ak {
+		t.Fatalf("Unexpected idle accounts %v.", as)
+	}
+	if !dr.ExpireIdle(ak, expired) || dr.IsLoggedIn(ak) {
+		t.Fatal("Idle session should be logged out")
+	}
+}
+
+func TestDatabaseRegistryTerminateInstance(t *testing.T) {
+	l, _ := test.NewNullLogger()
+	db := setupTestDatabase(t)
+	r := NewDatabaseRegistry(l, db)
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	instanceId := uuid.New()
+	a1 := AccountKey{Tenant: tenant, AccountId: 1}
+	a2 := AccountKey{Tenant: tenant, AccountId: 2}
+	if err := r.Login(a1, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, instanceId); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Login(a2, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := r.TerminateInstance(tenant, instanceId); len(ids) != 1 || ids[0] != a1.AccountId {
+		t.Fatalf("Unexpected accounts terminated %v.", ids)
+	}
+	if r.IsLoggedIn(a1) || !r.IsLoggedIn(a2) {
+		t.Fatal("Only sessions of the instance should be terminated")
+	}
+	if ids := r.TerminateInstance(tenant, instanceId); len(ids) != 0 {
+		t.Fatalf("Unexpected accounts terminated %v.", ids)
+	}
+}
+
+func TestAdditionalServices(t *testing.T) {
+	c := NewMemoryRegistry()
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	login := ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}
+	channel := ServiceKey{SessionId: uuid.New(), Service: ServiceChannel}
+	cashShop := ServiceKey{SessionId: uuid.New(), Service: ServiceCashShop}
+	portal := ServiceKey{SessionId: uuid.New(), Service: ServiceWebPortal}
+
+	if err := c.Login(ak, portal, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsLoggedIn(ak) {
+		t.Fatal("Web portal session should not log the account in to the game")
+	}
+	if err := c.Transition(ak, portal); err == nil {
+		t.Fatal("Web portal session should not transition")
+	}
+	if err := c.Login(ak, login, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Transition(ak, login); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Login(ak, cashShop, uuid.Nil); err == nil {
+		t.Fatal("Cash shop should not be entered from the login service")
+	}
+	if err := c.Login(ak, channel, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Transition(ak, channel); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Login(ak, cashShop, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	states := c.GetStates(ak)
+	if len(states) != 2 || states[cashShop].State != StateLoggedIn || states[portal].State != StateLoggedIn {
+		t.Fatalf("Unexpected sessions %v.", states)
+	}
+	if err := c.Login(ak, ServiceKey{SessionId: uuid.New(), Service: ServiceLogin}, uuid.Nil); err == nil {
+		t.Fatal("Login should fail while in the cash shop")
+	}
+	if err := c.Login(ak, ServiceKey{SessionId: uuid.New(), Service: "UNKNOWN"}, uuid.Nil); err == nil {
+		t.Fatal("Login to an undefined service should fail")
+	}
+}
+
+func TestSessionServices(t *testing.T) {
+	previous := GetServices()
+	defer UseServices(previous)
+
+	ss := sessionServices(configuration.SessionRegistry{Services: map[string]configuration.Service{
+		"auction": {Exclusive: true, TransitionsFrom: []string{"channel"}, TransitionsTo: []string{"channel"}, IdleTimeout: time.Minute},
+		"channel": {Exclusive: true, TransitionsFrom: []string{"login", "auction"}, TransitionsTo: []string{"auction"}},
+	}})
+	if d := ss["AUCTION"]; d.TransitionTimeout != defaultTransitionTimeout || d.IdleTimeout != time.Minute {
+		t.Fatalf("Unexpected auction definition %v.", d)
+	}
+	if !ss.mayTransition(ServiceChannel, "AUCTION") || ss.mayTransition(ServiceChannel, ServiceCashShop) {
+		t.Fatal("Configured channel definition should replace the built in one")
+	}
+	if _, ok := ss[ServiceWebPortal]; !ok {
+		t.Fatal("Services not configured should keep their built in definition")
+	}
+	if err := ss.Validate(); err != nil {
+		t.Fatalf("Unexpected invalid services: %v", err)
+	}
+	if err := sessionServices(configuration.SessionRegistry{Services: map[string]configuration.Service{
+		"auction": {Exclusive: true, TransitionsFrom: []string{"chanel"}},
+	}}).Validate(); err == nil || !strings.Contains(err.Error(), "[AUCTION] transitions from [CHANEL]") {
+		t.Fatalf("Expected reference to an undefined service to be reported, got %v.", err)
+	}
+
+	UseServices(ss)
+	c := NewMemoryRegistry()
+	tenant, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	ak := AccountKey{Tenant: tenant, AccountId: 1}
+	channel := ServiceKey{SessionId: uuid.New(), Service: ServiceChannel}
+	_ = c.Restore(ak, map[ServiceKey]StateValue{channel: {State: StateTransition, UpdatedAt: time.Now()}})
+	if err := c.Login(ak, ServiceKey{SessionId: uuid.New(), Service: "AUCTION"}, uuid.Nil); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsLoggedIn(ak) {
+		t.Fatal("Auction session should log the account in")
+	}
+}
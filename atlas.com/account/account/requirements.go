@@ -0,0 +1,209 @@
+package account
+
+import (
+	"atlas-account/configuration"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	ViolationNameTooShort        = "NAME_TOO_SHORT"
+	ViolationNameTooLong         = "NAME_TOO_LONG"
+	ViolationNameCharacters      = "NAME_INVALID_CHARACTERS"
+	ViolationNameNotAllowed      = "NAME_NOT_ALLOWED"
+	ViolationPasswordTooShort    = "PASSWORD_TOO_SHORT"
+	ViolationPasswordTooLong     = "PASSWORD_TOO_LONG"
+	ViolationPasswordNoUppercase = "PASSWORD_MISSING_UPPERCASE"
+	ViolationPasswordNoLowercase = "PASSWORD_MISSING_LOWERCASE"
+	ViolationPasswordNoDigit     = "PASSWORD_MISSING_DIGIT"
+	ViolationPasswordNoSymbol    = "PASSWORD_MISSING_SYMBOL"
+	ViolationFieldName           = "name"
+	ViolationFieldPassword       = "password"
+)
+
+// Violation describes one way in which a name or password fails the tenant requirements.
+type Violation struct {
+	Field  string
+	Code   string
+	Detail string
+}
+
+// RequirementsError reports every requirement a name or password fails. It matches ErrInvalidName and
+// ErrInvalidPassword according to the fields violated.
+type RequirementsError struct {
+	Violations []Violation
+}
+
+func (e RequirementsError) Error() string {
+	ds := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		ds = append(ds, v.Detail)
+	}
+	return "requirements not met: " + strings.Join(ds, ", ")
+}
+
+func (e RequirementsError) Is(target error) bool {
+	switch target {
+	case ErrInvalidName:
+		return e.violates(ViolationFieldName)
+	case ErrInvalidPassword:
+		return e.violates(ViolationFieldPassword)
+	}
+	return false
+}
+
+func (e RequirementsError) violates(field string) bool {
+	for _, v := range e.Violations {
+		if v.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func requirementsError(vs ...[]Violation) error {
+	var results []Violation
+	for _, v := range vs {
+		results = append(results, v...)
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return RequirementsError{Violations: results}
+}
+
+var namePatterns = make(map[string]*regexp.Regexp)
+var namePatternsLock sync.RWMutex
+
+// namePattern compiles the characters pattern names must match, anchored so that it must match the whole name. Each
+// pattern is compiled once, normally as configuration is validated at startup, and reused thereafter.
+func namePattern(characters string) (*regexp.Regexp, error) {
+	namePatternsLock.RLock()
+	r, ok := namePatterns[characters]
+	namePatternsLock.RUnlock()
+	if ok {
+		return r, nil
+	}
+
+	r, err := regexp.Compile("^(?:" + characters + ")$")
+	if err != nil {
+		return nil, err
+	}
+	namePatternsLock.Lock()
+	namePatterns[characters] = r
+	namePatternsLock.Unlock()
+	return r, nil
+}
+
+// nameTokens splits a name into the words it is made of, ignoring case. Words are separated by anything other than
+// letters and digits, and end where letters give way to digits, digits to letters, or lowercase to uppercase.
+func nameTokens(name string) []string {
+	var results []string
+	var token []rune
+	var prev rune
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(token) > 0 {
+				results = append(results, strings.ToLower(string(token)))
+			}
+			token = nil
+			continue
+		}
+		if len(token) > 0 && (unicode.IsDigit(r) != unicode.IsDigit(prev) || (unicode.IsUpper(r) && unicode.IsLower(prev))) {
+			results = append(results, strings.ToLower(string(token)))
+			token = nil
+		}
+		token = append(token, r)
+		prev = r
+	}
+	if len(token) > 0 {
+		results = append(results, strings.ToLower(string(token)))
+	}
+	return results
+}
+
+// reserved reports whether the name is, or has among its words, one of the reserved words, ignoring case.
+func reserved(words []string, name string) bool {
+	lower := strings.ToLower(name)
+	tokens := nameTokens(name)
+	for _, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if w == "" {
+			continue
+		}
+		if w == lower {
+			return true
+		}
+		for _, t := range tokens {
+			if w == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateName(c configuration.NameRequirements, name string) ([]Violation, error) {
+	characters, err := namePattern(c.Characters)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Violation
+	length := utf8.RuneCountInString(name)
+	if length < c.MinLength {
+		results = append(results, Violation{Field: ViolationFieldName, Code: ViolationNameTooShort, Detail: fmt.Sprintf("name must be at least %d characters", c.MinLength)})
+	}
+	if length > c.MaxLength {
+		results = append(results, Violation{Field: ViolationFieldName, Code: ViolationNameTooLong, Detail: fmt.Sprintf("name must be at most %d characters", c.MaxLength)})
+	}
+	if length > 0 && !characters.MatchString(name) {
+		results = append(results, Violation{Field: ViolationFieldName, Code: ViolationNameCharacters, Detail: "name contains characters which are not allowed"})
+	}
+	if reserved(c.ReservedWords, name) {
+		results = append(results, Violation{Field: ViolationFieldName, Code: ViolationNameNotAllowed, Detail: "name contains a word which is not allowed"})
+	}
+	return results, nil
+}
+
+func validatePassword(c configuration.PasswordRequirements, password string) []Violation {
+	var results []Violation
+	length := utf8.RuneCountInString(password)
+	if length < c.MinLength {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordTooShort, Detail: fmt.Sprintf("password must be at least %d characters", c.MinLength)})
+	}
+	if length > c.MaxLength {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordTooLong, Detail: fmt.Sprintf("password must be at most %d characters", c.MaxLength)})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if c.RequireUppercase && !upper {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordNoUppercase, Detail: "password must contain an uppercase letter"})
+	}
+	if c.RequireLowercase && !lower {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordNoLowercase, Detail: "password must contain a lowercase letter"})
+	}
+	if c.RequireDigit && !digit {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordNoDigit, Detail: "password must contain a digit"})
+	}
+	if c.RequireSymbol && !symbol {
+		results = append(results, Violation{Field: ViolationFieldPassword, Code: ViolationPasswordNoSymbol, Detail: "password must contain a symbol"})
+	}
+	return results
+}
@@ -4,13 +4,21 @@ import (
 	account2 "atlas-account/kafka/message/account"
 	"atlas-account/kafka/producer"
 	"atlas-account/rest"
+	"errors"
+	"fmt"
 	"github.com/Chronicle20/atlas-model/model"
 	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/jtumidanski/api2go/jsonapi"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func InitResource(si jsonapi.ServerInformation) func(db *gorm.DB) server.RouteInitializer {
@@ -18,14 +26,28 @@ func InitResource(si jsonapi.ServerInformation) func(db *gorm.DB) server.RouteIn
 		return func(router *mux.Router, l logrus.FieldLogger) {
 			register := rest.RegisterHandler(l)(db)(si)
 			registerInput := rest.RegisterInputHandler[RestModel](l)(db)(si)
+			registerCreate := rest.RegisterInputHandler[CreateRestModel](l)(db)(si)
+			registerVerification := rest.RegisterInputHandler[VerificationRestModel](l)(db)(si)
+			registerPassword := rest.RegisterInputHandler[PasswordRestModel](l)(db)(si)
+			registerSlotGrant := rest.RegisterInputHandler[CharacterSlotGrantRestModel](l)(db)(si)
+			registerPrivileges := rest.RegisterInputHandler[PrivilegesRestModel](l)(db)(si)
 
 			r := router.PathPrefix("/accounts").Subrouter()
-			r.HandleFunc("/", registerInput("create_account", handleCreateAccount)).Methods(http.MethodPost)
+			r.HandleFunc("/", registerCreate("create_account", handleCreateAccount)).Methods(http.MethodPost)
 			r.HandleFunc("/", register("get_account_by_name", handleGetAccountByName)).Queries("name", "{name}").Methods(http.MethodGet)
 			r.HandleFunc("/", register("get_accounts", handleGetAccounts)).Methods(http.MethodGet)
 			r.HandleFunc("/{accountId}", register("get_account", handleGetAccountById)).Methods(http.MethodGet)
 			r.HandleFunc("/{accountId}", registerInput("update_account", handleUpdateAccount)).Methods(http.MethodPatch)
+			r.HandleFunc("/{accountId}", register("delete_account", handleDeleteAccount)).Methods(http.MethodDelete)
+			r.HandleFunc("/{accountId}/restore", register("restore_account", handleRestoreAccount)).Methods(http.MethodPost)
 			r.HandleFunc("/{accountId}/session", register("delete_account_session", handleDeleteAccountSession)).Methods(http.MethodDelete)
+			r.HandleFunc("/{accountId}/password", registerPassword("change_account_password", handleChangePassword)).Methods(http.MethodPut)
+			r.HandleFunc("/{accountId}/password/reset", registerPassword("reset_account_password", handleResetPassword)).Methods(http.MethodPost)
+			r.HandleFunc("/{accountId}/character-slots", registerSlotGrant("add_account_character_slots", handleAddCharacterSlots)).Methods(http.MethodPost)
+			r.HandleFunc("/{accountId}/privileges", registerPrivileges("set_account_privileges", handleSetPrivileges)).Methods(http.MethodPut)
+			r.HandleFunc("/{accountId}/privilege-changes", register("get_account_privilege_changes", handleGetPrivilegeChanges)).Methods(http.MethodGet)
+			r.HandleFunc("/{accountId}/pin/verifications", registerVerification("verify_account_pin", handleVerifyPin)).Methods(http.MethodPost)
+			r.HandleFunc("/{accountId}/pic/verifications", registerVerification("verify_account_pic", handleVerifyPic)).Methods(http.MethodPost)
 		}
 	}
 }
@@ -39,7 +61,11 @@ func handleUpdateAccount(d *rest.HandlerDependency, c *rest.HandlerContext, inpu
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
-			a, err := NewProcessor(d.Logger(), d.Context(), d.DB()).Update(accountId, im)
+			a, err := NewProcessor(d.Logger(), d.Context(), d.DB()).UpdateAndEmit(accountId, im)
+			if errors.Is(err, ErrInvalidCharacterSlots) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
 			if err != nil {
 				d.Logger().WithError(err).Errorf("Unable to update account [%d].", accountId)
 				w.WriteHeader(http.StatusNotFound)
@@ -60,13 +86,127 @@ func handleUpdateAccount(d *rest.HandlerDependency, c *rest.HandlerContext, inpu
 	})
 }
 
-func handleCreateAccount(d *rest.HandlerDependency, c *rest.HandlerContext, input RestModel) http.HandlerFunc {
+// TransactionIdHeader carries the transaction id of an account creation accepted, which identifies the status event
+// reporting its outcome.
+const TransactionIdHeader = "X-Transaction-Id"
+
+func handleCreateAccount(d *rest.HandlerDependency, c *rest.HandlerContext, input CreateRestModel) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_ = producer.ProviderImpl(d.Logger())(d.Context())(account2.EnvCommandTopicCreateAccount)(createCommandProvider(input.Name, input.Password))
+		synchronous := false
+		if val := r.URL.Query().Get("sync"); val != "" {
+			var err error
+			synchronous, err = strconv.ParseBool(val)
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Invalid sync parameter [%s].", val)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		p := NewProcessor(d.Logger(), d.Context(), d.DB())
+		if synchronous {
+			createAccount(d, c, p, input)(w, r)
+			return
+		}
+
+		if input.Gender != nil && !validGender(tenant.MustFromContext(d.Context()), *input.Gender) {
+			rest.WriteErrors(d.Logger())(w, http.StatusBadRequest, []jsonapi.Error{createError(http.StatusBadRequest, ErrInvalidGender)})
+			return
+		}
+		err := p.Validate(input.Name, input.Password)
+		var re RequirementsError
+		if errors.As(err, &re) {
+			rest.WriteErrors(d.Logger())(w, http.StatusBadRequest, violationErrors(re, "name", "password"))
+			return
+		}
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Unable to validate account [%s].", input.Name)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if _, err = p.GetByName(input.Name); err == nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		transactionId := uuid.New()
+		if input.TransactionId != nil {
+			transactionId = *input.TransactionId
+		}
+		err = producer.ProviderImpl(d.Logger())(d.Context())(account2.EnvCommandTopicCreateAccount)(createCommandProvider(transactionId, input.Name, input.Password, input.Gender))
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Unable to request creation of account [%s].", input.Name)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(TransactionIdHeader, transactionId.String())
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
 
+// createAccount creates the account while the request waits, responding with the account created.
+func createAccount(d *rest.HandlerDependency, c *rest.HandlerContext, p Processor, input CreateRestModel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var a Model
+		var err error
+		if input.Gender != nil {
+			a, err = p.CreateWithGenderAndEmit(input.Name, input.Password, *input.Gender)
+		} else {
+			a, err = p.CreateAndEmit(input.Name, input.Password)
+		}
+		var re RequirementsError
+		if errors.As(err, &re) {
+			rest.WriteErrors(d.Logger())(w, http.StatusBadRequest, violationErrors(re, "name", "password"))
+			return
+		}
+		if errors.Is(err, ErrInvalidGender) {
+			rest.WriteErrors(d.Logger())(w, http.StatusBadRequest, []jsonapi.Error{createError(http.StatusBadRequest, err)})
+			return
+		}
+		if errors.Is(err, ErrAlreadyExists) || errors.Is(err, ErrNameReserved) {
+			rest.WriteErrors(d.Logger())(w, http.StatusConflict, []jsonapi.Error{createError(http.StatusConflict, err)})
+			return
+		}
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Unable to create account [%s].", input.Name)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		res, err := model.Map(Transform)(model.FixedProvider(a))()
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Creating REST model.")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		si := c.ServerInformation()
+		w.Header().Set("Location", fmt.Sprintf("%s%s%s/%d", si.GetBaseURL(), si.GetPrefix(), res.GetName(), a.Id()))
+		w.Header().Set("Content-Type", rest.ContentType)
+		w.WriteHeader(http.StatusCreated)
+		query := r.URL.Query()
+		queryParams := jsonapi.ParseQueryFields(&query)
+		server.MarshalResponse[RestModel](d.Logger())(w)(si)(queryParams)(res)
+	}
+}
+
+// createError describes a refused account creation as a JSON:API error object.
+func createError(status int, err error) jsonapi.Error {
+	code, _ := createErrorCode(err)
+	je := jsonapi.Error{Status: strconv.Itoa(status), Code: code, Detail: err.Error()}
+	switch {
+	case errors.Is(err, ErrInvalidGender):
+		je.Title = "Invalid gender"
+		je.Source = &jsonapi.ErrorSource{Pointer: "/data/attributes/gender"}
+	case errors.Is(err, ErrAlreadyExists):
+		je.Title = "Name taken"
+		je.Source = &jsonapi.ErrorSource{Pointer: "/data/attributes/name"}
+	case errors.Is(err, ErrNameReserved):
+		je.Title = "Name reserved"
+		je.Source = &jsonapi.ErrorSource{Pointer: "/data/attributes/name"}
+	}
+	return je
+}
+
 type nameHandler func(name string) http.HandlerFunc
 
 func parseName(l logrus.FieldLogger, next nameHandler) http.HandlerFunc {
@@ -98,25 +238,113 @@ func handleGetAccountByName(d *rest.HandlerDependency, c *rest.HandlerContext) h
 }
 
 func handleGetAccounts(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		as, err := NewProcessor(d.Logger(), d.Context(), d.DB()).GetByTenant()
-		if err != nil {
-			d.Logger().WithError(err).Errorf("Unable to locate accounts.")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+	return rest.ParsePage(d.Logger(), func(page rest.Page) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			cr, err := parseCriteria(r)
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Invalid account filter or sort.")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			p := NewProcessor(d.Logger(), d.Context(), d.DB())
+			total, err := p.CountByCriteria(cr)
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Unable to count accounts.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			res, err := model.SliceMap(Transform)(p.ByCriteriaProvider(cr, page.Offset, page.Limit))(model.ParallelMap())()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Unable to locate accounts.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			rest.MarshalPage[[]RestModel](d.Logger())(w, r)(c.ServerInformation())(page, total)(res)
 		}
+	})
+}
 
-		res, err := model.SliceMap(Transform)(model.FixedProvider(as))(model.ParallelMap())()
+// parseCriteria interprets filter[name] (a name prefix), filter[loggedIn], filter[banned], filter[gmLevel],
+// filter[minGmLevel], filter[createdAfter], filter[createdBefore], filter[lastLoginAfter] and filter[lastLoginBefore]
+// (RFC 3339 times), and sort (comma separated fields, descending when prefixed by -).
+func parseCriteria(r *http.Request) (Criteria, error) {
+	query := r.URL.Query()
+	c := Criteria{NamePrefix: query.Get("filter[name]")}
+
+	parseBool := func(key string) (*bool, error) {
+		val := query.Get(key)
+		if val == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(val)
 		if err != nil {
-			d.Logger().WithError(err).Errorf("Creating REST model.")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("%s must be true or false", key)
+		}
+		return &b, nil
+	}
+	parseLevel := func(key string) (*byte, error) {
+		val := query.Get(key)
+		if val == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseUint(val, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be between 0 and 255", key)
+		}
+		b := byte(n)
+		return &b, nil
+	}
+	parseTime := func(key string) (*time.Time, error) {
+		val := query.Get(key)
+		if val == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 time", key)
 		}
+		return &t, nil
+	}
 
-		query := r.URL.Query()
-		queryParams := jsonapi.ParseQueryFields(&query)
-		server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+	var err error
+	if c.LoggedIn, err = parseBool("filter[loggedIn]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.Banned, err = parseBool("filter[banned]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.GMLevel, err = parseLevel("filter[gmLevel]"); err != nil {
+		return Criteria{}, err
 	}
+	if c.MinGMLevel, err = parseLevel("filter[minGmLevel]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.CreatedAfter, err = parseTime("filter[createdAfter]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.CreatedBefore, err = parseTime("filter[createdBefore]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.LastLoginAfter, err = parseTime("filter[lastLoginAfter]"); err != nil {
+		return Criteria{}, err
+	}
+	if c.LastLoginBefore, err = parseTime("filter[lastLoginBefore]"); err != nil {
+		return Criteria{}, err
+	}
+
+	if val := query.Get("sort"); val != "" {
+		for _, f := range strings.Split(val, ",") {
+			s := Sort{Field: strings.TrimPrefix(f, "-"), Descending: strings.HasPrefix(f, "-")}
+			if !ValidSort(s.Field) {
+				return Criteria{}, fmt.Errorf("unknown sort field [%s]", s.Field)
+			}
+			c.Sort = append(c.Sort, s)
+		}
+	}
+	return c, nil
 }
 
 func handleGetAccountById(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
@@ -136,6 +364,81 @@ func handleGetAccountById(d *rest.HandlerDependency, c *rest.HandlerContext) htt
 	})
 }
 
+func handleDeleteAccount(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := NewProcessor(d.Logger(), d.Context(), d.DB()).DeleteAndEmit(accountId)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func handleRestoreAccount(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			a, err := NewProcessor(d.Logger(), d.Context(), d.DB()).RestoreAndEmit(accountId)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if errors.Is(err, ErrNameTaken) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if errors.Is(err, ErrGracePeriodElapsed) {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			res, err := model.Map(Transform)(model.FixedProvider(a))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Creating REST model.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+// violationErrors describes each requirement violated as a JSON:API error object, pointing at the request attribute
+// holding the name or password.
+func violationErrors(e RequirementsError, nameAttribute string, passwordAttribute string) []jsonapi.Error {
+	results := make([]jsonapi.Error, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		attribute := nameAttribute
+		if v.Field == ViolationFieldPassword {
+			attribute = passwordAttribute
+		}
+		je := jsonapi.Error{
+			Status: strconv.Itoa(http.StatusBadRequest),
+			Code:   v.Code,
+			Title:  "Requirement not met",
+			Detail: v.Detail,
+		}
+		if attribute != "" {
+			je.Source = &jsonapi.ErrorSource{Pointer: "/data/attributes/" + attribute}
+		}
+		results = append(results, je)
+	}
+	return results
+}
+
 func handleDeleteAccountSession(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
 	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -144,3 +447,193 @@ func handleDeleteAccountSession(d *rest.HandlerDependency, c *rest.HandlerContex
 		}
 	})
 }
+
+func handleVerifyPin(d *rest.HandlerDependency, c *rest.HandlerContext, input VerificationRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := NewProcessor(d.Logger(), d.Context(), d.DB()).VerifyPin(accountId, input.Value)
+			writeVerification(d, w, accountId, "PIN", err)
+		}
+	})
+}
+
+func handleVerifyPic(d *rest.HandlerDependency, c *rest.HandlerContext, input VerificationRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := NewProcessor(d.Logger(), d.Context(), d.DB()).VerifyPic(accountId, input.Value)
+			writeVerification(d, w, accountId, "PIC", err)
+		}
+	})
+}
+
+func writeVerification(d *rest.HandlerDependency, w http.ResponseWriter, accountId uint32, name string, err error) {
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if errors.Is(err, ErrSecretMismatch) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, ErrSecretNotSet) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	var le SecretLockedError
+	if errors.As(err, &le) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(le.Until).Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	d.Logger().WithError(err).Errorf("Unable to verify %s of account [%d].", name, accountId)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func handleChangePassword(d *rest.HandlerDependency, c *rest.HandlerContext, input PasswordRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := NewProcessor(d.Logger(), d.Context(), d.DB()).ChangePasswordAndEmit(accountId, input.OldPassword, input.NewPassword)
+			writePasswordChange(d, w, accountId, err)
+		}
+	})
+}
+
+func handleResetPassword(d *rest.HandlerDependency, c *rest.HandlerContext, input PasswordRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			err := NewProcessor(d.Logger(), d.Context(), d.DB()).ResetPasswordAndEmit(accountId, input.NewPassword, input.Issuer)
+			writePasswordChange(d, w, accountId, err)
+		}
+	})
+}
+
+func writePasswordChange(d *rest.HandlerDependency, w http.ResponseWriter, accountId uint32, err error) {
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var re RequirementsError
+	if errors.As(err, &re) {
+		rest.WriteErrors(d.Logger())(w, http.StatusBadRequest, violationErrors(re, "", "newPassword"))
+		return
+	}
+	if errors.Is(err, ErrInvalidPassword) || errors.Is(err, ErrIssuerRequired) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrPasswordMismatch) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	var le PasswordLockedError
+	if errors.As(err, &le) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(le.Until).Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	d.Logger().WithError(err).Errorf("Unable to change password of account [%d].", accountId)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func handleSetPrivileges(d *rest.HandlerDependency, c *rest.HandlerContext, input PrivilegesRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			a, err := NewProcessor(d.Logger(), d.Context(), d.DB()).SetPrivilegesAndEmit(accountId, input.GMLevel, input.Roles, input.Issuer, input.Reason)
+			if errors.Is(err, ErrInvalidRole) || errors.Is(err, ErrIssuerRequired) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, ErrConcurrentModification) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			res, err := model.Map(Transform)(model.FixedProvider(a))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Creating REST model.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+func handleGetPrivilegeChanges(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return rest.ParsePage(d.Logger(), func(page rest.Page) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				p := NewProcessor(d.Logger(), d.Context(), d.DB())
+				total, err := p.CountPrivilegeChanges(accountId)
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to count privilege changes for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				res, err := model.SliceMap(TransformPrivilegeChange)(p.PrivilegeChangesProvider(accountId, page.Offset, page.Limit))(model.ParallelMap())()
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to retrieve privilege changes for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				rest.MarshalPage[[]PrivilegeChangeRestModel](d.Logger())(w, r)(c.ServerInformation())(page, total)(res)
+			}
+		})
+	})
+}
+
+func handleAddCharacterSlots(d *rest.HandlerDependency, c *rest.HandlerContext, input CharacterSlotGrantRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			a, err := NewProcessor(d.Logger(), d.Context(), d.DB()).AddCharacterSlotsAndEmit(accountId, input.Amount, input.IdempotencyKey)
+			if errors.Is(err, ErrInvalidCharacterSlots) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, ErrCharacterSlotLimit) || errors.Is(err, ErrIdempotencyConflict) || errors.Is(err, ErrConcurrentModification) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			res, err := model.Map(Transform)(model.FixedProvider(a))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Creating REST model.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
@@ -1,11 +1,17 @@
 package account
 
-import "strconv"
+import (
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
 
 type CreateRestModel struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
-	Gender   byte   `json:"gender"`
+	Gender   *byte  `json:"gender,omitempty"` // The tenant default when omitted.
+	// TransactionId identifies the creation in the status event reporting its outcome. One is chosen when omitted.
+	TransactionId *uuid.UUID `json:"transactionId,omitempty"`
 }
 
 func (r CreateRestModel) SetID(_ string) error {
@@ -17,19 +23,25 @@ func (r CreateRestModel) GetName() string {
 }
 
 type RestModel struct {
-	Id             uint32 `json:"-"`
-	Name           string `json:"name"`
-	Password       string `json:"-"`
-	Pin            string `json:"pin"`
-	Pic            string `json:"pic"`
-	LoggedIn       byte   `json:"loggedIn"`
-	LastLogin      uint64 `json:"lastLogin"`
-	Gender         byte   `json:"gender"`
-	Banned         bool   `json:"banned"`
-	TOS            bool   `json:"tos"`
-	Language       string `json:"language"`
-	Country        string `json:"country"`
-	CharacterSlots int16  `json:"characterSlots"`
+	Id                uint32   `json:"-"`
+	Name              string   `json:"name"`
+	Password          string   `json:"-"`
+	CredentialVersion uint32   `json:"credentialVersion"`
+	Pin               string   `json:"pin,omitempty"`
+	Pic               string   `json:"pic,omitempty"`
+	PinSet            bool     `json:"pinSet"`
+	PicSet            bool     `json:"picSet"`
+	LoggedIn          byte     `json:"loggedIn"`
+	LastLogin         uint64   `json:"lastLogin"`
+	LastLoginIP       string   `json:"lastLoginIp"`
+	Gender            byte     `json:"gender"`
+	Banned            bool     `json:"banned"`
+	TOS               bool     `json:"tos"`
+	Language          string   `json:"language"`
+	Country           string   `json:"country"`
+	CharacterSlots    int16    `json:"characterSlots"`
+	GMLevel           byte     `json:"gmLevel"` // Read only. Privileges change through the privileges endpoint alone.
+	Roles             []string `json:"roles"`   // Read only.
 }
 
 func (r RestModel) GetName() string {
@@ -51,34 +63,162 @@ func (r *RestModel) SetID(idStr string) error {
 
 func Transform(m Model) (RestModel, error) {
 	rm := RestModel{
-		Id:             m.id,
-		Name:           m.name,
-		Password:       m.password,
-		Pin:            m.pin,
-		Pic:            m.pic,
-		LoggedIn:       byte(m.state),
-		LastLogin:      0,
-		Gender:         m.gender,
-		Banned:         false,
-		TOS:            m.tos,
-		Language:       "en",
-		Country:        "us",
-		CharacterSlots: 4,
+		Id:                m.id,
+		Name:              m.name,
+		Password:          m.password,
+		CredentialVersion: m.credentialVersion,
+		PinSet:            m.PinSet(),
+		PicSet:            m.PicSet(),
+		LoggedIn:          byte(m.state),
+		LastLogin:         uint64(m.lastLogin),
+		LastLoginIP:       m.lastLoginIP,
+		Gender:            m.gender,
+		Banned:            m.banned,
+		TOS:               m.tos,
+		Language:          m.language,
+		Country:           m.country,
+		CharacterSlots:    m.characterSlots,
+		GMLevel:           m.gmLevel,
+		Roles:             m.roles,
 	}
 	return rm, nil
 }
 
 func Extract(rm RestModel) (Model, error) {
 	m := Model{
-		id:       rm.Id,
-		name:     rm.Name,
-		password: rm.Password,
-		pin:      rm.Pin,
-		pic:      rm.Pic,
-		state:    State(rm.LoggedIn),
-		gender:   rm.Gender,
-		banned:   rm.Banned,
-		tos:      rm.TOS,
+		id:             rm.Id,
+		name:           rm.Name,
+		password:       rm.Password,
+		pin:            rm.Pin,
+		pic:            rm.Pic,
+		state:          State(rm.LoggedIn),
+		gender:         rm.Gender,
+		banned:         rm.Banned,
+		tos:            rm.TOS,
+		language:       rm.Language,
+		country:        rm.Country,
+		characterSlots: rm.CharacterSlots,
 	}
 	return m, nil
 }
+
+type VerificationRestModel struct {
+	Id    string `json:"-"`
+	Value string `json:"value"`
+}
+
+func (r VerificationRestModel) GetName() string {
+	return "verifications"
+}
+
+func (r VerificationRestModel) GetID() string {
+	return r.Id
+}
+
+func (r *VerificationRestModel) SetID(idStr string) error {
+	r.Id = idStr
+	return nil
+}
+
+type PasswordRestModel struct {
+	Id          string `json:"-"`
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+	Issuer      string `json:"issuer"`
+}
+
+func (r PasswordRestModel) GetName() string {
+	return "passwords"
+}
+
+func (r PasswordRestModel) GetID() string {
+	return r.Id
+}
+
+func (r *PasswordRestModel) SetID(idStr string) error {
+	r.Id = idStr
+	return nil
+}
+
+type CharacterSlotGrantRestModel struct {
+	Id             string `json:"-"`
+	Amount         int16  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey"`
+}
+
+func (r CharacterSlotGrantRestModel) GetName() string {
+	return "character-slot-grants"
+}
+
+func (r CharacterSlotGrantRestModel) GetID() string {
+	return r.Id
+}
+
+func (r *CharacterSlotGrantRestModel) SetID(idStr string) error {
+	r.Id = idStr
+	return nil
+}
+
+type PrivilegesRestModel struct {
+	Id      string   `json:"-"`
+	GMLevel byte     `json:"gmLevel"`
+	Roles   []string `json:"roles"`
+	Issuer  string   `json:"issuer"`
+	Reason  string   `json:"reason"`
+}
+
+func (r PrivilegesRestModel) GetName() string {
+	return "privileges"
+}
+
+func (r PrivilegesRestModel) GetID() string {
+	return r.Id
+}
+
+func (r *PrivilegesRestModel) SetID(idStr string) error {
+	r.Id = idStr
+	return nil
+}
+
+type PrivilegeChangeRestModel struct {
+	Id              uint64    `json:"-"`
+	AccountId       uint32    `json:"accountId"`
+	PreviousGMLevel byte      `json:"previousGmLevel"`
+	GMLevel         byte      `json:"gmLevel"`
+	PreviousRoles   []string  `json:"previousRoles"`
+	Roles           []string  `json:"roles"`
+	Issuer          string    `json:"issuer"`
+	Reason          string    `json:"reason"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+func (r PrivilegeChangeRestModel) GetName() string {
+	return "privilege-changes"
+}
+
+func (r PrivilegeChangeRestModel) GetID() string {
+	return strconv.FormatUint(r.Id, 10)
+}
+
+func (r *PrivilegeChangeRestModel) SetID(idStr string) error {
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return err
+	}
+	r.Id = id
+	return nil
+}
+
+func TransformPrivilegeChange(c PrivilegeChange) (PrivilegeChangeRestModel, error) {
+	return PrivilegeChangeRestModel{
+		Id:              c.id,
+		AccountId:       c.accountId,
+		PreviousGMLevel: c.previousGMLevel,
+		GMLevel:         c.gmLevel,
+		PreviousRoles:   c.previousRoles,
+		Roles:           c.roles,
+		Issuer:          c.issuer,
+		Reason:          c.reason,
+		CreatedAt:       c.createdAt,
+	}, nil
+}
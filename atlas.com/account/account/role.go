@@ -0,0 +1,49 @@
+package account
+
+import (
+	"slices"
+	"strings"
+)
+
+const maximumRoleLength = 32
+
+func normalizeRole(role string) string {
+	return strings.ToUpper(strings.TrimSpace(role))
+}
+
+// normalizeRoles upper cases, deduplicates and sorts role names. Names may hold only letters, digits and underscores.
+func normalizeRoles(roles []string) ([]string, error) {
+	results := make([]string, 0, len(roles))
+	for _, r := range roles {
+		r = normalizeRole(r)
+		if !validRole(r) {
+			return nil, ErrInvalidRole
+		}
+		results = append(results, r)
+	}
+	slices.Sort(results)
+	return slices.Compact(results), nil
+}
+
+func validRole(role string) bool {
+	if role == "" || len(role) > maximumRoleLength {
+		return false
+	}
+	for _, c := range role {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func joinRoles(roles []string) string {
+	return strings.Join(roles, ",")
+}
+
+func splitRoles(roles string) []string {
+	if roles == "" {
+		return []string{}
+	}
+	return strings.Split(roles, ",")
+}
@@ -0,0 +1,171 @@
+package account
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Service string
+
+const (
+	ServiceLogin     = "LOGIN"
+	ServiceChannel   = "CHANNEL"
+	ServiceCashShop  = "CASH_SHOP"
+	ServiceMTS       = "MTS"
+	ServiceWebPortal = "WEB_PORTAL"
+)
+
+// defaultTransitionTimeout is how long a session may transition between services before it is logged out, unless its
+// service says otherwise.
+const defaultTransitionTimeout = 5 * time.Second
+
+// ServiceDefinition describes how sessions of a service behave in the registry.
+//
+// An account holds at most one session of the exclusive services, which are those placing it in the game, while sessions
+// of other services are held alongside them. A service which names services it may be transitioned from is entered only
+// by taking over a session transitioning from one of them, which must in turn name it among the services it may
+// transition to. Otherwise, the service is entered directly, and its sessions may transition only if it names services
+// to transition to.
+type ServiceDefinition struct {
+	Exclusive         bool
+	TransitionsFrom   []Service
+	TransitionsTo     []Service
+	TransitionTimeout time.Duration
+	IdleTimeout       time.Duration // Sessions are never idle without one.
+}
+
+// Services are the services sessions may be held with, by name.
+type Services map[Service]ServiceDefinition
+
+// DefaultServices are the services known absent configuration. Players log in to the login service and move to a
+// channel, between channels and to the cash shop and MTS, while the web portal is used alongside the game.
+func DefaultServices() Services {
+	return Services{
+		ServiceLogin: {
+			Exclusive:         true,
+			TransitionsTo:     []Service{ServiceChannel},
+			TransitionTimeout: defaultTransitionTimeout,
+		},
+		ServiceChannel: {
+			Exclusive:         true,
+			TransitionsFrom:   []Service{ServiceLogin, ServiceChannel, ServiceCashShop, ServiceMTS},
+			TransitionsTo:     []Service{ServiceChannel, ServiceCashShop, ServiceMTS},
+			TransitionTimeout: defaultTransitionTimeout,
+		},
+		ServiceCashShop: {
+			Exclusive:         true,
+			TransitionsFrom:   []Service{ServiceChannel},
+			TransitionsTo:     []Service{ServiceChannel},
+			TransitionTimeout: defaultTransitionTimeout,
+		},
+		ServiceMTS: {
+			Exclusive:         true,
+			TransitionsFrom:   []Service{ServiceChannel},
+			TransitionsTo:     []Service{ServiceChannel},
+			TransitionTimeout: defaultTransitionTimeout,
+		},
+		ServiceWebPortal: {},
+	}
+}
+
+// exclusive reports whether sessions of the service place the account in the game. Sessions of services no longer known
+// are taken to, so they are not overlooked.
+func (s Services) exclusive(service Service) bool {
+	d, ok := s[service]
+	return !ok || d.Exclusive
+}
+
+// mayTransition reports whether a session transitioning from one service may be taken over by another.
+func (s Services) mayTransition(from Service, to Service) bool {
+	fd, ok := s[from]
+	if !ok {
+		return false
+	}
+	td, ok := s[to]
+	if !ok {
+		return false
+	}
+	return slices.Contains(fd.TransitionsTo, to) && slices.Contains(td.TransitionsFrom, from)
+}
+
+// enteredByTransition reports whether sessions of the service begin only by taking over a session transitioning from
+// another service, rather than by logging in.
+func (s Services) enteredByTransition(service Service) bool {
+	return len(s[service].TransitionsFrom) > 0
+}
+
+// Validate verifies that every service named among those another may transition from or to is defined, as transitions
+// naming a service which is not can never take place.
+func (s Services) Validate() error {
+	var unknown []string
+	for service, d := range s {
+		for _, from := range d.TransitionsFrom {
+			if _, ok := s[from]; !ok {
+				unknown = append(unknown, fmt.Sprintf("[%s] transitions from [%s]", service, from))
+			}
+		}
+		for _, to := range d.TransitionsTo {
+			if _, ok := s[to]; !ok {
+				unknown = append(unknown, fmt.Sprintf("[%s] transitions to [%s]", service, to))
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("undefined services referenced: %s", strings.Join(unknown, ", "))
+}
+
+// nonExclusive lists the services whose sessions are held alongside others.
+func (s Services) nonExclusive() []string {
+	var results = make([]string, 0)
+	for service, d := range s {
+		if !d.Exclusive {
+			results = append(results, string(service))
+		}
+	}
+	return results
+}
+
+// TransitionTimeouts are how long sessions of each service may transition before they are logged out.
+func (s Services) TransitionTimeouts() map[Service]time.Duration {
+	var results = make(map[Service]time.Duration)
+	for service, d := range s {
+		results[service] = d.TransitionTimeout
+	}
+	return results
+}
+
+// IdleTimeouts are how long sessions of each service may go without a heartbeat before they are logged out. Services
+// whose sessions are never idle are omitted.
+func (s Services) IdleTimeouts() map[Service]time.Duration {
+	var results = make(map[Service]time.Duration)
+	for service, d := range s {
+		if d.IdleTimeout > 0 {
+			results[service] = d.IdleTimeout
+		}
+	}
+	return results
+}
+
+var services = DefaultServices()
+var servicesLock sync.RWMutex
+
+// GetServices retrieves the services in use.
+func GetServices() Services {
+	servicesLock.RLock()
+	defer servicesLock.RUnlock()
+	return services
+}
+
+// UseServices replaces the services in use. It is meant to be called during startup, before sessions are created.
+func UseServices(s Services) {
+	servicesLock.Lock()
+	defer servicesLock.Unlock()
+	services = s
+}
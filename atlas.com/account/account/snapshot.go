@@ -0,0 +1,179 @@
+package account
+
+import (
+	"atlas-account/configuration"
+	account2 "atlas-account/kafka/message/account"
+	"atlas-account/kafka/producer"
+	"context"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"os"
+	"time"
+)
+
+const EnvRegistryInstance = "REGISTRY_INSTANCE"
+
+// snapshotInstance identifies the instance of the service a snapshot is taken by and restored to, so that replicas do
+// not restore or replace the sessions of one another. It is configured by REGISTRY_INSTANCE, and is the host name
+// otherwise.
+func snapshotInstance(l logrus.FieldLogger) string {
+	if instance := os.Getenv(EnvRegistryInstance); instance != "" {
+		return instance
+	}
+	instance, err := os.Hostname()
+	if err != nil {
+		l.WithError(err).Warnf("Unable to determine host name. Session snapshots will be shared by instances without [%s].", EnvRegistryInstance)
+	}
+	return instance
+}
+
+// Snapshot records the sessions held in memory, replacing any earlier snapshot of this instance, so they may be restored
+// once it restarts.
+func Snapshot(l logrus.FieldLogger, db *gorm.DB) error {
+	r, ok := Get().(*MemoryRegistry)
+	if !ok {
+		return nil
+	}
+
+	instance := snapshotInstance(l)
+	now := time.Now()
+	es := make([]SessionSnapshotEntity, 0)
+	for ak, states := range r.Sessions() {
+		for sk, state := range states {
+			es = append(es, SessionSnapshotEntity{
+				Instance:     instance,
+				TenantId:     ak.Tenant.Id(),
+				AccountId:    ak.AccountId,
+				SessionId:    sk.SessionId,
+				Service:      string(sk.Service),
+				Region:       ak.Tenant.Region(),
+				MajorVersion: ak.Tenant.MajorVersion(),
+				MinorVersion: ak.Tenant.MinorVersion(),
+				State:        byte(state.State),
+				UpdatedAt:    state.UpdatedAt,
+				InstanceId:   state.InstanceId,
+				TakenAt:      now,
+			})
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("instance = ?", instance).Delete(&SessionSnapshotEntity{}).Error
+		if err != nil {
+			return err
+		}
+		if len(es) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&es, 500).Error
+	})
+	if err != nil {
+		return err
+	}
+	l.Infof("Recorded [%d] sessions for restoration.", len(es))
+	return nil
+}
+
+// Restore reinstates the sessions recorded by the last snapshot of this instance into the memory registry, and discards
+// the snapshot. Accounts whose sessions cannot be reinstated, because the snapshot is too old or they were transitioning
+// between services for too long, are logged out. Stale snapshots of other instances, which are not expected to restart,
+// are discarded in the same way.
+func Restore(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) {
+	r, ok := Get().(*MemoryRegistry)
+	if !ok {
+		return
+	}
+
+	c := sessionRegistry(configuration.SessionRegistry{})
+	if sc, err := configuration.Get(); err == nil {
+		c = sessionRegistry(sc.SessionRegistry)
+	} else {
+		l.WithError(err).Warnf("Error reading needed configuration. Using default session registry configuration.")
+	}
+
+	now := time.Now()
+	es, err := consumeSnapshot(db, snapshotInstance(l), now.Add(-c.SnapshotMaxAge))
+	if err != nil {
+		l.WithError(err).Errorf("Unable to read session snapshot.")
+		return
+	}
+	if len(es) == 0 {
+		return
+	}
+
+	restored, loggedOut := restoreSnapshot(r, es, c.SnapshotMaxAge, now)
+	l.Infof("Restored sessions of [%d] accounts from the snapshot.", restored)
+	for _, ak := range loggedOut {
+		tctx := tenant.WithContext(ctx, ak.Tenant)
+		a, err := NewProcessor(l, tctx, db).GetById(ak.AccountId)
+		if err != nil {
+			l.WithError(err).Errorf("Unable to locate account [%d] to log out.", ak.AccountId)
+			continue
+		}
+		l.Debugf("Logging out [%d] [%s]. Triggered by [%s].", a.Id(), a.Name(), "stale session snapshot")
+		err = producer.ProviderImpl(l)(tctx)(account2.EnvEventTopicStatus)(loggedOutEventProvider()(a.Id(), a.Name()))
+		if err != nil {
+			l.WithError(err).Errorf("Unable to emit logout of account [%d].", a.Id())
+		}
+	}
+}
+
+// consumeSnapshot reads and discards the snapshot of the instance, along with those of other instances taken before
+// staleBefore.
+func consumeSnapshot(db *gorm.DB, instance string, staleBefore time.Time) ([]SessionSnapshotEntity, error) {
+	var es []SessionSnapshotEntity
+	err := db.Transaction(func(tx *gorm.DB) error {
+		consumed := func(db *gorm.DB) *gorm.DB {
+			return db.Where("instance = ? OR taken_at < ?", instance, staleBefore)
+		}
+		err := tx.Scopes(consumed).Find(&es).Error
+		if err != nil {
+			return err
+		}
+		return tx.Scopes(consumed).Delete(&SessionSnapshotEntity{}).Error
+	})
+	return es, err
+}
+
+// restoreSnapshot reinstates the sessions of a snapshot into the registry, reporting how many accounts were reinstated
+// and which accounts logged in at the time of the snapshot no longer are. The snapshot is discarded entirely when taken
+// longer than maxAge before now, and sessions transitioning longer than the transition timeout are dropped. Accounts
+// holding sessions updated since the snapshot keep them.
+func restoreSnapshot(r *MemoryRegistry, es []SessionSnapshotEntity, maxAge time.Duration, now time.Time) (int, []AccountKey) {
+	sessions := make(map[AccountKey]map[ServiceKey]StateValue)
+	stale := make(map[AccountKey]bool)
+	for _, e := range es {
+		t, err := tenant.Create(e.TenantId, e.Region, e.MajorVersion, e.MinorVersion)
+		if err != nil {
+			continue
+		}
+		ak := AccountKey{Tenant: t, AccountId: e.AccountId}
+		if _, ok := sessions[ak]; !ok {
+			sessions[ak] = make(map[ServiceKey]StateValue)
+		}
+		sessions[ak][ServiceKey{SessionId: e.SessionId, Service: Service(e.Service)}] = StateValue{State: State(e.State), UpdatedAt: e.UpdatedAt, InstanceId: e.InstanceId}
+		if now.Sub(e.TakenAt) > maxAge {
+			stale[ak] = true
+		}
+	}
+
+	services := GetServices()
+	restored := 0
+	loggedOut := make([]AccountKey, 0)
+	for ak, states := range sessions {
+		wasLoggedIn := maximalState(services, states) > 0
+		if stale[ak] {
+			clear(states)
+		}
+		applyExpireTransition(states, services.TransitionTimeouts(), now)
+		if len(states) > 0 && r.Restore(ak, states) {
+			restored++
+			continue
+		}
+		if wasLoggedIn && !r.IsLoggedIn(ak) {
+			loggedOut = append(loggedOut, ak)
+		}
+	}
+	return restored, loggedOut
+}
@@ -1,7 +1,10 @@
 package account
 
 import (
+	"atlas-account/configuration"
 	"context"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 	"gorm.io/gorm"
@@ -10,25 +13,24 @@ import (
 
 const TimeoutTask = "timeout"
 
+// Timeout logs out sessions which have been transitioning for longer than the transition timeout of their service.
 type Timeout struct {
 	l        logrus.FieldLogger
 	db       *gorm.DB
 	interval time.Duration
-	timeout  time.Duration
 }
 
 func NewTransitionTimeout(l logrus.FieldLogger, db *gorm.DB, interval time.Duration) *Timeout {
-	var to int64 = 5000
-	timeout := time.Duration(to) * time.Millisecond
-	l.Infof("Initializing transition timeout task to run every %dms, timeout session older than %dms", interval.Milliseconds(), timeout.Milliseconds())
-	return &Timeout{l, db, interval, timeout}
+	l.Infof("Initializing transition timeout task to run every %dms.", interval.Milliseconds())
+	return &Timeout{l, db, interval}
 }
 
 func (t *Timeout) Run() {
 	_, span := otel.GetTracerProvider().Tracer("atlas-account").Start(context.Background(), TimeoutTask)
 	defer span.End()
 
-	as, err := GetInTransition(t.timeout)
+	timeouts := GetServices().TransitionTimeouts()
+	as, err := GetInTransition(timeouts)
 	if err != nil {
 		return
 	}
@@ -36,10 +38,121 @@ func (t *Timeout) Run() {
 	t.l.Debugf("Executing timeout task.")
 	for _, a := range as {
 		t.l.Infof("Account [%d] was stuck in transition and will be set to logged out.", a.AccountId)
-		Get().ExpireTransition(a, t.timeout)
+		Get().ExpireTransition(a, timeouts)
 	}
 }
 
 func (t *Timeout) SleepTime() time.Duration {
 	return t.interval
 }
+
+const IdleTask = "idle"
+
+// Idle logs out sessions which have gone without a heartbeat for longer than the idle timeout of their service.
+type Idle struct {
+	l        logrus.FieldLogger
+	db       *gorm.DB
+	interval time.Duration
+}
+
+func NewIdleTimeout(l logrus.FieldLogger, db *gorm.DB, interval time.Duration) *Idle {
+	l.Infof("Initializing idle timeout task to run every %dms.", interval.Milliseconds())
+	return &Idle{l, db, interval}
+}
+
+func (t *Idle) Run() {
+	sctx, span := otel.GetTracerProvider().Tracer("atlas-account").Start(context.Background(), IdleTask)
+	defer span.End()
+
+	timeouts := GetServices().IdleTimeouts()
+	if len(timeouts) == 0 {
+		return
+	}
+
+	t.l.Debugf("Executing idle timeout task.")
+	for _, a := range Get().GetExpiredIdle(timeouts) {
+		t.l.Infof("Account [%d] has an idle session and will be logged out of it.", a.AccountId)
+		err := NewProcessor(t.l, tenant.WithContext(sctx, a.Tenant), t.db).ExpireIdleAndEmit(a.AccountId, timeouts)
+		if err != nil {
+			t.l.WithError(err).Errorf("Unable to log out idle sessions of account [%d].", a.AccountId)
+		}
+	}
+}
+
+func (t *Idle) SleepTime() time.Duration {
+	return t.interval
+}
+
+const PurgeTask = "purge"
+
+// Purge permanently removes deleted accounts once the deletion grace period of their tenant elapses.
+type Purge struct {
+	l        logrus.FieldLogger
+	db       *gorm.DB
+	interval time.Duration
+}
+
+func NewPurge(l logrus.FieldLogger, db *gorm.DB, interval time.Duration) *Purge {
+	l.Infof("Initializing purge task to run every %dms.", interval.Milliseconds())
+	return &Purge{l, db, interval}
+}
+
+func (t *Purge) Run() {
+	_, span := otel.GetTracerProvider().Tracer("atlas-account").Start(context.Background(), PurgeTask)
+	defer span.End()
+
+	t.purgeElapsed(t.deletionPolicy)
+}
+
+// purgeElapsed purges the deleted accounts whose grace period elapsed under the deletion policy of their tenant. Tenants
+// without a policy are left for a later run.
+func (t *Purge) purgeElapsed(policyProvider func(tenantId uuid.UUID) (configuration.Deletion, bool)) {
+	es, err := allDeletedEntities(t.db)()
+	if err != nil {
+		t.l.WithError(err).Errorf("Unable to retrieve deleted accounts.")
+		return
+	}
+
+	t.l.Debugf("Executing purge task.")
+	type tenantPolicy struct {
+		policy configuration.Deletion
+		ok     bool
+	}
+	policies := make(map[uuid.UUID]tenantPolicy)
+	for _, e := range es {
+		tp, cached := policies[e.TenantId]
+		if !cached {
+			tp.policy, tp.ok = policyProvider(e.TenantId)
+			policies[e.TenantId] = tp
+		}
+		if !tp.ok || time.Since(e.DeletedAt.Time) < tp.policy.GracePeriod {
+			continue
+		}
+		err = purge(t.db)(e.TenantId, e.ID)
+		if err != nil {
+			t.l.WithError(err).Errorf("Unable to purge account [%d] of tenant [%s].", e.ID, e.TenantId.String())
+			continue
+		}
+		t.l.Infof("Purged account [%d] [%s] of tenant [%s], deleted at [%s].", e.ID, e.Name, e.TenantId.String(), e.DeletedAt.Time.String())
+	}
+}
+
+// deletionPolicy provides the deletion policy of the tenant. When the configuration cannot be read, the tenant is not
+// purged, as its grace period may be longer than the default.
+func (t *Purge) deletionPolicy(tenantId uuid.UUID) (configuration.Deletion, bool) {
+	c, err := configuration.Get()
+	if err != nil {
+		t.l.WithError(err).Warnf("Error reading needed configuration. Deleted accounts of tenant [%s] will not be purged.", tenantId.String())
+		return configuration.Deletion{}, false
+	}
+	tc, err := c.ForTenant(tenantId)
+	if err != nil {
+		t.l.WithError(err).Warnf("Error reading needed tenant configuration. Deleted accounts of tenant [%s] will not be purged.", tenantId.String())
+		return configuration.Deletion{}, false
+	}
+	return deletionPolicy(tc.Deletion), true
+}
+
+func (t *Purge) SleepTime() time.Duration {
+	return t.interval
+}
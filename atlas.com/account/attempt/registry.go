@@ -0,0 +1,128 @@
+package attempt
+
+import (
+	"github.com/Chronicle20/atlas-tenant"
+	"sync"
+	"time"
+)
+
+var instance *Registry
+var once sync.Once
+
+func Get() *Registry {
+	once.Do(func() {
+		instance = &Registry{
+			lock:    sync.Mutex{},
+			entries: make(map[Key]*entry),
+		}
+	})
+	return instance
+}
+
+type Type string
+
+const (
+	TypeSession = "SESSION"
+	TypeName    = "NAME"
+	TypeIP      = "IP"
+	TypePin     = "PIN"
+	TypePic     = "PIC"
+)
+
+type Key struct {
+	Tenant tenant.Model
+	Type   Type
+	Value  string
+}
+
+type Policy struct {
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+}
+
+type entry struct {
+	failures    []time.Time
+	window      time.Duration
+	lockedUntil time.Time
+}
+
+type Registry struct {
+	lock    sync.Mutex
+	entries map[Key]*entry
+}
+
+// LockedUntil reports whether the key is currently locked out, and until when.
+func (r *Registry) LockedUntil(key Key) (time.Time, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	e, ok := r.entries[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if time.Now().Before(e.lockedUntil) {
+		return e.lockedUntil, true
+	}
+	return time.Time{}, false
+}
+
+// Fail records a failed attempt for the key. When the attempt causes the policy threshold to be reached, the key is
+// locked out for the policy cooldown and the expiry of the lockout is returned.
+func (r *Registry) Fail(key Key, policy Policy) (time.Time, bool) {
+	if policy.Threshold <= 0 {
+		return time.Time{}, false
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	var e *entry
+	var ok bool
+	if e, ok = r.entries[key]; !ok {
+		e = &entry{failures: make([]time.Time, 0)}
+		r.entries[key] = e
+	}
+	e.window = policy.Window
+	e.failures = append(recent(e.failures, now, policy.Window), now)
+
+	if len(e.failures) >= policy.Threshold {
+		e.lockedUntil = now.Add(policy.Cooldown)
+		e.failures = make([]time.Time, 0)
+		return e.lockedUntil, true
+	}
+	return time.Time{}, false
+}
+
+// Reset clears any failures and lockout recorded for the key.
+func (r *Registry) Reset(key Key) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.entries, key)
+}
+
+// Prune drops keys which have neither failures within their window nor an active lockout.
+func (r *Registry) Prune() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now()
+	for k, e := range r.entries {
+		e.failures = recent(e.failures, now, e.window)
+		if len(e.failures) == 0 && !now.Before(e.lockedUntil) {
+			delete(r.entries, k)
+		}
+	}
+}
+
+func recent(failures []time.Time, now time.Time, window time.Duration) []time.Time {
+	results := make([]time.Time, 0)
+	for _, f := range failures {
+		if now.Sub(f) < window {
+			results = append(results, f)
+		}
+	}
+	return results
+}
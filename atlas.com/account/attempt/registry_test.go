@@ -0,0 +1,100 @@
+package attempt
+
+import (
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func sampleKey() Key {
+	t, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	return Key{Tenant: t, Type: TypeName, Value: "name"}
+}
+
+func TestLockout(t *testing.T) {
+	r := Get()
+	k := sampleKey()
+	p := Policy{Threshold: 3, Window: time.Minute, Cooldown: time.Minute}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := r.Fail(k, p); ok {
+			t.Fatalf("Attempt [%d] should not lock out.", i)
+		}
+	}
+	if _, ok := r.LockedUntil(k); ok {
+		t.Fatalf("Key should not be locked out before threshold.")
+	}
+	until, ok := r.Fail(k, p)
+	if !ok {
+		t.Fatalf("Attempt reaching threshold should lock out.")
+	}
+	lu, ok := r.LockedUntil(k)
+	if !ok {
+		t.Fatalf("Key should be locked out.")
+	}
+	if !lu.Equal(until) {
+		t.Fatalf("Lockout mismatch. Expected %v, got %v", until, lu)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	r := Get()
+	k := sampleKey()
+	p := Policy{Threshold: 2, Window: 50 * time.Millisecond, Cooldown: time.Minute}
+
+	_, _ = r.Fail(k, p)
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := r.Fail(k, p); ok {
+		t.Fatalf("Failure outside of window should not count towards threshold.")
+	}
+	if _, ok := r.Fail(k, p); !ok {
+		t.Fatalf("Failures within window should lock out.")
+	}
+}
+
+func TestCooldownExpiry(t *testing.T) {
+	r := Get()
+	k := sampleKey()
+	p := Policy{Threshold: 1, Window: time.Minute, Cooldown: 50 * time.Millisecond}
+
+	_, _ = r.Fail(k, p)
+	if _, ok := r.LockedUntil(k); !ok {
+		t.Fatalf("Key should be locked out.")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := r.LockedUntil(k); ok {
+		t.Fatalf("Lockout should have expired.")
+	}
+	r.Prune()
+	r.lock.Lock()
+	_, ok := r.entries[k]
+	r.lock.Unlock()
+	if ok {
+		t.Fatalf("Expired entry should have been pruned.")
+	}
+}
+
+func TestReset(t *testing.T) {
+	r := Get()
+	k := sampleKey()
+	p := Policy{Threshold: 2, Window: time.Minute, Cooldown: time.Minute}
+
+	_, _ = r.Fail(k, p)
+	r.Reset(k)
+	if _, ok := r.Fail(k, p); ok {
+		t.Fatalf("Reset should clear prior failures.")
+	}
+}
+
+func TestDisabledPolicy(t *testing.T) {
+	r := Get()
+	k := sampleKey()
+	p := Policy{}
+
+	for i := 0; i < 10; i++ {
+		if _, ok := r.Fail(k, p); ok {
+			t.Fatalf("Disabled policy should never lock out.")
+		}
+	}
+}
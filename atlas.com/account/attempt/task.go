@@ -0,0 +1,32 @@
+package attempt
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"time"
+)
+
+const PruneTask = "login_attempt_prune"
+
+type Prune struct {
+	l        logrus.FieldLogger
+	interval time.Duration
+}
+
+func NewPrune(l logrus.FieldLogger, interval time.Duration) *Prune {
+	l.Infof("Initializing login attempt prune task to run every %dms.", interval.Milliseconds())
+	return &Prune{l, interval}
+}
+
+func (t *Prune) Run() {
+	_, span := otel.GetTracerProvider().Tracer("atlas-account").Start(context.Background(), PruneTask)
+	defer span.End()
+
+	t.l.Debugf("Executing login attempt prune task.")
+	Get().Prune()
+}
+
+func (t *Prune) SleepTime() time.Duration {
+	return t.interval
+}
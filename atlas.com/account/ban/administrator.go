@@ -0,0 +1,68 @@
+package ban
+
+import (
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+func create(db *gorm.DB) func(tenant tenant.Model, banType string, accountId uint32, value string, reason byte, issuer string, note string, startsAt time.Time, expiresAt time.Time) (Model, error) {
+	return func(tenant tenant.Model, banType string, accountId uint32, value string, reason byte, issuer string, note string, startsAt time.Time, expiresAt time.Time) (Model, error) {
+		e := &Entity{
+			TenantId:  tenant.Id(),
+			AccountId: accountId,
+			Type:      banType,
+			Value:     value,
+			Reason:    reason,
+			Issuer:    issuer,
+			Note:      note,
+			StartsAt:  startsAt,
+		}
+		if !expiresAt.IsZero() {
+			e.ExpiresAt = &expiresAt
+		}
+
+		err := db.Create(e).Error
+		if err != nil {
+			return Model{}, err
+		}
+		return Make(*e)
+	}
+}
+
+func revoke(db *gorm.DB) func(tenant tenant.Model, id uint32, revokedAt time.Time) error {
+	return func(tenant tenant.Model, id uint32, revokedAt time.Time) error {
+		return db.Model(&Entity{}).Where("tenant_id = ? AND id = ? AND revoked_at IS NULL", tenant.Id(), id).Update("revoked_at", revokedAt).Error
+	}
+}
+
+// PurgeByAccountId removes the account bans of an account being purged. Client bans are retained, as they block clients
+// rather than the account.
+func PurgeByAccountId(db *gorm.DB) func(tenantId uuid.UUID, accountId uint32) error {
+	return func(tenantId uuid.UUID, accountId uint32) error {
+		return db.Where("tenant_id = ? AND account_id = ? AND type = ?", tenantId, accountId, TypeAccount).Delete(&Entity{}).Error
+	}
+}
+
+func Make(e Entity) (Model, error) {
+	m := Model{
+		tenantId:  e.TenantId,
+		id:        e.ID,
+		accountId: e.AccountId,
+		banType:   e.Type,
+		value:     e.Value,
+		reason:    e.Reason,
+		issuer:    e.Issuer,
+		note:      e.Note,
+		startsAt:  e.StartsAt,
+		createdAt: e.CreatedAt,
+	}
+	if e.ExpiresAt != nil {
+		m.expiresAt = *e.ExpiresAt
+	}
+	if e.RevokedAt != nil {
+		m.revokedAt = *e.RevokedAt
+	}
+	return m, nil
+}
@@ -0,0 +1,70 @@
+package ban
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+)
+
+var ErrInvalidValue = errors.New("invalid ban value")
+
+// normalize canonicalizes the value of a client ban so that it can be matched against what clients report.
+func normalize(banType string, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch banType {
+	case TypeIP:
+		if _, n, err := net.ParseCIDR(value); err == nil {
+			return n.String(), nil
+		}
+		if ip := parseIP(value); ip != nil {
+			return ip.String(), nil
+		}
+		return "", ErrInvalidValue
+	case TypeMAC:
+		ms := macAddresses(value)
+		if len(ms) != 1 {
+			return "", ErrInvalidValue
+		}
+		return ms[0], nil
+	case TypeHWID:
+		if value == "" {
+			return "", ErrInvalidValue
+		}
+		return strings.ToUpper(value), nil
+	}
+	return "", ErrInvalidValue
+}
+
+// parseIP parses an address as reported by a client, tolerating an accompanying port.
+func parseIP(value string) net.IP {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	return net.ParseIP(value)
+}
+
+// macAddresses splits the list of MAC addresses reported by a client, normalizing each to upper case hexadecimal
+// without separators. Malformed entries are dropped.
+func macAddresses(value string) []string {
+	results := make([]string, 0)
+	for _, v := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == ' ' }) {
+		v = strings.ToUpper(strings.NewReplacer("-", "", ":", "", ".", "").Replace(v))
+		if _, err := hex.DecodeString(v); err != nil || len(v) != 12 {
+			continue
+		}
+		results = append(results, v)
+	}
+	return results
+}
+
+// matchesIP reports whether an IP ban covers the address, either exactly or by CIDR range.
+func matchesIP(m Model, ip net.IP) bool {
+	if ip == nil || m.Type() != TypeIP {
+		return false
+	}
+	if _, n, err := net.ParseCIDR(m.Value()); err == nil {
+		return n.Contains(ip)
+	}
+	return ip.Equal(net.ParseIP(m.Value()))
+}
@@ -0,0 +1,31 @@
+package ban
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+func Migration(db *gorm.DB) error {
+	return db.AutoMigrate(&Entity{})
+}
+
+type Entity struct {
+	TenantId  uuid.UUID `gorm:"not null;index:idx_bans_tenant_account"`
+	ID        uint32    `gorm:"primaryKey;autoIncrement;not null"`
+	AccountId uint32    `gorm:"not null;index:idx_bans_tenant_account"`
+	Type      string    `gorm:"not null;default:ACCOUNT;index"`
+	Value     string    `gorm:"not null;default:''"`
+	Reason    byte      `gorm:"not null;default:0"`
+	Issuer    string    `gorm:"not null"`
+	Note      string
+	StartsAt  time.Time  `gorm:"not null"`
+	ExpiresAt *time.Time // A nil expiry denotes a permanent ban.
+	RevokedAt *time.Time
+	CreatedAt time.Time // Automatically managed by GORM for creation time
+	UpdatedAt time.Time // Automatically managed by GORM for update time
+}
+
+func (e Entity) TableName() string {
+	return "bans"
+}
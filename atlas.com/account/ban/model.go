@@ -0,0 +1,141 @@
+package ban
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+// Types of ban. Account bans apply to a single account, while the others block any client matching the value.
+const (
+	TypeAccount = "ACCOUNT"
+	TypeIP      = "IP"
+	TypeMAC     = "MAC"
+	TypeHWID    = "HWID"
+)
+
+// TypeOf resolves the type of ban requested, bans not naming one being account bans.
+func TypeOf(banType string) string {
+	banType = strings.ToUpper(strings.TrimSpace(banType))
+	if banType == "" {
+		return TypeAccount
+	}
+	return banType
+}
+
+// Reason codes understood by the client when displaying why an account is blocked.
+const (
+	ReasonNone                   = byte(0)
+	ReasonHacking                = byte(1)
+	ReasonBotting                = byte(2)
+	ReasonAdvertising            = byte(3)
+	ReasonHarassment             = byte(4)
+	ReasonProfanity              = byte(5)
+	ReasonScamming               = byte(6)
+	ReasonMisconduct             = byte(7)
+	ReasonIllegalCashTransaction = byte(8)
+	ReasonIllegalCharging        = byte(9)
+	ReasonTemporaryRequest       = byte(10)
+	ReasonImpersonatingGM        = byte(11)
+	ReasonIllegalProgram         = byte(12)
+	ReasonMegaphoneProfanity     = byte(13)
+)
+
+type Model struct {
+	tenantId  uuid.UUID
+	id        uint32
+	accountId uint32
+	banType   string
+	value     string
+	reason    byte
+	issuer    string
+	note      string
+	startsAt  time.Time
+	expiresAt time.Time
+	revokedAt time.Time
+	createdAt time.Time
+}
+
+func (m Model) TenantId() uuid.UUID {
+	return m.tenantId
+}
+
+func (m Model) Id() uint32 {
+	return m.id
+}
+
+func (m Model) AccountId() uint32 {
+	return m.accountId
+}
+
+func (m Model) Type() string {
+	return m.banType
+}
+
+// Value is the IP address (or CIDR range), MAC address or hardware id blocked by a non-account ban.
+func (m Model) Value() string {
+	return m.value
+}
+
+func (m Model) Reason() byte {
+	return m.reason
+}
+
+func (m Model) Issuer() string {
+	return m.issuer
+}
+
+func (m Model) Note() string {
+	return m.note
+}
+
+func (m Model) StartsAt() time.Time {
+	return m.startsAt
+}
+
+// ExpiresAt is the zero time for permanent bans.
+func (m Model) ExpiresAt() time.Time {
+	return m.expiresAt
+}
+
+// RevokedAt is the zero time unless the ban was lifted before it would otherwise expire.
+func (m Model) RevokedAt() time.Time {
+	return m.revokedAt
+}
+
+func (m Model) Revoked() bool {
+	return !m.revokedAt.IsZero()
+}
+
+func (m Model) CreatedAt() time.Time {
+	return m.createdAt
+}
+
+func (m Model) Permanent() bool {
+	return m.expiresAt.IsZero()
+}
+
+// ActiveAt reports whether the ban is in effect at time t. Temporary bans lapse automatically once their expiry passes.
+func (m Model) ActiveAt(t time.Time) bool {
+	if t.Before(m.startsAt) {
+		return false
+	}
+	if m.Revoked() && !t.Before(m.revokedAt) {
+		return false
+	}
+	return m.Permanent() || t.Before(m.expiresAt)
+}
+
+func Active(m Model) bool {
+	return m.ActiveAt(time.Now())
+}
+
+func Expired(m Model) bool {
+	return !Active(m)
+}
+
+func OfType(banType string) func(m Model) bool {
+	return func(m Model) bool {
+		return m.Type() == banType
+	}
+}
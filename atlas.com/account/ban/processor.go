@@ -0,0 +1,290 @@
+package ban
+
+import (
+	"atlas-account/kafka/message"
+	account2 "atlas-account/kafka/message/account"
+	ban2 "atlas-account/kafka/message/ban"
+	"atlas-account/kafka/producer"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+var ErrNotBanned = errors.New("no active ban")
+var ErrInvalidExpiry = errors.New("ban expiry must be in the future")
+var ErrIssuerRequired = errors.New("issuer required")
+var ErrAccountNotFound = errors.New("account not found")
+
+type Processor interface {
+	GetById(banId uint32) (Model, error)
+	ByIdProvider(banId uint32) model.Provider[Model]
+	GetByAccountId(accountId uint32) ([]Model, error)
+	ByAccountIdProvider(accountId uint32) model.Provider[[]Model]
+	GetByTenant() ([]Model, error)
+	ByTenantProvider() model.Provider[[]Model]
+	GetActiveByAccountId(accountId uint32) (Model, error)
+	ActiveByAccountIdProvider(accountId uint32) model.Provider[Model]
+	GetBannedAccountIds(accountIds []uint32) (map[uint32]bool, error)
+	GetActiveByClient(ipAddress string, macAddress string, hwid string) (Model, error)
+	ActiveByClientProvider(ipAddress string, macAddress string, hwid string) model.Provider[Model]
+	CheckClient(mb *message.Buffer) func(sessionId uuid.UUID, accountName string, ipAddress string, macAddress string, hwid string) (Model, error)
+	CreateAndEmit(accountId uint32, reason byte, issuer string, note string, expiresAt time.Time) (Model, error)
+	Create(mb *message.Buffer) func(accountId uint32, reason byte, issuer string, note string, expiresAt time.Time) (Model, error)
+	CreateClientAndEmit(banType string, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error)
+	CreateClient(mb *message.Buffer) func(banType string, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error)
+	RevokeAndEmit(banId uint32) (Model, error)
+	Revoke(mb *message.Buffer) func(banId uint32) (Model, error)
+}
+
+type ProcessorImpl struct {
+	l   logrus.FieldLogger
+	ctx context.Context
+	db  *gorm.DB
+	t   tenant.Model
+	p   producer.Provider
+}
+
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return &ProcessorImpl{
+		l:   l,
+		ctx: ctx,
+		db:  db,
+		t:   tenant.MustFromContext(ctx),
+		p:   producer.ProviderImpl(l)(ctx),
+	}
+}
+
+func (p *ProcessorImpl) GetById(banId uint32) (Model, error) {
+	return p.ByIdProvider(banId)()
+}
+
+func (p *ProcessorImpl) ByIdProvider(banId uint32) model.Provider[Model] {
+	return model.Map(Make)(entityById(p.t, banId)(p.db))
+}
+
+func (p *ProcessorImpl) GetByAccountId(accountId uint32) ([]Model, error) {
+	return p.ByAccountIdProvider(accountId)()
+}
+
+// ByAccountIdProvider provides the full ban history of an account, most recent first.
+func (p *ProcessorImpl) ByAccountIdProvider(accountId uint32) model.Provider[[]Model] {
+	return model.SliceMap(Make)(entitiesByAccountId(p.t, accountId)(p.db))(model.ParallelMap())
+}
+
+func (p *ProcessorImpl) GetByTenant() ([]Model, error) {
+	return p.ByTenantProvider()()
+}
+
+// ByTenantProvider provides every ban of the tenant, of any type, most recent first.
+func (p *ProcessorImpl) ByTenantProvider() model.Provider[[]Model] {
+	return model.SliceMap(Make)(entitiesByTenant(p.t)(p.db))(model.ParallelMap())
+}
+
+func (p *ProcessorImpl) GetActiveByAccountId(accountId uint32) (Model, error) {
+	return p.ActiveByAccountIdProvider(accountId)()
+}
+
+// ActiveByAccountIdProvider provides the ban currently in effect for an account. When several overlap, the one lasting
+// longest is chosen.
+func (p *ProcessorImpl) ActiveByAccountIdProvider(accountId uint32) model.Provider[Model] {
+	return func() (Model, error) {
+		bs, err := model.FilteredProvider(p.ByAccountIdProvider(accountId), model.Filters[Model](Active))()
+		if err != nil {
+			return Model{}, err
+		}
+		return longest(bs)
+	}
+}
+
+// bannedAccountIdsBatch bounds how many accounts are looked up by a single query.
+const bannedAccountIdsBatch = 500
+
+// GetBannedAccountIds reports which of the accounts given are banned, looking them up in batches rather than one by one.
+func (p *ProcessorImpl) GetBannedAccountIds(accountIds []uint32) (map[uint32]bool, error) {
+	now := time.Now()
+	var results = make(map[uint32]bool)
+	for start := 0; start < len(accountIds); start += bannedAccountIdsBatch {
+		end := min(start+bannedAccountIdsBatch, len(accountIds))
+		ids, err := bannedAccountIds(p.t, accountIds[start:end], now)(p.db)()
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			results[id] = true
+		}
+	}
+	return results, nil
+}
+
+func (p *ProcessorImpl) GetActiveByClient(ipAddress string, macAddress string, hwid string) (Model, error) {
+	return p.ActiveByClientProvider(ipAddress, macAddress, hwid)()
+}
+
+// ActiveByClientProvider provides the IP, MAC or hardware id ban currently in effect for a client, if any.
+func (p *ProcessorImpl) ActiveByClientProvider(ipAddress string, macAddress string, hwid string) model.Provider[Model] {
+	return func() (Model, error) {
+		ip := parseIP(ipAddress)
+		var address string
+		if ip != nil {
+			address = ip.String()
+		}
+		hwid = strings.ToUpper(strings.TrimSpace(hwid))
+		matches := func(m Model) bool {
+			if m.Type() == TypeIP {
+				return matchesIP(m, ip)
+			}
+			return true
+		}
+		bs, err := model.FilteredProvider(model.SliceMap(Make)(entitiesByClient(p.t, address, macAddresses(macAddress), hwid, time.Now())(p.db))(model.ParallelMap()), model.Filters[Model](matches))()
+		if err != nil {
+			return Model{}, err
+		}
+		return longest(bs)
+	}
+}
+
+// CheckClient returns the ban blocking a client from logging in, emitting a BLOCKED status event so that other
+// services may react. ErrNotBanned is returned when the client is not blocked.
+func (p *ProcessorImpl) CheckClient(mb *message.Buffer) func(sessionId uuid.UUID, accountName string, ipAddress string, macAddress string, hwid string) (Model, error) {
+	return func(sessionId uuid.UUID, accountName string, ipAddress string, macAddress string, hwid string) (Model, error) {
+		b, err := p.GetActiveByClient(ipAddress, macAddress, hwid)
+		if err != nil {
+			return Model{}, err
+		}
+		p.l.Infof("Session [%s] attempting login for [%s] blocked by [%s] ban [%d].", sessionId.String(), accountName, b.Type(), b.Id())
+		_ = mb.Put(ban2.EnvEventTopicStatus, blockedEventProvider(b, sessionId, accountName, ipAddress, macAddress, hwid))
+		return b, nil
+	}
+}
+
+func longest(bs []Model) (Model, error) {
+	if len(bs) == 0 {
+		return Model{}, ErrNotBanned
+	}
+	var result = bs[0]
+	for _, b := range bs[1:] {
+		if result.Permanent() {
+			break
+		}
+		if b.Permanent() || b.ExpiresAt().After(result.ExpiresAt()) {
+			result = b
+		}
+	}
+	return result, nil
+}
+
+func (p *ProcessorImpl) CreateAndEmit(accountId uint32, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.Create(buf)(accountId, reason, issuer, note, expiresAt)
+		return err
+	})
+	return result, err
+}
+
+func (p *ProcessorImpl) Create(mb *message.Buffer) func(accountId uint32, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+	return func(accountId uint32, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+		return p.create(mb)(TypeAccount, accountId, "", reason, issuer, note, expiresAt)
+	}
+}
+
+func (p *ProcessorImpl) CreateClientAndEmit(banType string, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+	var result Model
+	err := message.Emit(p.p)(func(buf *message.Buffer) error {
+		var err error
+		result, err = p.CreateClient(buf)(banType, value, reason, issuer, note, expiresAt)
+		return err
+	})
+	return result, err
+}
+
+// CreateClient bans an IP address (or CIDR range), MAC address or hardware id from logging in to any account.
+func (p *ProcessorImpl) CreateClient(mb *message.Buffer) func(banType string, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+	return func(banType string, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+		nv, err := normalize(banType, value)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to ban [%s] [%s].", banType, value)
+			return Model{}, err
+		}
+		return p.create(mb)(banType, 0, nv, reason, issuer, note, expiresAt)
+	}
+}
+
+func (p *ProcessorImpl) create(mb *message.Buffer) func(banType string, accountId uint32, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+	return func(banType string, accountId uint32, value string, reason byte, issuer string, note string, expiresAt time.Time) (Model, error) {
+		if strings.TrimSpace(issuer) == "" {
+			p.l.Errorf("Ban of [%s] [%d] [%s] requested without an issuer.", banType, accountId, value)
+			return Model{}, ErrIssuerRequired
+		}
+		now := time.Now()
+		if !expiresAt.IsZero() && !expiresAt.After(now) {
+			p.l.Errorf("Ban of [%s] [%d] [%s] would expire [%s] before it starts.", banType, accountId, value, expiresAt.String())
+			return Model{}, ErrInvalidExpiry
+		}
+		if banType == TypeAccount {
+			exists, err := accountExists(p.t, accountId)(p.db)()
+			if err != nil {
+				p.l.WithError(err).Errorf("Unable to locate account [%d] being banned.", accountId)
+				return Model{}, err
+			}
+			if !exists {
+				p.l.Errorf("Ban of account [%d] requested, which does not exist.", accountId)
+				return Model{}, ErrAccountNotFound
+			}
+		}
+
+		m, err := create(p.db)(p.t, banType, accountId, value, reason, issuer, note, now, expiresAt)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to ban [%s] [%d] [%s].", banType, accountId, value)
+			return Model{}, err
+		}
+		if m.Permanent() {
+			p.l.Infof("[%s] ban [%d] of [%d] [%s] issued permanently by [%s] for reason [%d].", banType, m.Id(), accountId, value, issuer, reason)
+		} else {
+			p.l.Infof("[%s] ban [%d] of [%d] [%s] issued by [%s] for reason [%d] until [%s].", banType, m.Id(), accountId, value, issuer, reason, expiresAt.String())
+		}
+		_ = mb.Put(ban2.EnvEventTopicStatus, createdEventProvider(m))
+		if m.Type() == TypeAccount {
+			_ = mb.Put(account2.EnvCommandSessionTopic, terminateSessionCommandProvider(accountId))
+		}
+		return m, nil
+	}
+}
+
+func (p *ProcessorImpl) RevokeAndEmit(banId uint32) (Model, error) {
+	return message.EmitWithResult[Model, uint32](p.p)(p.Revoke)(banId)
+}
+
+// Revoke lifts a ban before it would otherwise expire. The ban is retained as history.
+func (p *ProcessorImpl) Revoke(mb *message.Buffer) func(banId uint32) (Model, error) {
+	return func(banId uint32) (Model, error) {
+		b, err := p.GetById(banId)
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to locate ban [%d] being revoked.", banId)
+			return Model{}, err
+		}
+		if !Active(b) {
+			return b, nil
+		}
+
+		err = revoke(p.db)(p.t, banId, time.Now())
+		if err != nil {
+			p.l.WithError(err).Errorf("Unable to revoke ban [%d].", banId)
+			return Model{}, err
+		}
+		b, err = p.GetById(banId)
+		if err != nil {
+			return Model{}, err
+		}
+		p.l.Infof("[%s] ban [%d] of [%d] [%s] revoked.", b.Type(), b.Id(), b.AccountId(), b.Value())
+		_ = mb.Put(ban2.EnvEventTopicStatus, revokedEventProvider(b))
+		return b, nil
+	}
+}
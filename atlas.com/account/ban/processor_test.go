@@ -0,0 +1,300 @@
+package ban
+
+import (
+	"atlas-account/kafka/message"
+	account2 "atlas-account/kafka/message/account"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// accountEntity stands in for the accounts table of the account package, which may not be imported here.
+type accountEntity struct {
+	TenantId  uuid.UUID
+	ID        uint32 `gorm:"primaryKey;autoIncrement:false"`
+	DeletedAt gorm.DeletedAt
+}
+
+func (e accountEntity) TableName() string {
+	return "accounts"
+}
+
+func setupTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	err = db.AutoMigrate(Entity{}, accountEntity{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	return db
+}
+
+// testProcessor provides a processor for a tenant holding accounts 1 and 2, along with account 3 which is deleted.
+func testProcessor(t *testing.T) Processor {
+	l, _ := test.NewNullLogger()
+	st, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	db := setupTestDatabase(t)
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	err := db.Create(&[]accountEntity{{TenantId: st.Id(), ID: 1}, {TenantId: st.Id(), ID: 2}, {TenantId: st.Id(), ID: 3, DeletedAt: deleted}}).Error
+	if err != nil {
+		t.Fatalf("Unable to create accounts: %v", err)
+	}
+	return NewProcessor(l, tenant.WithContext(context.Background(), st), db)
+}
+
+func TestNotBanned(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.GetActiveByAccountId(1)
+	if !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("Expected no active ban, got %v", err)
+	}
+}
+
+func TestTemporaryBan(t *testing.T) {
+	p := testProcessor(t)
+
+	expiresAt := time.Now().Add(time.Hour)
+	b, err := p.Create(message.NewBuffer())(1, ReasonHacking, "gm", "note", expiresAt)
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	if b.Permanent() {
+		t.Fatalf("Ban should not be permanent.")
+	}
+
+	a, err := p.GetActiveByAccountId(1)
+	if err != nil {
+		t.Fatalf("Expected active ban: %v", err)
+	}
+	if a.Id() != b.Id() || a.Reason() != ReasonHacking {
+		t.Fatalf("Active ban mismatch. Expected %d, got %d", b.Id(), a.Id())
+	}
+	if !a.ActiveAt(expiresAt.Add(-time.Minute)) {
+		t.Fatalf("Ban should be active before expiry.")
+	}
+	if a.ActiveAt(expiresAt.Add(time.Minute)) {
+		t.Fatalf("Ban should lapse after expiry.")
+	}
+
+	_, err = p.GetActiveByAccountId(2)
+	if !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("Ban should not apply to other accounts.")
+	}
+}
+
+func TestPermanentBanPreferred(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.Create(message.NewBuffer())(1, ReasonBotting, "gm", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	pb, err := p.Create(message.NewBuffer())(1, ReasonScamming, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+
+	a, err := p.GetActiveByAccountId(1)
+	if err != nil {
+		t.Fatalf("Expected active ban: %v", err)
+	}
+	if a.Id() != pb.Id() || !a.Permanent() {
+		t.Fatalf("Permanent ban should take precedence.")
+	}
+
+	bs, err := p.GetByAccountId(1)
+	if err != nil {
+		t.Fatalf("Unable to retrieve ban history: %v", err)
+	}
+	if len(bs) != 2 {
+		t.Fatalf("History mismatch. Expected %d, got %d", 2, len(bs))
+	}
+}
+
+func TestExpiryInPast(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.Create(message.NewBuffer())(1, ReasonHacking, "gm", "", time.Now().Add(-time.Hour))
+	if err == nil {
+		t.Fatalf("Ban expiring in the past should be rejected.")
+	}
+}
+
+func TestClientBans(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.CreateClient(message.NewBuffer())(TypeIP, "10.0.0.0/24", ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create IP ban: %v", err)
+	}
+	_, err = p.CreateClient(message.NewBuffer())(TypeMAC, "00-1a-2b-3c-4d-5e", ReasonBotting, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create MAC ban: %v", err)
+	}
+	_, err = p.CreateClient(message.NewBuffer())(TypeHWID, "abcdef_123456", ReasonScamming, "gm", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unable to create HWID ban: %v", err)
+	}
+	_, err = p.CreateClient(message.NewBuffer())(TypeIP, "172.16.0.5", ReasonAdvertising, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create IP ban: %v", err)
+	}
+	revoked, err := p.CreateClient(message.NewBuffer())(TypeIP, "172.16.0.6", ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create IP ban: %v", err)
+	}
+	if _, err = p.Revoke(message.NewBuffer())(revoked.Id()); err != nil {
+		t.Fatalf("Unable to revoke IP ban: %v", err)
+	}
+
+	var tests = []struct {
+		name       string
+		ipAddress  string
+		macAddress string
+		hwid       string
+		reason     byte
+		blocked    bool
+	}{
+		{"cidr", "10.0.0.17:8484", "", "", ReasonHacking, true},
+		{"outside cidr", "10.0.1.17", "", "", 0, false},
+		{"exact ip", "172.16.0.5:8484", "", "", ReasonAdvertising, true},
+		{"revoked ip", "172.16.0.6", "", "", 0, false},
+		{"mac in list", "192.168.0.1", "11-11-11-11-11-11, 00:1A:2B:3C:4D:5E", "", ReasonBotting, true},
+		{"hwid", "192.168.0.1", "", "ABCDEF_123456", ReasonScamming, true},
+		{"clean", "192.168.0.1", "11-11-11-11-11-11", "OTHER", 0, false},
+	}
+	for _, tt := range tests {
+		b, err := p.GetActiveByClient(tt.ipAddress, tt.macAddress, tt.hwid)
+		if !tt.blocked {
+			if !errors.Is(err, ErrNotBanned) {
+				t.Errorf("[%s] expected client not to be blocked, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%s] expected client to be blocked, got %v", tt.name, err)
+			continue
+		}
+		if b.Reason() != tt.reason {
+			t.Errorf("[%s] reason mismatch. Expected %d, got %d", tt.name, tt.reason, b.Reason())
+		}
+	}
+
+	_, err = p.GetActiveByAccountId(0)
+	if !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("Client bans should not be reported as account bans.")
+	}
+}
+
+func TestInvalidClientBan(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.CreateClient(message.NewBuffer())(TypeIP, "not an ip", ReasonHacking, "gm", "", time.Time{})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Expected invalid value, got %v", err)
+	}
+	_, err = p.CreateClient(message.NewBuffer())(TypeMAC, "00-1a", ReasonHacking, "gm", "", time.Time{})
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Fatalf("Expected invalid value, got %v", err)
+	}
+}
+
+func TestAccountBanTerminatesSessions(t *testing.T) {
+	p := testProcessor(t)
+
+	mb := message.NewBuffer()
+	_, err := p.Create(mb)(1, ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	if len(mb.GetAll()[account2.EnvCommandSessionTopic]) != 1 {
+		t.Fatalf("Account ban should request session termination.")
+	}
+
+	mb = message.NewBuffer()
+	_, err = p.CreateClient(mb)(TypeIP, "10.0.0.1", ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	if len(mb.GetAll()[account2.EnvCommandSessionTopic]) != 0 {
+		t.Fatalf("Client ban should not request session termination.")
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	p := testProcessor(t)
+
+	b, err := p.Create(message.NewBuffer())(1, ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	r, err := p.Revoke(message.NewBuffer())(b.Id())
+	if err != nil {
+		t.Fatalf("Unable to revoke ban: %v", err)
+	}
+	if !r.Revoked() || Active(r) {
+		t.Fatalf("Revoked ban should no longer be active.")
+	}
+
+	_, err = p.GetActiveByAccountId(1)
+	if !errors.Is(err, ErrNotBanned) {
+		t.Fatalf("Expected no active ban, got %v", err)
+	}
+	bs, err := p.GetByAccountId(1)
+	if err != nil || len(bs) != 1 {
+		t.Fatalf("Revoked ban should be retained as history.")
+	}
+}
+
+func TestGetBannedAccountIds(t *testing.T) {
+	p := testProcessor(t)
+
+	if _, err := p.Create(message.NewBuffer())(1, ReasonHacking, "gm", "", time.Time{}); err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	revoked, err := p.Create(message.NewBuffer())(2, ReasonHacking, "gm", "", time.Time{})
+	if err != nil {
+		t.Fatalf("Unable to create ban: %v", err)
+	}
+	if _, err = p.Revoke(message.NewBuffer())(revoked.Id()); err != nil {
+		t.Fatalf("Unable to revoke ban: %v", err)
+	}
+
+	banned, err := p.GetBannedAccountIds([]uint32{1, 2, 3})
+	if err != nil {
+		t.Fatalf("Unable to retrieve banned accounts: %v", err)
+	}
+	if len(banned) != 1 || !banned[1] {
+		t.Fatalf("Expected only account 1 banned, got %v", banned)
+	}
+}
+
+func TestCreateRequirements(t *testing.T) {
+	p := testProcessor(t)
+
+	if _, err := p.Create(message.NewBuffer())(1, ReasonHacking, " ", "", time.Time{}); !errors.Is(err, ErrIssuerRequired) {
+		t.Fatalf("Expected issuer to be required, got %v", err)
+	}
+	if _, err := p.CreateClient(message.NewBuffer())(TypeIP, "10.0.0.1", ReasonHacking, "", "", time.Time{}); !errors.Is(err, ErrIssuerRequired) {
+		t.Fatalf("Expected issuer to be required, got %v", err)
+	}
+	if _, err := p.Create(message.NewBuffer())(999999, ReasonHacking, "gm", "", time.Time{}); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("Expected unknown account to be refused, got %v", err)
+	}
+	if _, err := p.Create(message.NewBuffer())(3, ReasonHacking, "gm", "", time.Time{}); err != nil {
+		t.Fatalf("Deleted accounts may still be banned, got %v", err)
+	}
+	bs, err := p.GetByAccountId(999999)
+	if err != nil || len(bs) != 0 {
+		t.Fatalf("Refused ban should not be stored.")
+	}
+}
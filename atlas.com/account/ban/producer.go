@@ -0,0 +1,76 @@
+package ban
+
+import (
+	account2 "atlas-account/kafka/message/account"
+	ban2 "atlas-account/kafka/message/ban"
+	"github.com/Chronicle20/atlas-kafka/producer"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+func createdEventProvider(m Model) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(m.AccountId()))
+	value := &ban2.StatusEvent[ban2.CreatedStatusEventBody]{
+		BanId:     m.Id(),
+		AccountId: m.AccountId(),
+		Type:      ban2.EventStatusCreated,
+		Body: ban2.CreatedStatusEventBody{
+			BanType:   m.Type(),
+			Value:     m.Value(),
+			Reason:    m.Reason(),
+			Issuer:    m.Issuer(),
+			StartsAt:  m.StartsAt(),
+			ExpiresAt: m.ExpiresAt(),
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func blockedEventProvider(m Model, sessionId uuid.UUID, accountName string, ipAddress string, macAddress string, hwid string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(m.Id()))
+	value := &ban2.StatusEvent[ban2.BlockedStatusEventBody]{
+		BanId:     m.Id(),
+		AccountId: m.AccountId(),
+		Type:      ban2.EventStatusBlocked,
+		Body: ban2.BlockedStatusEventBody{
+			BanType:     m.Type(),
+			Value:       m.Value(),
+			Reason:      m.Reason(),
+			SessionId:   sessionId,
+			AccountName: accountName,
+			IPAddress:   ipAddress,
+			MACAddress:  macAddress,
+			HWID:        hwid,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func revokedEventProvider(m Model) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(m.AccountId()))
+	value := &ban2.StatusEvent[ban2.RevokedStatusEventBody]{
+		BanId:     m.Id(),
+		AccountId: m.AccountId(),
+		Type:      ban2.EventStatusRevoked,
+		Body: ban2.RevokedStatusEventBody{
+			BanType:   m.Type(),
+			Value:     m.Value(),
+			RevokedAt: m.RevokedAt(),
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+// terminateSessionCommandProvider requests that every session of a newly banned account be logged out.
+func terminateSessionCommandProvider(accountId uint32) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &account2.SessionCommand[account2.LogoutSessionCommandBody]{
+		SessionId: uuid.Nil,
+		AccountId: accountId,
+		Issuer:    account2.SessionCommandIssuerInternal,
+		Type:      account2.SessionCommandTypeLogout,
+		Body:      account2.LogoutSessionCommandBody{},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
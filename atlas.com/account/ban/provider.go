@@ -0,0 +1,98 @@
+package ban
+
+import (
+	"atlas-account/database"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"gorm.io/gorm"
+	"time"
+)
+
+func entityById(tenant tenant.Model, id uint32) database.EntityProvider[Entity] {
+	return func(db *gorm.DB) model.Provider[Entity] {
+		where := map[string]interface{}{"tenant_id": tenant.Id(), "id": id}
+		var result = Entity{}
+		err := db.Where(where).First(&result).Error
+		if err != nil {
+			return model.ErrorProvider[Entity](err)
+		}
+		return model.FixedProvider[Entity](result)
+	}
+}
+
+// accountExists reports whether the account is present, including accounts deleted but not yet purged, which may still be
+// restored. The accounts table is queried directly, as the account package depends on this one.
+func accountExists(tenant tenant.Model, accountId uint32) database.EntityProvider[bool] {
+	return func(db *gorm.DB) model.Provider[bool] {
+		var count int64
+		err := db.Table("accounts").Where("tenant_id = ? AND id = ?", tenant.Id(), accountId).Count(&count).Error
+		if err != nil {
+			return model.ErrorProvider[bool](err)
+		}
+		return model.FixedProvider(count > 0)
+	}
+}
+
+func entitiesByAccountId(tenant tenant.Model, accountId uint32) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		err := db.Where(&Entity{TenantId: tenant.Id(), AccountId: accountId, Type: TypeAccount}).Order("starts_at desc").Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+func entitiesByTenant(tenant tenant.Model) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		err := db.Where(&Entity{TenantId: tenant.Id()}).Order("starts_at desc").Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+// entitiesByClient retrieves the bans in effect at the time given which may block a client, being those matching its IP
+// address exactly, its MAC addresses or its hardware id. IP bans expressed as CIDR ranges are included regardless of the
+// address, to be matched against it by the caller.
+func entitiesByClient(tenant tenant.Model, ipAddress string, macAddresses []string, hwid string, now time.Time) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		q := db.Where("type = ? AND (value = ? OR value LIKE ?)", TypeIP, ipAddress, "%/%")
+		if len(macAddresses) > 0 {
+			q = q.Or("type = ? AND value IN ?", TypeMAC, macAddresses)
+		}
+		if hwid != "" {
+			q = q.Or("type = ? AND value = ?", TypeHWID, hwid)
+		}
+		err := db.Scopes(activeAt(now)).Where("tenant_id = ?", tenant.Id()).Where(q).Order("starts_at desc").Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+// activeAt restricts a query of bans to those in effect at the time given.
+func activeAt(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("starts_at <= ? AND (revoked_at IS NULL OR revoked_at > ?) AND (expires_at IS NULL OR expires_at > ?)", now, now, now)
+	}
+}
+
+// bannedAccountIds retrieves which of the accounts given are banned at the time given.
+func bannedAccountIds(tenant tenant.Model, accountIds []uint32, now time.Time) database.EntityProvider[[]uint32] {
+	return func(db *gorm.DB) model.Provider[[]uint32] {
+		var results = make([]uint32, 0)
+		err := db.Model(&Entity{}).Scopes(activeAt(now)).
+			Where("tenant_id = ? AND type = ? AND account_id IN ?", tenant.Id(), TypeAccount, accountIds).
+			Distinct().Pluck("account_id", &results).Error
+		if err != nil {
+			return model.ErrorProvider[[]uint32](err)
+		}
+		return model.FixedProvider(results)
+	}
+}
@@ -0,0 +1,221 @@
+package ban
+
+import (
+	"atlas-account/rest"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	StatusActive  = "active"
+	StatusExpired = "expired"
+)
+
+func InitResource(si jsonapi.ServerInformation) func(db *gorm.DB) server.RouteInitializer {
+	return func(db *gorm.DB) server.RouteInitializer {
+		return func(router *mux.Router, l logrus.FieldLogger) {
+			register := rest.RegisterHandler(l)(db)(si)
+			registerInput := rest.RegisterInputHandler[RestModel](l)(db)(si)
+
+			r := router.PathPrefix("/bans").Subrouter()
+			r.HandleFunc("/", registerInput("create_ban", handleCreateBan)).Methods(http.MethodPost)
+			r.HandleFunc("/", register("get_bans", handleGetBans)).Methods(http.MethodGet)
+			r.HandleFunc("/{banId}", register("get_ban", handleGetBan)).Methods(http.MethodGet)
+			r.HandleFunc("/{banId}", register("delete_ban", handleDeleteBan)).Methods(http.MethodDelete)
+
+			ar := router.PathPrefix("/accounts/{accountId}/bans").Subrouter()
+			ar.HandleFunc("", registerInput("create_account_ban", handleCreateAccountBan)).Methods(http.MethodPost)
+			ar.HandleFunc("", register("get_account_bans", handleGetAccountBans)).Methods(http.MethodGet)
+			ar.HandleFunc("/{banId}", register("delete_account_ban", handleDeleteAccountBan)).Methods(http.MethodDelete)
+		}
+	}
+}
+
+func handleCreateBan(d *rest.HandlerDependency, c *rest.HandlerContext, input RestModel) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := NewProcessor(d.Logger(), d.Context(), d.DB())
+		var b Model
+		var err error
+		if banType := TypeOf(input.Type); banType == TypeAccount {
+			if input.AccountId == 0 {
+				d.Logger().Errorf("Account ban requested without an account.")
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			b, err = p.CreateAndEmit(input.AccountId, input.Reason, input.Issuer, input.Note, expiresAt(input))
+		} else {
+			b, err = p.CreateClientAndEmit(banType, input.Value, input.Reason, input.Issuer, input.Note, expiresAt(input))
+		}
+		writeCreated(d, c, w, r, b, err)
+	}
+}
+
+func handleCreateAccountBan(d *rest.HandlerDependency, c *rest.HandlerContext, input RestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			b, err := NewProcessor(d.Logger(), d.Context(), d.DB()).CreateAndEmit(accountId, input.Reason, input.Issuer, input.Note, expiresAt(input))
+			writeCreated(d, c, w, r, b, err)
+		}
+	})
+}
+
+func expiresAt(input RestModel) time.Time {
+	if input.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return *input.ExpiresAt
+}
+
+func writeCreated(d *rest.HandlerDependency, c *rest.HandlerContext, w http.ResponseWriter, r *http.Request, b Model, err error) {
+	if errors.Is(err, ErrInvalidValue) || errors.Is(err, ErrInvalidExpiry) || errors.Is(err, ErrIssuerRequired) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, ErrAccountNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := model.Map(Transform)(model.FixedProvider(b))()
+	if err != nil {
+		d.Logger().WithError(err).Errorf("Creating REST model.")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", rest.ContentType)
+	w.WriteHeader(http.StatusCreated)
+	query := r.URL.Query()
+	queryParams := jsonapi.ParseQueryFields(&query)
+	server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+}
+
+func handleGetBans(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filters, err := parseFilters(r)
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Invalid ban filter.")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		bp := model.FilteredProvider(NewProcessor(d.Logger(), d.Context(), d.DB()).ByTenantProvider(), filters)
+		res, err := model.SliceMap(Transform)(bp)(model.ParallelMap())()
+		if err != nil {
+			d.Logger().WithError(err).Errorf("Unable to retrieve bans.")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		queryParams := jsonapi.ParseQueryFields(&query)
+		server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+	}
+}
+
+// parseFilters interprets filter[status] (active or expired) and filter[type] (ACCOUNT, IP, MAC or HWID).
+func parseFilters(r *http.Request) ([]model.Filter[Model], error) {
+	filters := model.Filters[Model]()
+	query := r.URL.Query()
+	switch strings.ToLower(query.Get("filter[status]")) {
+	case "":
+	case StatusActive:
+		filters = append(filters, Active)
+	case StatusExpired:
+		filters = append(filters, Expired)
+	default:
+		return nil, errors.New("unknown status")
+	}
+	if val := strings.ToUpper(query.Get("filter[type]")); val != "" {
+		if val != TypeAccount && val != TypeIP && val != TypeMAC && val != TypeHWID {
+			return nil, errors.New("unknown type")
+		}
+		filters = append(filters, OfType(val))
+	}
+	return filters, nil
+}
+
+func handleGetBan(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseBanId(d.Logger(), func(banId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			res, err := model.Map(Transform)(NewProcessor(d.Logger(), d.Context(), d.DB()).ByIdProvider(banId))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Unable to locate ban [%d].", banId)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+func handleGetAccountBans(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			res, err := model.SliceMap(Transform)(NewProcessor(d.Logger(), d.Context(), d.DB()).ByAccountIdProvider(accountId))(model.ParallelMap())()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Unable to retrieve bans for account [%d].", accountId)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[[]RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+func handleDeleteBan(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseBanId(d.Logger(), func(banId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_, err := NewProcessor(d.Logger(), d.Context(), d.DB()).RevokeAndEmit(banId)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func handleDeleteAccountBan(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return rest.ParseBanId(d.Logger(), func(banId uint32) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				p := NewProcessor(d.Logger(), d.Context(), d.DB())
+				b, err := p.GetById(banId)
+				if err != nil || b.Type() != TypeAccount || b.AccountId() != accountId {
+					d.Logger().Errorf("Unable to locate ban [%d] of account [%d].", banId, accountId)
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, err = p.RevokeAndEmit(banId)
+				if err != nil {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package ban
+
+import (
+	"strconv"
+	"time"
+)
+
+type RestModel struct {
+	Id        uint32     `json:"-"`
+	AccountId uint32     `json:"accountId"`
+	Type      string     `json:"type"`
+	Value     string     `json:"value"`
+	Reason    byte       `json:"reason"`
+	Issuer    string     `json:"issuer"`
+	Note      string     `json:"note"`
+	StartsAt  time.Time  `json:"startsAt"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	Permanent bool       `json:"permanent"`
+	Active    bool       `json:"active"`
+}
+
+func (r RestModel) GetName() string {
+	return "bans"
+}
+
+func (r RestModel) GetID() string {
+	return strconv.Itoa(int(r.Id))
+}
+
+func (r *RestModel) SetID(idStr string) error {
+	if idStr == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return err
+	}
+	r.Id = uint32(id)
+	return nil
+}
+
+func Transform(m Model) (RestModel, error) {
+	rm := RestModel{
+		Id:        m.id,
+		AccountId: m.accountId,
+		Type:      m.banType,
+		Value:     m.value,
+		Reason:    m.reason,
+		Issuer:    m.issuer,
+		Note:      m.note,
+		StartsAt:  m.startsAt,
+		Permanent: m.Permanent(),
+		Active:    Active(m),
+	}
+	if !m.expiresAt.IsZero() {
+		rm.ExpiresAt = &m.expiresAt
+	}
+	if !m.revokedAt.IsZero() {
+		rm.RevokedAt = &m.revokedAt
+	}
+	return rm, nil
+}
@@ -1,7 +1,10 @@
 package configuration
 
 import (
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
 	"sync"
+	"time"
 )
 
 type Registry struct {
@@ -10,7 +13,45 @@ type Registry struct {
 }
 
 type Configuration struct {
-	AutomaticRegister bool `yaml:"automaticRegister"`
+	AutomaticRegister bool                 `yaml:"automaticRegister"`
+	SessionRegistry   SessionRegistry      `yaml:"sessionRegistry"`
+	Defaults          TenantConfiguration  `yaml:"defaults"`
+	Tenants           map[string]yaml.Node `yaml:"tenants"`
+}
+
+// SessionRegistry governs the registry of sessions accounts hold with each service. A snapshot of the registry taken at
+// shutdown is restored at startup, unless taken longer than SnapshotMaxAge before. Services are keyed by name, and
+// replace the built in definition of a service of the same name.
+type SessionRegistry struct {
+	SnapshotMaxAge time.Duration      `yaml:"snapshotMaxAge"`
+	Services       map[string]Service `yaml:"services"`
+}
+
+// Service describes how sessions of a service behave. Sessions of exclusive services place the account in the game, and
+// an account holds one at a time. A service listing services to transition from is entered only by taking over a session
+// transitioning from one of them. Sessions are logged out once transitioning for longer than TransitionTimeout, or going
+// without a heartbeat for longer than IdleTimeout, when set.
+type Service struct {
+	Exclusive         bool          `yaml:"exclusive"`
+	TransitionsFrom   []string      `yaml:"transitionsFrom"`
+	TransitionsTo     []string      `yaml:"transitionsTo"`
+	TransitionTimeout time.Duration `yaml:"transitionTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+}
+
+// ForTenant resolves the policies for the given tenant. Any portion of the defaults may be overridden by an entry keyed
+// by the tenant id under tenants.
+func (c *Configuration) ForTenant(tenantId uuid.UUID) (TenantConfiguration, error) {
+	tc := c.Defaults
+	n, ok := c.Tenants[tenantId.String()]
+	if !ok {
+		return tc, nil
+	}
+	err := n.Decode(&tc)
+	if err != nil {
+		return TenantConfiguration{}, err
+	}
+	return tc, nil
 }
 
 var configurationRegistryOnce sync.Once
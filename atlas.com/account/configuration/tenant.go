@@ -0,0 +1,96 @@
+package configuration
+
+import "time"
+
+type TenantConfiguration struct {
+	AccountDefaults           AccountDefaults           `yaml:"accountDefaults"`
+	CharacterSlots            CharacterSlots            `yaml:"characterSlots"`
+	LoginAttempts             LoginAttempts             `yaml:"loginAttempts"`
+	SecondaryPasswordAttempts SecondaryPasswordAttempts `yaml:"secondaryPasswordAttempts"`
+	PasswordHashing           PasswordHashing           `yaml:"passwordHashing"`
+	Deletion                  Deletion                  `yaml:"deletion"`
+	NameRequirements          NameRequirements          `yaml:"nameRequirements"`
+	PasswordRequirements      PasswordRequirements      `yaml:"passwordRequirements"`
+}
+
+// AccountDefaults are given to accounts which have not chosen otherwise. Values left empty are derived from the tenant
+// region.
+type AccountDefaults struct {
+	Language       string `yaml:"language"`
+	Country        string `yaml:"country"`
+	CharacterSlots int16  `yaml:"characterSlots"`
+}
+
+// CharacterSlots bounds the character slots an account may hold. A zero Maximum falls back to the default maximum.
+type CharacterSlots struct {
+	Maximum int16 `yaml:"maximum"`
+}
+
+// Deletion governs deleted accounts. They may be restored until GracePeriod elapses, after which they are purged. While
+// ReserveName is set, the name of a deleted account may not be registered again until it is purged. A zero GracePeriod
+// falls back to the default.
+type Deletion struct {
+	GracePeriod time.Duration `yaml:"gracePeriod"`
+	ReserveName bool          `yaml:"reserveName"`
+}
+
+// NameRequirements govern the names of new accounts. Characters is a regular expression the whole name must match, and
+// a name which is, or has among its words, any of the ReservedWords, ignoring case, is refused. Zero lengths and an
+// empty Characters fall back to the defaults.
+type NameRequirements struct {
+	MinLength     int      `yaml:"minLength"`
+	MaxLength     int      `yaml:"maxLength"`
+	Characters    string   `yaml:"characters"`
+	ReservedWords []string `yaml:"reservedWords"`
+}
+
+// PasswordRequirements govern passwords chosen for new accounts, and when a password is changed or reset. Zero lengths
+// fall back to the defaults.
+type PasswordRequirements struct {
+	MinLength        int  `yaml:"minLength"`
+	MaxLength        int  `yaml:"maxLength"`
+	RequireUppercase bool `yaml:"requireUppercase"`
+	RequireLowercase bool `yaml:"requireLowercase"`
+	RequireDigit     bool `yaml:"requireDigit"`
+	RequireSymbol    bool `yaml:"requireSymbol"`
+}
+
+type LoginAttempts struct {
+	Session AttemptPolicy `yaml:"session"`
+	Name    AttemptPolicy `yaml:"name"`
+	IP      AttemptPolicy `yaml:"ip"`
+}
+
+// SecondaryPasswordAttempts limits failed PIN and PIC entries, tracked per account.
+type SecondaryPasswordAttempts struct {
+	Pin AttemptPolicy `yaml:"pin"`
+	Pic AttemptPolicy `yaml:"pic"`
+}
+
+// AttemptPolicy locks out further attempts for Cooldown once Threshold failures occur within a sliding Window. A zero
+// Threshold disables the policy.
+type AttemptPolicy struct {
+	Threshold int           `yaml:"threshold"`
+	Window    time.Duration `yaml:"window"`
+	Cooldown  time.Duration `yaml:"cooldown"`
+}
+
+// PasswordHashing selects the algorithm (bcrypt or argon2id) and parameters used for newly stored hashes. Zero valued
+// parameters fall back to the algorithm defaults.
+type PasswordHashing struct {
+	Algorithm string          `yaml:"algorithm"`
+	Bcrypt    BcryptHashing   `yaml:"bcrypt"`
+	Argon2id  Argon2idHashing `yaml:"argon2id"`
+}
+
+type BcryptHashing struct {
+	Cost int `yaml:"cost"`
+}
+
+type Argon2idHashing struct {
+	Memory      uint32 `yaml:"memory"`
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+	SaltLength  uint32 `yaml:"saltLength"`
+	KeyLength   uint32 `yaml:"keyLength"`
+}
@@ -0,0 +1,107 @@
+package credential
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+const (
+	defaultArgon2idMemory      = 64 * 1024
+	defaultArgon2idIterations  = 3
+	defaultArgon2idParallelism = 2
+	defaultArgon2idSaltLength  = 16
+	defaultArgon2idKeyLength   = 32
+)
+
+// argon2idVerifier produces and verifies hashes in the PHC string format, $argon2id$v=19$m=<memory>,t=<iterations>,
+// p=<parallelism>$<salt>$<key>, with the salt and key encoded as unpadded base64.
+type argon2idVerifier struct {
+}
+
+type argon2idHash struct {
+	params Argon2idParameters
+	salt   []byte
+	key    []byte
+}
+
+func (v argon2idVerifier) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (v argon2idVerifier) Verify(hash string, secret string) bool {
+	h, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	k := argon2.IDKey([]byte(secret), h.salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, uint32(len(h.key)))
+	return subtle.ConstantTimeCompare(k, h.key) == 1
+}
+
+func (v argon2idVerifier) Hash(secret string, p Policy) (string, error) {
+	ap := p.Argon2id.withDefaults()
+	salt := make([]byte, ap.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	k := argon2.IDKey([]byte(secret), salt, ap.Iterations, ap.Memory, ap.Parallelism, ap.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, ap.Memory, ap.Iterations, ap.Parallelism, base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(k)), nil
+}
+
+func (v argon2idVerifier) Weaker(hash string, p Policy) bool {
+	h, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	ap := p.Argon2id.withDefaults()
+	return h.params.Memory < ap.Memory || h.params.Iterations < ap.Iterations || h.params.Parallelism < ap.Parallelism || uint32(len(h.salt)) < ap.SaltLength || uint32(len(h.key)) < ap.KeyLength
+}
+
+func (p Argon2idParameters) withDefaults() Argon2idParameters {
+	if p.Memory == 0 {
+		p.Memory = defaultArgon2idMemory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = defaultArgon2idIterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = defaultArgon2idParallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = defaultArgon2idSaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = defaultArgon2idKeyLength
+	}
+	return p
+}
+
+func decodeArgon2id(hash string) (argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idHash{}, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idHash{}, errors.New("unsupported argon2id version")
+	}
+	var h argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &h.params.Memory, &h.params.Iterations, &h.params.Parallelism); err != nil {
+		return argon2idHash{}, err
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, err
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return argon2idHash{}, err
+	}
+	if len(h.key) == 0 {
+		return argon2idHash{}, errors.New("malformed argon2id hash")
+	}
+	return h, nil
+}
@@ -0,0 +1,30 @@
+package credential
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+type bcryptVerifier struct {
+}
+
+func (v bcryptVerifier) Identifies(hash string) bool {
+	return strings.HasPrefix(hash, "$2")
+}
+
+func (v bcryptVerifier) Verify(hash string, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+func (v bcryptVerifier) Hash(secret string, p Policy) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(secret), p.Bcrypt.cost())
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+func (v bcryptVerifier) Weaker(hash string, p Policy) bool {
+	c, err := bcrypt.Cost([]byte(hash))
+	return err != nil || c < p.Bcrypt.cost()
+}
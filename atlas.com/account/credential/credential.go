@@ -0,0 +1,84 @@
+package credential
+
+import (
+	"errors"
+	"sync"
+)
+
+type Format string
+
+const (
+	FormatBcrypt   = Format("bcrypt")
+	FormatArgon2id = Format("argon2id")
+	FormatSHA1     = Format("sha1")
+	FormatSHA512   = Format("sha512")
+)
+
+var ErrUnknownFormat = errors.New("unknown credential format")
+var ErrMismatch = errors.New("credential mismatch")
+
+// Verifier checks secrets against hashes of a single format.
+type Verifier interface {
+	// Identifies reports whether the hash was produced in the format of this verifier.
+	Identifies(hash string) bool
+	// Verify reports whether the secret produces the hash.
+	Verify(hash string, secret string) bool
+}
+
+type registration struct {
+	format   Format
+	verifier Verifier
+}
+
+var lock sync.RWMutex
+var verifiers = []registration{
+	{format: FormatBcrypt, verifier: bcryptVerifier{}},
+	{format: FormatArgon2id, verifier: argon2idVerifier{}},
+	{format: FormatSHA512, verifier: sha512Verifier{}},
+	{format: FormatSHA1, verifier: sha1Verifier{}},
+}
+
+// Register adds a verifier for a format, replacing any verifier already registered for it. Formats are identified in
+// the order they were registered.
+func Register(format Format, v Verifier) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for i, r := range verifiers {
+		if r.format == format {
+			verifiers[i].verifier = v
+			return
+		}
+	}
+	verifiers = append(verifiers, registration{format: format, verifier: v})
+}
+
+// Identify determines the format which produced the hash.
+func Identify(hash string) (Format, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for _, r := range verifiers {
+		if r.verifier.Identifies(hash) {
+			return r.format, nil
+		}
+	}
+	return "", ErrUnknownFormat
+}
+
+// Verify checks the secret against the hash using the verifier of its format, returning the format on success.
+func Verify(hash string, secret string) (Format, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	for _, r := range verifiers {
+		if !r.verifier.Identifies(hash) {
+			continue
+		}
+		if !r.verifier.Verify(hash, secret) {
+			return r.format, ErrMismatch
+		}
+		return r.format, nil
+	}
+	return "", ErrUnknownFormat
+}
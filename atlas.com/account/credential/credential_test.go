@@ -0,0 +1,163 @@
+package credential
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	bh, err := Hash("password", Policy{})
+	if err != nil {
+		t.Fatalf("Unable to hash: %v", err)
+	}
+
+	var tests = []struct {
+		name   string
+		hash   string
+		format Format
+	}{
+		{"bcrypt", bh, FormatBcrypt},
+		{"argon2id", "$argon2id$v=19$m=64,t=2,p=1$c29tZXNhbHQ$Bo1ismRVk2qm6+YAYLCmWHDb+j3fjUH3", FormatArgon2id},
+		{"bare sha1", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", FormatSHA1},
+		{"prefixed sha1", "$sha1$5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8", FormatSHA1},
+		{"bare sha512", "b109f3bbbc244eb82441917ed06d618b9008dd09b3befd1b5e07394c706a8bb980b1d7785e5976ec049b46df5f1326af5a2ea6d103fd07c95385ffab0cacbc86", FormatSHA512},
+		{"salted sha512", "$sha512$a1b2c3$dc8da33b67ebdbfed3576ae7c6cec73168fcbd1aef86089c579b5997a2a3c6c09bdace95de7ce309b663becaa8f83d1c9e3e258127e9922e8cd488195558e999", FormatSHA512},
+	}
+	for _, tt := range tests {
+		f, err := Verify(tt.hash, "password")
+		if err != nil {
+			t.Errorf("[%s] expected password to verify, got %v", tt.name, err)
+		}
+		if f != tt.format {
+			t.Errorf("[%s] format mismatch. Expected %s, got %s", tt.name, tt.format, f)
+		}
+		_, err = Verify(tt.hash, "wrong")
+		if !errors.Is(err, ErrMismatch) {
+			t.Errorf("[%s] expected mismatch, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := Verify("password", "password")
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("Plaintext should not be accepted as a hash, got %v", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptPolicy := Policy{Algorithm: FormatBcrypt, Bcrypt: BcryptParameters{Cost: 6}}
+	argon2idPolicy := Policy{Algorithm: FormatArgon2id, Argon2id: Argon2idParameters{Memory: 1024, Iterations: 2, Parallelism: 1}}
+
+	bh, _ := Hash("password", bcryptPolicy)
+	ah, _ := Hash("password", argon2idPolicy)
+
+	var tests = []struct {
+		name   string
+		hash   string
+		policy Policy
+		rehash bool
+	}{
+		{"current bcrypt", bh, bcryptPolicy, false},
+		{"stronger bcrypt", bh, Policy{Bcrypt: BcryptParameters{Cost: 5}}, false},
+		{"weaker bcrypt", bh, Policy{Bcrypt: BcryptParameters{Cost: 7}}, true},
+		{"bcrypt to argon2id", bh, argon2idPolicy, true},
+		{"current argon2id", ah, argon2idPolicy, false},
+		{"weaker argon2id memory", ah, Policy{Algorithm: FormatArgon2id, Argon2id: Argon2idParameters{Memory: 2048, Iterations: 2, Parallelism: 1}}, true},
+		{"weaker argon2id iterations", ah, Policy{Algorithm: FormatArgon2id, Argon2id: Argon2idParameters{Memory: 1024, Iterations: 3, Parallelism: 1}}, true},
+		{"argon2id to bcrypt", ah, bcryptPolicy, true},
+		{"legacy", "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8", bcryptPolicy, true},
+	}
+	for _, tt := range tests {
+		if NeedsRehash(tt.hash, tt.policy) != tt.rehash {
+			t.Errorf("[%s] expected rehash [%t].", tt.name, tt.rehash)
+		}
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h, err := Hash("password", Policy{Algorithm: FormatArgon2id, Argon2id: Argon2idParameters{Memory: 1024, Iterations: 1, Parallelism: 1}})
+	if err != nil {
+		t.Fatalf("Unable to hash: %v", err)
+	}
+	if _, err = Verify(h, "password"); err != nil {
+		t.Fatalf("Expected password to verify, got %v", err)
+	}
+	if _, err = Verify(h, "wrong"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("Expected mismatch, got %v", err)
+	}
+}
+
+func TestUnsupportedAlgorithm(t *testing.T) {
+	_, err := Hash("password", Policy{Algorithm: FormatSHA1})
+	if !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("Legacy algorithms should not produce new hashes, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		algorithm Format
+		valid     bool
+	}{
+		{"", true},
+		{FormatBcrypt, true},
+		{FormatArgon2id, true},
+		{FormatSHA1, false},
+		{"Bcrypt", false},
+		{"scrypt", false},
+	}
+	for _, tt := range tests {
+		err := Policy{Algorithm: tt.algorithm}.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("[%s] expected algorithm to be valid, got %v", tt.algorithm, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("[%s] expected algorithm to be unsupported, got %v", tt.algorithm, err)
+		}
+	}
+}
+
+func TestValidateBcryptCost(t *testing.T) {
+	var tests = []struct {
+		cost  int
+		valid bool
+	}{
+		{0, true},
+		{bcrypt.MinCost, true},
+		{bcrypt.MaxCost, true},
+		{bcrypt.MinCost - 1, false},
+		{bcrypt.MaxCost + 1, false},
+		{-1, false},
+	}
+	for _, tt := range tests {
+		err := Policy{Algorithm: FormatBcrypt, Bcrypt: BcryptParameters{Cost: tt.cost}}.Validate()
+		if tt.valid && err != nil {
+			t.Errorf("[%d] expected cost to be valid, got %v", tt.cost, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidParameters) {
+			t.Errorf("[%d] expected cost to be invalid, got %v", tt.cost, err)
+		}
+	}
+}
+
+type plainVerifier struct {
+}
+
+func (v plainVerifier) Identifies(hash string) bool {
+	return len(hash) > 7 && hash[:7] == "$plain$"
+}
+
+func (v plainVerifier) Verify(hash string, secret string) bool {
+	return hash[7:] == secret
+}
+
+func TestRegister(t *testing.T) {
+	Register("plain", plainVerifier{})
+
+	f, err := Verify("$plain$password", "password")
+	if err != nil || f != "plain" {
+		t.Fatalf("Registered verifier should be used, got %s %v", f, err)
+	}
+}
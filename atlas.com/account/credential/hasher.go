@@ -0,0 +1,93 @@
+package credential
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported hashing algorithm")
+var ErrInvalidParameters = errors.New("invalid hashing parameters")
+
+// Policy selects the algorithm and parameters used to produce new hashes. Zero valued parameters fall back to the
+// algorithm defaults.
+type Policy struct {
+	Algorithm Format
+	Bcrypt    BcryptParameters
+	Argon2id  Argon2idParameters
+}
+
+type BcryptParameters struct {
+	Cost int
+}
+
+// Argon2idParameters are expressed as memory in KiB, iterations (time cost), parallelism (threads), and the salt and
+// key lengths in bytes.
+type Argon2idParameters struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func (p Policy) algorithm() Format {
+	if p.Algorithm == "" {
+		return FormatBcrypt
+	}
+	return p.Algorithm
+}
+
+// Validate reports whether the policy calls for an algorithm new hashes may be produced with, and parameters that
+// algorithm accepts.
+func (p Policy) Validate() error {
+	if _, ok := hashers[p.algorithm()]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, p.algorithm())
+	}
+	if c := p.Bcrypt.cost(); c < bcrypt.MinCost || c > bcrypt.MaxCost {
+		return fmt.Errorf("%w: bcrypt cost %d outside %d..%d", ErrInvalidParameters, c, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return nil
+}
+
+// Hasher produces hashes in a single format.
+type Hasher interface {
+	Hash(secret string, p Policy) (string, error)
+	// Weaker reports whether the hash was produced with weaker parameters than the policy calls for.
+	Weaker(hash string, p Policy) bool
+}
+
+var hashers = map[Format]Hasher{
+	FormatBcrypt:   bcryptVerifier{},
+	FormatArgon2id: argon2idVerifier{},
+}
+
+// Hash produces a hash of the secret using the algorithm and parameters of the policy.
+func Hash(secret string, p Policy) (string, error) {
+	h, ok := hashers[p.algorithm()]
+	if !ok {
+		return "", ErrUnsupportedAlgorithm
+	}
+	return h.Hash(secret, p)
+}
+
+// NeedsRehash reports whether the hash was produced by an algorithm other than the one the policy calls for, or with
+// weaker parameters, and so should be replaced the next time the secret is presented.
+func NeedsRehash(hash string, p Policy) bool {
+	f, err := Identify(hash)
+	if err != nil || f != p.algorithm() {
+		return true
+	}
+	h, ok := hashers[f]
+	if !ok {
+		return true
+	}
+	return h.Weaker(hash, p)
+}
+
+func (p BcryptParameters) cost() int {
+	if p.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return p.Cost
+}
@@ -0,0 +1,65 @@
+package credential
+
+import (
+	"crypto/sha1"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// sha1Verifier verifies the unsalted hexadecimal SHA-1 digests used by OdinMS derived servers. Hashes are accepted
+// either bare, or prefixed as $sha1$<digest>.
+type sha1Verifier struct {
+}
+
+func (v sha1Verifier) Identifies(hash string) bool {
+	return isHex(strings.TrimPrefix(hash, "$sha1$"), sha1.Size)
+}
+
+func (v sha1Verifier) Verify(hash string, secret string) bool {
+	d := sha1.Sum([]byte(secret))
+	return equalHex(strings.TrimPrefix(hash, "$sha1$"), d[:])
+}
+
+// sha512Verifier verifies the hexadecimal SHA-512 digests of the secret followed by a salt used by HeavenMS derived
+// servers. Hashes are accepted as $sha512$<salt>$<digest>, or bare when unsalted.
+type sha512Verifier struct {
+}
+
+func (v sha512Verifier) Identifies(hash string) bool {
+	_, d, ok := splitSalted(hash)
+	return ok && isHex(d, sha512.Size)
+}
+
+func (v sha512Verifier) Verify(hash string, secret string) bool {
+	salt, digest, ok := splitSalted(hash)
+	if !ok {
+		return false
+	}
+	d := sha512.Sum512([]byte(secret + salt))
+	return equalHex(digest, d[:])
+}
+
+func splitSalted(hash string) (string, string, bool) {
+	if !strings.HasPrefix(hash, "$sha512$") {
+		return "", hash, true
+	}
+	parts := strings.SplitN(strings.TrimPrefix(hash, "$sha512$"), "$", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func isHex(value string, size int) bool {
+	if len(value) != size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
+
+func equalHex(value string, digest []byte) bool {
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(value)), []byte(hex.EncodeToString(digest))) == 1
+}
@@ -113,7 +113,7 @@ func Connect(l logrus.FieldLogger, configurators ...Configurator) *gorm.DB {
 	var db *gorm.DB
 	tryToConnect := func(attempt int) (bool, error) {
 		var err error
-		db, err = gorm.Open(postgres.Open(dsnBuilder.Build()), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(dsnBuilder.Build()), &gorm.Config{TranslateError: true})
 		if err != nil {
 			return true, err
 		}
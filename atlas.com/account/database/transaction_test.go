@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+type testEntity struct {
+	Id   uint32 `gorm:"primaryKey;autoIncrement"`
+	Name string
+}
+
+func setupTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	// Each connection to an in-memory database sees its own database.
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("Failed to retrieve connection pool: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	err = db.AutoMigrate(testEntity{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	return db
+}
+
+func TestIsTransaction(t *testing.T) {
+	db := setupTestDatabase(t)
+	if isTransaction(db) {
+		t.Fatalf("Connection should not be reported as a transaction.")
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if !isTransaction(tx) {
+			t.Fatalf("Transaction should be reported as one.")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unable to run transaction: %v", err)
+	}
+}
+
+func TestExecuteTransactionRollback(t *testing.T) {
+	db := setupTestDatabase(t)
+	failure := errors.New("failure")
+
+	err := ExecuteTransaction(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&testEntity{Name: "first"}).Error; err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected failure, got %v", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := ExecuteTransaction(tx, func(tx *gorm.DB) error {
+			return tx.Create(&testEntity{Name: "second"}).Error
+		})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected failure, got %v", err)
+	}
+
+	var count int64
+	if err = db.Model(&testEntity{}).Count(&count).Error; err != nil {
+		t.Fatalf("Unable to count entities: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("Work of failed transactions should be rolled back. Got %d entities", count)
+	}
+}
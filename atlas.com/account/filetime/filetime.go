@@ -0,0 +1,22 @@
+package filetime
+
+import "time"
+
+// epochDifference is the number of 100-nanosecond intervals between 1601-01-01 and 1970-01-01.
+const epochDifference = 116444736000000000
+
+// FromTime converts t to a Windows FILETIME, the format the client expects for timestamps such as ban expiry.
+func FromTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano()/100) + epochDifference
+}
+
+// ToTime converts a Windows FILETIME to a time.Time.
+func ToTime(ft uint64) time.Time {
+	if ft == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(ft-epochDifference)*100).UTC()
+}
@@ -19,7 +19,9 @@ func InitConsumers(l logrus.FieldLogger) func(func(config consumer.Config, decor
 	return func(rf func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
 		return func(consumerGroupId string) {
 			rf(consumer2.NewConfig(l)("create_account_command")(account2.EnvCommandTopicCreateAccount)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
+			rf(consumer2.NewConfig(l)("account_command")(account2.EnvCommandTopic)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
 			rf(consumer2.NewConfig(l)("account_session_command")(account2.EnvCommandSessionTopic)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
+			rf(consumer2.NewConfig(l)("service_instance_command")(account2.EnvCommandTopicServiceInstance)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
 		}
 	}
 }
@@ -30,18 +32,28 @@ func InitHandlers(l logrus.FieldLogger) func(db *gorm.DB) func(rf func(topic str
 			var t string
 			t, _ = topic.EnvProvider(l)(account2.EnvCommandTopicCreateAccount)()
 			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleCreateAccountCommand(db))))
+			t, _ = topic.EnvProvider(l)(account2.EnvCommandTopic)()
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleChangePasswordCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleResetPasswordCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleAddCharacterSlotsCommand(db))))
 			t, _ = topic.EnvProvider(l)(account2.EnvCommandSessionTopic)()
 			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleCreateAccountSessionCommand(db))))
 			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleProgressStateAccountSessionCommand(db))))
 			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleLogoutAccountSessionCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleVerifyPinAccountSessionCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleVerifyPicAccountSessionCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleHeartbeatAccountSessionCommand(db))))
+			t, _ = topic.EnvProvider(l)(account2.EnvCommandTopicServiceInstance)()
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleServiceInstanceCommand[account2.StartedServiceInstanceCommandBody](db, account2.ServiceInstanceCommandTypeStarted))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleServiceInstanceCommand[account2.StoppedServiceInstanceCommandBody](db, account2.ServiceInstanceCommandTypeStopped))))
 		}
 	}
 }
 
 func handleCreateAccountCommand(db *gorm.DB) message.Handler[account2.CreateCommand] {
 	return func(l logrus.FieldLogger, ctx context.Context, c account2.CreateCommand) {
-		l.Debugf("Received create account command name [%s] password [%s].", c.Name, c.Password)
-		_, err := account.NewProcessor(l, ctx, db).CreateAndEmit(c.Name, c.Password)
+		l.Debugf("Received create account command [%s] name [%s].", c.TransactionId.String(), c.Name)
+		_, err := account.NewProcessor(l, ctx, db).CreateForTransactionAndEmit(c.TransactionId, c.Name, c.Password, c.Gender)
 		if err != nil {
 			l.WithError(err).Errorf("Error processing command to create account [%s].", c.Name)
 			return
@@ -49,6 +61,48 @@ func handleCreateAccountCommand(db *gorm.DB) message.Handler[account2.CreateComm
 	}
 }
 
+func handleChangePasswordCommand(db *gorm.DB) message.Handler[account2.Command[account2.ChangePasswordCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.Command[account2.ChangePasswordCommandBody]) {
+		if c.Type != account2.CommandTypeChangePassword {
+			return
+		}
+
+		l.Debugf("Received change password command account [%d].", c.AccountId)
+		err := account.NewProcessor(l, ctx, db).ChangePasswordAndEmit(c.AccountId, c.Body.OldPassword, c.Body.NewPassword)
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to change password of account [%d].", c.AccountId)
+		}
+	}
+}
+
+func handleResetPasswordCommand(db *gorm.DB) message.Handler[account2.Command[account2.ResetPasswordCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.Command[account2.ResetPasswordCommandBody]) {
+		if c.Type != account2.CommandTypeResetPassword {
+			return
+		}
+
+		l.Debugf("Received reset password command account [%d] from [%s].", c.AccountId, c.Body.Issuer)
+		err := account.NewProcessor(l, ctx, db).ResetPasswordAndEmit(c.AccountId, c.Body.Password, c.Body.Issuer)
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to reset password of account [%d].", c.AccountId)
+		}
+	}
+}
+
+func handleAddCharacterSlotsCommand(db *gorm.DB) message.Handler[account2.Command[account2.AddCharacterSlotsCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.Command[account2.AddCharacterSlotsCommandBody]) {
+		if c.Type != account2.CommandTypeAddCharacterSlots {
+			return
+		}
+
+		l.Debugf("Received add character slots command account [%d] amount [%d] key [%s].", c.AccountId, c.Body.Amount, c.Body.IdempotencyKey)
+		_, err := account.NewProcessor(l, ctx, db).AddCharacterSlotsAndEmit(c.AccountId, c.Body.Amount, c.Body.IdempotencyKey)
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to add character slots to account [%d].", c.AccountId)
+		}
+	}
+}
+
 func handleCreateAccountSessionCommand(db *gorm.DB) func(l logrus.FieldLogger, ctx context.Context, c account2.SessionCommand[account2.CreateSessionCommandBody]) {
 	return func(l logrus.FieldLogger, ctx context.Context, c account2.SessionCommand[account2.CreateSessionCommandBody]) {
 		if c.Type != account2.SessionCommandTypeCreate {
@@ -56,7 +110,7 @@ func handleCreateAccountSessionCommand(db *gorm.DB) func(l logrus.FieldLogger, c
 		}
 
 		l.Debugf("Received create account command account [%d] from [%s].", c.AccountId, c.Issuer)
-		_ = account.NewProcessor(l, ctx, db).AttemptLoginAndEmit(c.SessionId, c.Body.AccountName, c.Body.Password)
+		_ = account.NewProcessor(l, ctx, db).AttemptLoginAndEmit(c.SessionId, strings.ToUpper(c.Issuer), c.InstanceId, c.Body.AccountName, c.Body.Password, c.Body.IPAddress, c.Body.MACAddress, c.Body.HWID)
 	}
 }
 
@@ -65,7 +119,7 @@ func handleProgressStateAccountSessionCommand(db *gorm.DB) message.Handler[accou
 		if c.Type != account2.SessionCommandTypeProgressState {
 			return
 		}
-		_ = account.NewProcessor(l, ctx, db).ProgressStateAndEmit(c.SessionId, c.Issuer, c.AccountId, account.State(c.Body.State), c.Body.Params)
+		_ = account.NewProcessor(l, ctx, db).ProgressStateAndEmit(c.SessionId, strings.ToUpper(c.Issuer), c.InstanceId, c.AccountId, account.State(c.Body.State), c.Body.Params)
 	}
 }
 
@@ -79,3 +133,55 @@ func handleLogoutAccountSessionCommand(db *gorm.DB) func(l logrus.FieldLogger, c
 		_ = account.NewProcessor(l, ctx, db).LogoutAndEmit(c.SessionId, c.AccountId, strings.ToUpper(c.Issuer))
 	}
 }
+
+func handleVerifyPinAccountSessionCommand(db *gorm.DB) message.Handler[account2.SessionCommand[account2.VerifyPinSessionCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.SessionCommand[account2.VerifyPinSessionCommandBody]) {
+		if c.Type != account2.SessionCommandTypeVerifyPin {
+			return
+		}
+
+		l.Debugf("Received verify PIN command account [%d] from [%s].", c.AccountId, c.Issuer)
+		_ = account.NewProcessor(l, ctx, db).AttemptPinAndEmit(c.SessionId, c.AccountId, c.Body.Pin)
+	}
+}
+
+func handleVerifyPicAccountSessionCommand(db *gorm.DB) message.Handler[account2.SessionCommand[account2.VerifyPicSessionCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.SessionCommand[account2.VerifyPicSessionCommandBody]) {
+		if c.Type != account2.SessionCommandTypeVerifyPic {
+			return
+		}
+
+		l.Debugf("Received verify PIC command account [%d] from [%s].", c.AccountId, c.Issuer)
+		_ = account.NewProcessor(l, ctx, db).AttemptPicAndEmit(c.SessionId, c.AccountId, c.Body.Pic)
+	}
+}
+
+func handleHeartbeatAccountSessionCommand(db *gorm.DB) message.Handler[account2.SessionCommand[account2.HeartbeatSessionCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.SessionCommand[account2.HeartbeatSessionCommandBody]) {
+		if c.Type != account2.SessionCommandTypeHeartbeat {
+			return
+		}
+
+		l.Debugf("Received heartbeat command account [%d] session [%s] from [%s].", c.AccountId, c.SessionId.String(), c.Issuer)
+		err := account.NewProcessor(l, ctx, db).Heartbeat(c.SessionId, c.AccountId, strings.ToUpper(c.Issuer))
+		if err != nil {
+			l.WithError(err).Warnf("Unable to refresh session [%s] of account [%d].", c.SessionId.String(), c.AccountId)
+		}
+	}
+}
+
+// handleServiceInstanceCommand terminates the sessions held by an instance of a service which has started or stopped,
+// as announced by commands of the given type.
+func handleServiceInstanceCommand[E any](db *gorm.DB, commandType string) message.Handler[account2.ServiceInstanceCommand[E]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c account2.ServiceInstanceCommand[E]) {
+		if c.Type != commandType {
+			return
+		}
+
+		l.Debugf("Received [%s] command for [%s] instance [%s].", c.Type, c.Service, c.InstanceId.String())
+		err := account.NewProcessor(l, ctx, db).TerminateInstanceAndEmit(c.InstanceId)
+		if err != nil {
+			l.WithError(err).Errorf("Error terminating sessions of [%s] instance [%s].", c.Service, c.InstanceId.String())
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package ban
+
+import (
+	"atlas-account/ban"
+	consumer2 "atlas-account/kafka/consumer"
+	ban2 "atlas-account/kafka/message/ban"
+	"context"
+	"github.com/Chronicle20/atlas-kafka/consumer"
+	"github.com/Chronicle20/atlas-kafka/handler"
+	"github.com/Chronicle20/atlas-kafka/message"
+	"github.com/Chronicle20/atlas-kafka/topic"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func InitConsumers(l logrus.FieldLogger) func(func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+	return func(rf func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+		return func(consumerGroupId string) {
+			rf(consumer2.NewConfig(l)("account_ban_command")(ban2.EnvCommandTopic)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
+		}
+	}
+}
+
+func InitHandlers(l logrus.FieldLogger) func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+	return func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+		return func(rf func(topic string, handler handler.Handler) (string, error)) {
+			var t string
+			t, _ = topic.EnvProvider(l)(ban2.EnvCommandTopic)()
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleCreateBanCommand(db))))
+		}
+	}
+}
+
+func handleCreateBanCommand(db *gorm.DB) message.Handler[ban2.Command[ban2.CreateCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c ban2.Command[ban2.CreateCommandBody]) {
+		if c.Type != ban2.CommandTypeCreate {
+			return
+		}
+
+		var err error
+		if banType := ban.TypeOf(c.Body.BanType); banType == ban.TypeAccount {
+			l.Debugf("Received command to ban account [%d] for reason [%d] from [%s].", c.AccountId, c.Body.Reason, c.Body.Issuer)
+			_, err = ban.NewProcessor(l, ctx, db).CreateAndEmit(c.AccountId, c.Body.Reason, c.Body.Issuer, c.Body.Note, c.Body.ExpiresAt)
+		} else {
+			l.Debugf("Received command to ban [%s] [%s] for reason [%d] from [%s].", c.Body.BanType, c.Body.Value, c.Body.Reason, c.Body.Issuer)
+			_, err = ban.NewProcessor(l, ctx, db).CreateClientAndEmit(banType, c.Body.Value, c.Body.Reason, c.Body.Issuer, c.Body.Note, c.Body.ExpiresAt)
+		}
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to ban [%s] [%d] [%s].", c.Body.BanType, c.AccountId, c.Body.Value)
+			return
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package wallet
+
+import (
+	consumer2 "atlas-account/kafka/consumer"
+	wallet2 "atlas-account/kafka/message/wallet"
+	"atlas-account/wallet"
+	"context"
+	"github.com/Chronicle20/atlas-kafka/consumer"
+	"github.com/Chronicle20/atlas-kafka/handler"
+	"github.com/Chronicle20/atlas-kafka/message"
+	"github.com/Chronicle20/atlas-kafka/topic"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+func InitConsumers(l logrus.FieldLogger) func(func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+	return func(rf func(config consumer.Config, decorators ...model.Decorator[consumer.Config])) func(consumerGroupId string) {
+		return func(consumerGroupId string) {
+			rf(consumer2.NewConfig(l)("account_wallet_command")(wallet2.EnvCommandTopic)(consumerGroupId), consumer.SetHeaderParsers(consumer.SpanHeaderParser, consumer.TenantHeaderParser))
+		}
+	}
+}
+
+func InitHandlers(l logrus.FieldLogger) func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+	return func(db *gorm.DB) func(rf func(topic string, handler handler.Handler) (string, error)) {
+		return func(rf func(topic string, handler handler.Handler) (string, error)) {
+			var t string
+			t, _ = topic.EnvProvider(l)(wallet2.EnvCommandTopic)()
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleCreditCommand(db))))
+			_, _ = rf(t, message.AdaptHandler(message.PersistentConfig(handleDebitCommand(db))))
+		}
+	}
+}
+
+func handleCreditCommand(db *gorm.DB) message.Handler[wallet2.Command[wallet2.AdjustCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c wallet2.Command[wallet2.AdjustCommandBody]) {
+		if c.Type != wallet2.CommandTypeCredit {
+			return
+		}
+
+		l.Debugf("Received command to credit [%d] [%s] to account [%d] for transaction [%s].", c.Body.Amount, c.Body.Currency, c.AccountId, c.Body.TransactionId)
+		_, err := wallet.NewProcessor(l, ctx, db).CreditAndEmit(c.AccountId, c.Body.Currency, c.Body.Amount, c.Body.Reason, c.Body.TransactionId)
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to credit account [%d].", c.AccountId)
+			return
+		}
+	}
+}
+
+func handleDebitCommand(db *gorm.DB) message.Handler[wallet2.Command[wallet2.AdjustCommandBody]] {
+	return func(l logrus.FieldLogger, ctx context.Context, c wallet2.Command[wallet2.AdjustCommandBody]) {
+		if c.Type != wallet2.CommandTypeDebit {
+			return
+		}
+
+		l.Debugf("Received command to debit [%d] [%s] from account [%d] for transaction [%s].", c.Body.Amount, c.Body.Currency, c.AccountId, c.Body.TransactionId)
+		_, err := wallet.NewProcessor(l, ctx, db).DebitAndEmit(c.AccountId, c.Body.Currency, c.Body.Amount, c.Body.Reason, c.Body.TransactionId)
+		if err != nil {
+			l.WithError(err).Errorf("Error processing command to debit account [%d].", c.AccountId)
+			return
+		}
+	}
+}
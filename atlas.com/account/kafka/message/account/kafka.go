@@ -1,38 +1,107 @@
 package account
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
 const (
 	EnvCommandTopicCreateAccount = "COMMAND_TOPIC_CREATE_ACCOUNT"
 
 	EnvCommandSessionTopic = "COMMAND_TOPIC_ACCOUNT_SESSION"
 
-	SessionCommandIssuerInternal = "INTERNAL"
-	SessionCommandIssuerLogin    = "LOGIN"
-	SessionCommandIssuerChannel  = "CHANNEL"
+	SessionCommandIssuerInternal  = "INTERNAL"
+	SessionCommandIssuerLogin     = "LOGIN"
+	SessionCommandIssuerChannel   = "CHANNEL"
+	SessionCommandIssuerCashShop  = "CASH_SHOP"
+	SessionCommandIssuerMTS       = "MTS"
+	SessionCommandIssuerWebPortal = "WEB_PORTAL"
 
 	SessionCommandTypeCreate        = "CREATE"
 	SessionCommandTypeProgressState = "PROGRESS_STATE"
 	SessionCommandTypeLogout        = "LOGOUT"
+	SessionCommandTypeVerifyPin     = "VERIFY_PIN"
+	SessionCommandTypeVerifyPic     = "VERIFY_PIC"
+	SessionCommandTypeHeartbeat     = "HEARTBEAT"
 )
 
-type CreateCommand struct {
-	Name     string `json:"name"`
+const (
+	EnvCommandTopicServiceInstance = "COMMAND_TOPIC_SERVICE_INSTANCE"
+
+	ServiceInstanceCommandTypeStarted = "STARTED"
+	ServiceInstanceCommandTypeStopped = "STOPPED"
+)
+
+// ServiceInstanceCommand announces that an instance of a service has started or is stopping. Either way, the instance no
+// longer holds the sessions it held before.
+type ServiceInstanceCommand[E any] struct {
+	InstanceId uuid.UUID `json:"instanceId"`
+	Service    string    `json:"service"`
+	Type       string    `json:"type"`
+	Body       E         `json:"body"`
+}
+
+type StartedServiceInstanceCommandBody struct {
+}
+
+type StoppedServiceInstanceCommandBody struct {
+}
+
+const (
+	EnvCommandTopic = "COMMAND_TOPIC_ACCOUNT"
+
+	CommandTypeChangePassword    = "CHANGE_PASSWORD"
+	CommandTypeResetPassword     = "RESET_PASSWORD"
+	CommandTypeAddCharacterSlots = "ADD_CHARACTER_SLOTS"
+)
+
+type Command[E any] struct {
+	AccountId uint32 `json:"accountId"`
+	Type      string `json:"type"`
+	Body      E      `json:"body"`
+}
+
+type ChangePasswordCommandBody struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type ResetPasswordCommandBody struct {
 	Password string `json:"password"`
+	Issuer   string `json:"issuer"`
+}
+
+type AddCharacterSlotsCommandBody struct {
+	Amount         int16  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey"`
 }
 
+// CreateCommand requests an account. The outcome is reported by a CREATED or CREATE_FAILED status event carrying the
+// transaction id of the command.
+type CreateCommand struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	Name          string    `json:"name"`
+	Password      string    `json:"password"`
+	Gender        *byte     `json:"gender,omitempty"`
+}
+
+// SessionCommand acts on a session of an account. Services which identify their instances give the instance issuing the
+// command, so the sessions it holds may be terminated together once it restarts or stops.
 type SessionCommand[E any] struct {
-	SessionId uuid.UUID `json:"sessionId"`
-	AccountId uint32    `json:"accountId"`
-	Issuer    string    `json:"author"`
-	Type      string    `json:"type"`
-	Body      E         `json:"body"`
+	SessionId  uuid.UUID `json:"sessionId"`
+	AccountId  uint32    `json:"accountId"`
+	Issuer     string    `json:"author"`
+	InstanceId uuid.UUID `json:"instanceId"`
+	Type       string    `json:"type"`
+	Body       E         `json:"body"`
 }
 
 type CreateSessionCommandBody struct {
 	AccountName string `json:"accountName"`
 	Password    string `json:"password"`
 	IPAddress   string `json:"ipAddress"`
+	MACAddress  string `json:"macAddress"`
+	HWID        string `json:"hwid"`
 }
 
 type ProgressStateSessionCommandBody struct {
@@ -43,23 +112,119 @@ type ProgressStateSessionCommandBody struct {
 type LogoutSessionCommandBody struct {
 }
 
+// HeartbeatSessionCommandBody marks the session as still in use, keeping it from being logged out as idle.
+type HeartbeatSessionCommandBody struct {
+}
+
+type VerifyPinSessionCommandBody struct {
+	Pin string `json:"pin"`
+}
+
+type VerifyPicSessionCommandBody struct {
+	Pic string `json:"pic"`
+}
+
 const (
-	EnvEventTopicStatus  = "EVENT_TOPIC_ACCOUNT_STATUS"
-	EventStatusCreated   = "CREATED"
-	EventStatusLoggedIn  = "LOGGED_IN"
-	EventStatusLoggedOut = "LOGGED_OUT"
+	EnvEventTopicStatus     = "EVENT_TOPIC_ACCOUNT_STATUS"
+	EventStatusCreated      = "CREATED"
+	EventStatusCreateFailed = "CREATE_FAILED"
+	EventStatusLoggedIn     = "LOGGED_IN"
+	EventStatusLoggedOut    = "LOGGED_OUT"
+
+	EventStatusPasswordChanged       = "PASSWORD_CHANGED"
+	EventStatusCharacterSlotsChanged = "CHARACTER_SLOTS_CHANGED"
+	EventStatusCharacterSlotsFailed  = "CHARACTER_SLOTS_FAILED"
+	EventStatusPrivilegesChanged     = "PRIVILEGES_CHANGED"
+	EventStatusDeleted               = "DELETED"
+	EventStatusRestored              = "RESTORED"
 
 	EnvEventSessionStatusTopic                    = "EVENT_TOPIC_ACCOUNT_SESSION_STATUS"
 	SessionEventStatusTypeCreated                 = "CREATED"
 	SessionEventStatusTypeStateChanged            = "STATE_CHANGED"
 	SessionEventStatusTypeRequestLicenseAgreement = "REQUEST_LICENSE_AGREEMENT"
+	SessionEventStatusTypePinVerified             = "PIN_VERIFIED"
+	SessionEventStatusTypePicVerified             = "PIC_VERIFIED"
 	SessionEventStatusTypeError                   = "ERROR"
 )
 
-type StatusEvent struct {
+const (
+	CreateErrorInvalidName     = "INVALID_NAME"
+	CreateErrorInvalidPassword = "INVALID_PASSWORD"
+	CreateErrorInvalidGender   = "INVALID_GENDER"
+	CreateErrorAlreadyExists   = "ALREADY_EXISTS"
+	CreateErrorNameReserved    = "NAME_RESERVED"
+	CreateErrorSystemError     = "SYSTEM_ERROR"
+)
+
+const (
+	CharacterSlotsErrorInvalidAmount          = "INVALID_AMOUNT"
+	CharacterSlotsErrorLimitReached           = "LIMIT_REACHED"
+	CharacterSlotsErrorIdempotencyConflict    = "IDEMPOTENCY_CONFLICT"
+	CharacterSlotsErrorConcurrentModification = "CONCURRENT_MODIFICATION"
+	CharacterSlotsErrorNotFound               = "NOT_FOUND"
+	CharacterSlotsErrorSystemError            = "SYSTEM_ERROR"
+)
+
+type StatusEvent[E any] struct {
 	AccountId uint32 `json:"account_id"`
 	Name      string `json:"name"`
 	Status    string `json:"status"`
+	Body      E      `json:"body,omitempty"`
+}
+
+// CreatedStatusEventBody correlates a created account with the create command requesting it. Accounts created otherwise
+// carry the nil transaction id.
+type CreatedStatusEventBody struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+}
+
+// CreateFailedStatusEventBody reports why the create command with the transaction id was refused. Violations lists the
+// name and password requirements not met, if any.
+type CreateFailedStatusEventBody struct {
+	TransactionId uuid.UUID `json:"transactionId"`
+	Code          string    `json:"code"`
+	Violations    []string  `json:"violations,omitempty"`
+}
+
+// DeletedStatusEventBody reports when a deleted account will be purged, unless restored first.
+type DeletedStatusEventBody struct {
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+// LoggedInStatusEventBody carries the privileges of the account, so services may authorize staff commands.
+type LoggedInStatusEventBody struct {
+	GMLevel byte     `json:"gmLevel"`
+	Roles   []string `json:"roles"`
+}
+
+// PasswordChangedStatusEventBody names the issuer of an administrative reset. It is left empty when the player changed
+// their own password.
+type PasswordChangedStatusEventBody struct {
+	Issuer string `json:"issuer,omitempty"`
+}
+
+type PrivilegesChangedStatusEventBody struct {
+	PreviousGMLevel byte     `json:"previousGmLevel"`
+	GMLevel         byte     `json:"gmLevel"`
+	PreviousRoles   []string `json:"previousRoles"`
+	Roles           []string `json:"roles"`
+	Issuer          string   `json:"issuer"`
+	Reason          string   `json:"reason"`
+}
+
+type CharacterSlotsChangedStatusEventBody struct {
+	Previous       int16  `json:"previous"`
+	CharacterSlots int16  `json:"characterSlots"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+}
+
+// CharacterSlotsFailedStatusEventBody reports why a grant of character slots was refused, so that whoever requested it,
+// such as the cash shop, may refund the purchase.
+type CharacterSlotsFailedStatusEventBody struct {
+	Amount         int16  `json:"amount"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	Code           string `json:"code"`
 }
 
 type SessionStatusEvent[E any] struct {
@@ -70,6 +235,8 @@ type SessionStatusEvent[E any] struct {
 }
 
 type CreatedSessionStatusEventBody struct {
+	GMLevel byte     `json:"gmLevel"`
+	Roles   []string `json:"roles"`
 }
 
 type StateChangedSessionStatusEventBody struct {
@@ -0,0 +1,68 @@
+package ban
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	EnvCommandTopic   = "COMMAND_TOPIC_ACCOUNT_BAN"
+	CommandTypeCreate = "CREATE"
+)
+
+type Command[E any] struct {
+	AccountId uint32 `json:"accountId"`
+	Type      string `json:"type"`
+	Body      E      `json:"body"`
+}
+
+// CreateCommandBody requests a ban. An ACCOUNT ban (the default) applies to the command account, while IP, MAC and HWID
+// bans block any client reporting Value. A zero ExpiresAt requests a permanent ban.
+type CreateCommandBody struct {
+	BanType   string    `json:"banType"`
+	Value     string    `json:"value"`
+	Reason    byte      `json:"reason"`
+	Issuer    string    `json:"issuer"`
+	Note      string    `json:"note"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+const (
+	EnvEventTopicStatus = "EVENT_TOPIC_ACCOUNT_BAN_STATUS"
+	EventStatusCreated  = "CREATED"
+	EventStatusBlocked  = "BLOCKED"
+	EventStatusRevoked  = "REVOKED"
+)
+
+type StatusEvent[E any] struct {
+	BanId     uint32 `json:"banId"`
+	AccountId uint32 `json:"accountId"`
+	Type      string `json:"type"`
+	Body      E      `json:"body"`
+}
+
+type CreatedStatusEventBody struct {
+	BanType   string    `json:"banType"`
+	Value     string    `json:"value"`
+	Reason    byte      `json:"reason"`
+	Issuer    string    `json:"issuer"`
+	StartsAt  time.Time `json:"startsAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type BlockedStatusEventBody struct {
+	BanType     string    `json:"banType"`
+	Value       string    `json:"value"`
+	Reason      byte      `json:"reason"`
+	SessionId   uuid.UUID `json:"sessionId"`
+	AccountName string    `json:"accountName"`
+	IPAddress   string    `json:"ipAddress"`
+	MACAddress  string    `json:"macAddress"`
+	HWID        string    `json:"hwid"`
+}
+
+type RevokedStatusEventBody struct {
+	BanType   string    `json:"banType"`
+	Value     string    `json:"value"`
+	RevokedAt time.Time `json:"revokedAt"`
+}
@@ -0,0 +1,60 @@
+package wallet
+
+const (
+	EnvCommandTopic   = "COMMAND_TOPIC_ACCOUNT_WALLET"
+	CommandTypeCredit = "CREDIT"
+	CommandTypeDebit  = "DEBIT"
+)
+
+type Command[E any] struct {
+	AccountId uint32 `json:"accountId"`
+	Type      string `json:"type"`
+	Body      E      `json:"body"`
+}
+
+// AdjustCommandBody requests a CREDIT or DEBIT of a positive Amount of one currency (CREDIT, PREPAID or POINTS). A
+// TransactionId, such as the id of a cash shop purchase, makes a repeated command apply only once and is echoed in the
+// resulting status event.
+type AdjustCommandBody struct {
+	Currency      string `json:"currency"`
+	Amount        uint32 `json:"amount"`
+	Reason        string `json:"reason"`
+	TransactionId string `json:"transactionId"`
+}
+
+const (
+	EnvEventTopicStatus     = "EVENT_TOPIC_ACCOUNT_WALLET_STATUS"
+	EventStatusCredited     = "CREDITED"
+	EventStatusDebited      = "DEBITED"
+	EventStatusCreditFailed = "CREDIT_FAILED"
+	EventStatusDebitFailed  = "DEBIT_FAILED"
+
+	ErrorInvalidAmount       = "INVALID_AMOUNT"
+	ErrorInvalidCurrency     = "INVALID_CURRENCY"
+	ErrorInsufficientBalance = "INSUFFICIENT_BALANCE"
+	ErrorBalanceLimit        = "BALANCE_LIMIT"
+	ErrorTransactionConflict = "TRANSACTION_CONFLICT"
+	ErrorAccountNotFound     = "ACCOUNT_NOT_FOUND"
+	ErrorSystem              = "SYSTEM_ERROR"
+)
+
+type StatusEvent[E any] struct {
+	AccountId uint32 `json:"accountId"`
+	Type      string `json:"type"`
+	Body      E      `json:"body"`
+}
+
+type AdjustedStatusEventBody struct {
+	Currency      string `json:"currency"`
+	Amount        uint32 `json:"amount"`
+	Balance       uint32 `json:"balance"`
+	Reason        string `json:"reason"`
+	TransactionId string `json:"transactionId,omitempty"`
+}
+
+type AdjustFailedStatusEventBody struct {
+	Currency      string `json:"currency"`
+	Amount        uint32 `json:"amount"`
+	Error         string `json:"error"`
+	TransactionId string `json:"transactionId,omitempty"`
+}
@@ -0,0 +1,50 @@
+package login
+
+import (
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func create(db *gorm.DB) func(tenant tenant.Model, accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string, outcome string, errorCode string) (Model, error) {
+	return func(tenant tenant.Model, accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string, outcome string, errorCode string) (Model, error) {
+		e := &Entity{
+			TenantId:  tenant.Id(),
+			AccountId: accountId,
+			Name:      name,
+			SessionId: sessionId,
+			Service:   service,
+			IPAddress: ipAddress,
+			Outcome:   outcome,
+			ErrorCode: errorCode,
+		}
+
+		err := db.Create(e).Error
+		if err != nil {
+			return Model{}, err
+		}
+		return Make(*e)
+	}
+}
+
+// PurgeByAccountId removes the login history of an account being purged.
+func PurgeByAccountId(db *gorm.DB) func(tenantId uuid.UUID, accountId uint32) error {
+	return func(tenantId uuid.UUID, accountId uint32) error {
+		return db.Where("tenant_id = ? AND account_id = ?", tenantId, accountId).Delete(&Entity{}).Error
+	}
+}
+
+func Make(e Entity) (Model, error) {
+	return Model{
+		tenantId:  e.TenantId,
+		id:        e.ID,
+		accountId: e.AccountId,
+		name:      e.Name,
+		sessionId: e.SessionId,
+		service:   e.Service,
+		ipAddress: e.IPAddress,
+		outcome:   e.Outcome,
+		errorCode: e.ErrorCode,
+		createdAt: e.CreatedAt,
+	}, nil
+}
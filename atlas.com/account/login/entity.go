@@ -0,0 +1,29 @@
+package login
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+func Migration(db *gorm.DB) error {
+	return db.AutoMigrate(&Entity{})
+}
+
+// Entity is an append-only record of an attempt to log in.
+type Entity struct {
+	TenantId  uuid.UUID `gorm:"not null;index:idx_logins_tenant_account"`
+	ID        uint64    `gorm:"primaryKey;autoIncrement;not null"`
+	AccountId uint32    `gorm:"not null;index:idx_logins_tenant_account"` // Zero when the name did not resolve to an account.
+	Name      string    `gorm:"not null"`
+	SessionId uuid.UUID `gorm:"not null"`
+	Service   string    `gorm:"not null"`
+	IPAddress string    `gorm:"not null;default:''"`
+	Outcome   string    `gorm:"not null"`
+	ErrorCode string    `gorm:"not null;default:''"`
+	CreatedAt time.Time // Automatically managed by GORM for creation time
+}
+
+func (e Entity) TableName() string {
+	return "logins"
+}
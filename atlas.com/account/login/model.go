@@ -0,0 +1,68 @@
+package login
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "SUCCESS"
+	OutcomeFailure = "FAILURE"
+)
+
+type Model struct {
+	tenantId  uuid.UUID
+	id        uint64
+	accountId uint32
+	name      string
+	sessionId uuid.UUID
+	service   string
+	ipAddress string
+	outcome   string
+	errorCode string
+	createdAt time.Time
+}
+
+func (m Model) Id() uint64 {
+	return m.id
+}
+
+func (m Model) TenantId() uuid.UUID {
+	return m.tenantId
+}
+
+func (m Model) AccountId() uint32 {
+	return m.accountId
+}
+
+func (m Model) Name() string {
+	return m.name
+}
+
+func (m Model) SessionId() uuid.UUID {
+	return m.sessionId
+}
+
+func (m Model) Service() string {
+	return m.service
+}
+
+func (m Model) IPAddress() string {
+	return m.ipAddress
+}
+
+func (m Model) Outcome() string {
+	return m.outcome
+}
+
+func (m Model) ErrorCode() string {
+	return m.errorCode
+}
+
+func (m Model) CreatedAt() time.Time {
+	return m.createdAt
+}
+
+func Succeeded(m Model) bool {
+	return m.outcome == OutcomeSuccess
+}
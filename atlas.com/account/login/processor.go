@@ -0,0 +1,55 @@
+package login
+
+import (
+	"context"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type Processor interface {
+	GetByAccountId(accountId uint32, offset int, limit int) ([]Model, error)
+	ByAccountIdProvider(accountId uint32, offset int, limit int) model.Provider[[]Model]
+	CountByAccountId(accountId uint32) (int64, error)
+	RecordSuccess(accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string) (Model, error)
+	RecordFailure(accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string, errorCode string) (Model, error)
+}
+
+type ProcessorImpl struct {
+	l   logrus.FieldLogger
+	ctx context.Context
+	db  *gorm.DB
+	t   tenant.Model
+}
+
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return &ProcessorImpl{
+		l:   l,
+		ctx: ctx,
+		db:  db,
+		t:   tenant.MustFromContext(ctx),
+	}
+}
+
+func (p *ProcessorImpl) GetByAccountId(accountId uint32, offset int, limit int) ([]Model, error) {
+	return p.ByAccountIdProvider(accountId, offset, limit)()
+}
+
+// ByAccountIdProvider provides a window of the login history of an account, most recent first.
+func (p *ProcessorImpl) ByAccountIdProvider(accountId uint32, offset int, limit int) model.Provider[[]Model] {
+	return model.SliceMap(Make)(entitiesByAccountId(p.t, accountId, offset, limit)(p.db))(model.ParallelMap())
+}
+
+func (p *ProcessorImpl) CountByAccountId(accountId uint32) (int64, error) {
+	return countByAccountId(p.t, accountId)(p.db)()
+}
+
+func (p *ProcessorImpl) RecordSuccess(accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string) (Model, error) {
+	return create(p.db)(p.t, accountId, name, sessionId, service, ipAddress, OutcomeSuccess, "")
+}
+
+func (p *ProcessorImpl) RecordFailure(accountId uint32, name string, sessionId uuid.UUID, service string, ipAddress string, errorCode string) (Model, error) {
+	return create(p.db)(p.t, accountId, name, sessionId, service, ipAddress, OutcomeFailure, errorCode)
+}
@@ -0,0 +1,89 @@
+package login
+
+import (
+	"context"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func setupTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	err = db.AutoMigrate(Entity{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	return db
+}
+
+func testProcessor(t *testing.T) Processor {
+	l, _ := test.NewNullLogger()
+	st, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	return NewProcessor(l, tenant.WithContext(context.Background(), st), setupTestDatabase(t))
+}
+
+func TestRecord(t *testing.T) {
+	p := testProcessor(t)
+
+	sessionId := uuid.New()
+	_, err := p.RecordFailure(1, "name", sessionId, "LOGIN", "127.0.0.1", "INCORRECT_PASSWORD")
+	if err != nil {
+		t.Fatalf("Unable to record failure: %v", err)
+	}
+	_, err = p.RecordSuccess(1, "name", sessionId, "LOGIN", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Unable to record success: %v", err)
+	}
+	_, err = p.RecordFailure(2, "other", uuid.New(), "LOGIN", "127.0.0.2", "INCORRECT_PASSWORD")
+	if err != nil {
+		t.Fatalf("Unable to record failure: %v", err)
+	}
+
+	ms, err := p.GetByAccountId(1, 0, 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve history: %v", err)
+	}
+	if len(ms) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(ms))
+	}
+	if !Succeeded(ms[0]) || ms[0].SessionId() != sessionId {
+		t.Fatalf("Most recent entry should be first.")
+	}
+	if Succeeded(ms[1]) || ms[1].ErrorCode() != "INCORRECT_PASSWORD" || ms[1].IPAddress() != "127.0.0.1" {
+		t.Fatalf("Failure not recorded as expected.")
+	}
+}
+
+func TestPaging(t *testing.T) {
+	p := testProcessor(t)
+
+	for i := 0; i < 5; i++ {
+		_, err := p.RecordSuccess(1, "name", uuid.New(), "LOGIN", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("Unable to record success: %v", err)
+		}
+	}
+
+	total, err := p.CountByAccountId(1)
+	if err != nil {
+		t.Fatalf("Unable to count history: %v", err)
+	}
+	if total != 5 {
+		t.Fatalf("Expected total of 5, got %d", total)
+	}
+
+	first, _ := p.GetByAccountId(1, 0, 2)
+	last, _ := p.GetByAccountId(1, 4, 2)
+	if len(first) != 2 || len(last) != 1 {
+		t.Fatalf("Unexpected page sizes [%d] and [%d].", len(first), len(last))
+	}
+	if first[0].Id() <= last[0].Id() {
+		t.Fatalf("Pages should be ordered most recent first.")
+	}
+}
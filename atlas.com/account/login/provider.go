@@ -0,0 +1,30 @@
+package login
+
+import (
+	"atlas-account/database"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"gorm.io/gorm"
+)
+
+func entitiesByAccountId(tenant tenant.Model, accountId uint32, offset int, limit int) database.EntityProvider[[]Entity] {
+	return func(db *gorm.DB) model.Provider[[]Entity] {
+		var results []Entity
+		err := db.Where(&Entity{TenantId: tenant.Id(), AccountId: accountId}).Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]Entity](err)
+		}
+		return model.FixedProvider[[]Entity](results)
+	}
+}
+
+func countByAccountId(tenant tenant.Model, accountId uint32) database.EntityProvider[int64] {
+	return func(db *gorm.DB) model.Provider[int64] {
+		var result int64
+		err := db.Model(&Entity{}).Where(&Entity{TenantId: tenant.Id(), AccountId: accountId}).Count(&result).Error
+		if err != nil {
+			return model.ErrorProvider[int64](err)
+		}
+		return model.FixedProvider[int64](result)
+	}
+}
@@ -0,0 +1,48 @@
+package login
+
+import (
+	"atlas-account/rest"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func InitResource(si jsonapi.ServerInformation) func(db *gorm.DB) server.RouteInitializer {
+	return func(db *gorm.DB) server.RouteInitializer {
+		return func(router *mux.Router, l logrus.FieldLogger) {
+			register := rest.RegisterHandler(l)(db)(si)
+
+			r := router.PathPrefix("/accounts/{accountId}/logins").Subrouter()
+			r.HandleFunc("", register("get_account_logins", handleGetAccountLogins)).Methods(http.MethodGet)
+		}
+	}
+}
+
+func handleGetAccountLogins(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return rest.ParsePage(d.Logger(), func(page rest.Page) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				p := NewProcessor(d.Logger(), d.Context(), d.DB())
+				total, err := p.CountByAccountId(accountId)
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to count logins for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				res, err := model.SliceMap(Transform)(p.ByAccountIdProvider(accountId, page.Offset, page.Limit))(model.ParallelMap())()
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to retrieve logins for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				rest.MarshalPage[[]RestModel](d.Logger())(w, r)(c.ServerInformation())(page, total)(res)
+			}
+		})
+	})
+}
@@ -0,0 +1,50 @@
+package login
+
+import (
+	"github.com/google/uuid"
+	"strconv"
+	"time"
+)
+
+type RestModel struct {
+	Id        uint64    `json:"-"`
+	AccountId uint32    `json:"accountId"`
+	Name      string    `json:"name"`
+	SessionId uuid.UUID `json:"sessionId"`
+	Service   string    `json:"service"`
+	IPAddress string    `json:"ipAddress"`
+	Outcome   string    `json:"outcome"`
+	ErrorCode string    `json:"errorCode"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (r RestModel) GetName() string {
+	return "logins"
+}
+
+func (r RestModel) GetID() string {
+	return strconv.FormatUint(r.Id, 10)
+}
+
+func (r *RestModel) SetID(idStr string) error {
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return err
+	}
+	r.Id = id
+	return nil
+}
+
+func Transform(m Model) (RestModel, error) {
+	return RestModel{
+		Id:        m.id,
+		AccountId: m.accountId,
+		Name:      m.name,
+		SessionId: m.sessionId,
+		Service:   m.service,
+		IPAddress: m.ipAddress,
+		Outcome:   m.outcome,
+		ErrorCode: m.errorCode,
+		CreatedAt: m.createdAt,
+	}, nil
+}
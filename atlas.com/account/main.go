@@ -2,12 +2,18 @@ package main
 
 import (
 	"atlas-account/account"
+	"atlas-account/attempt"
+	"atlas-account/ban"
 	"atlas-account/database"
 	account2 "atlas-account/kafka/consumer/account"
+	ban2 "atlas-account/kafka/consumer/ban"
+	wallet2 "atlas-account/kafka/consumer/wallet"
 	"atlas-account/logger"
+	"atlas-account/login"
 	"atlas-account/service"
 	"atlas-account/tasks"
 	"atlas-account/tracing"
+	"atlas-account/wallet"
 	"github.com/Chronicle20/atlas-kafka/consumer"
 	"github.com/Chronicle20/atlas-rest/server"
 	"time"
@@ -47,15 +53,28 @@ func main() {
 		l.WithError(err).Fatal("Unable to initialize tracer.")
 	}
 
-	db := database.Connect(l, database.SetMigrations(account.Migration))
+	if err = account.ValidateConfiguration(l); err != nil {
+		l.WithError(err).Fatal("Invalid configuration.")
+	}
+
+	db := database.Connect(l, database.SetMigrations(account.Migration(l), ban.Migration, login.Migration, wallet.Migration))
+	account.InitRegistry(l, db)
+	account.Restore(l, tdm.Context(), db)
 
 	cmf := consumer.GetManager().AddConsumer(l, tdm.Context(), tdm.WaitGroup())
 	account2.InitConsumers(l)(cmf)(consumerGroupId)
 	account2.InitHandlers(l)(db)(consumer.GetManager().RegisterHandler)
+	ban2.InitConsumers(l)(cmf)(consumerGroupId)
+	ban2.InitHandlers(l)(db)(consumer.GetManager().RegisterHandler)
+	wallet2.InitConsumers(l)(cmf)(consumerGroupId)
+	wallet2.InitHandlers(l)(db)(consumer.GetManager().RegisterHandler)
 
-	server.CreateService(l, tdm.Context(), tdm.WaitGroup(), GetServer().GetPrefix(), account.InitResource(GetServer())(db))
+	server.CreateService(l, tdm.Context(), tdm.WaitGroup(), GetServer().GetPrefix(), account.InitResource(GetServer())(db), ban.InitResource(GetServer())(db), login.InitResource(GetServer())(db), wallet.InitResource(GetServer())(db))
 
 	go tasks.Register(l, tdm.Context())(account.NewTransitionTimeout(l, db, time.Second*time.Duration(5)))
+	go tasks.Register(l, tdm.Context())(account.NewIdleTimeout(l, db, time.Second*time.Duration(30)))
+	go tasks.Register(l, tdm.Context())(attempt.NewPrune(l, time.Minute))
+	go tasks.Register(l, tdm.Context())(account.NewPurge(l, db, time.Hour))
 
 	tdm.TeardownFunc(account.Teardown(l, db))
 	tdm.TeardownFunc(tracing.Teardown(l)(tc))
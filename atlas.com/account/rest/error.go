@@ -0,0 +1,28 @@
+package rest
+
+import (
+	"encoding/json"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// ContentType is the media type of JSON:API documents. Handlers responding with a status other than 200 must set it
+// before writing the status, as headers set afterward are not sent.
+const ContentType = "application/vnd.api+json"
+
+type errorDocument struct {
+	Errors []jsonapi.Error `json:"errors"`
+}
+
+// WriteErrors responds with the given status and a JSON:API document holding the error objects.
+func WriteErrors(l logrus.FieldLogger) func(w http.ResponseWriter, status int, errs []jsonapi.Error) {
+	return func(w http.ResponseWriter, status int, errs []jsonapi.Error) {
+		w.Header().Set("Content-Type", ContentType)
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(errorDocument{Errors: errs})
+		if err != nil {
+			l.WithError(err).Errorf("Writing error response.")
+		}
+	}
+}
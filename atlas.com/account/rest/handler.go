@@ -107,3 +107,18 @@ func ParseAccountId(l logrus.FieldLogger, next AccountIdHandler) http.HandlerFun
 		next(uint32(value))(w, r)
 	}
 }
+
+type BanIdHandler func(id uint32) http.HandlerFunc
+
+func ParseBanId(l logrus.FieldLogger, next BanIdHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		value, err := strconv.Atoi(vars["banId"])
+		if err != nil {
+			l.WithError(err).Errorln("Error parsing id as uint32")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		next(uint32(value))(w, r)
+	}
+}
@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Page is a window of a collection, requested through the page[offset] (starting at 0) and page[limit] query
+// parameters. The limit defaults to DefaultPageSize and is capped at MaxPageSize.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+type PageHandler func(page Page) http.HandlerFunc
+
+func ParsePage(l logrus.FieldLogger, next PageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := parsePage(r)
+		if err != nil {
+			l.WithError(err).Errorln("Error parsing page parameters")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		next(page)(w, r)
+	}
+}
+
+func parsePage(r *http.Request) (Page, error) {
+	page := Page{Offset: 0, Limit: DefaultPageSize}
+	query := r.URL.Query()
+	if val := query.Get("page[offset]"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return Page{}, errors.New("page offset must be a non-negative integer")
+		}
+		page.Offset = n
+	}
+	if val := query.Get("page[limit]"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 {
+			return Page{}, errors.New("page limit must be a positive integer")
+		}
+		page.Limit = min(n, MaxPageSize)
+	}
+	return page, nil
+}
+
+// Links provides links to the first and previous pages, unless the page is the first, and to the next and last pages,
+// unless the collection of the given total size ends within the page.
+func (p Page) Links(r *http.Request, si jsonapi.ServerInformation) func(total int64) jsonapi.Links {
+	return func(total int64) jsonapi.Links {
+		links := make(jsonapi.Links)
+		params := r.URL.Query()
+		base := strings.Trim(si.GetBaseURL(), "/") + r.URL.Path
+		link := func(offset int) jsonapi.Link {
+			params.Set("page[offset]", strconv.Itoa(offset))
+			params.Set("page[limit]", strconv.Itoa(p.Limit))
+			query, _ := url.QueryUnescape(params.Encode())
+			return jsonapi.Link{Href: fmt.Sprintf("%s?%s", base, query)}
+		}
+		if p.Offset > 0 {
+			links["first"] = link(0)
+			links["prev"] = link(max(p.Offset-p.Limit, 0))
+		}
+		if int64(p.Offset+p.Limit) < total {
+			links["next"] = link(p.Offset + p.Limit)
+			links["last"] = link(int(total) - p.Limit)
+		}
+		return links
+	}
+}
+
+// MarshalPage responds with a JSON:API document holding a page of a collection, reporting the size of the whole
+// collection as the total in its meta and linking to the neighbouring pages.
+func MarshalPage[A any](l logrus.FieldLogger) func(w http.ResponseWriter, r *http.Request) func(si jsonapi.ServerInformation) func(page Page, total int64) func(slice A) {
+	return func(w http.ResponseWriter, r *http.Request) func(si jsonapi.ServerInformation) func(page Page, total int64) func(slice A) {
+		return func(si jsonapi.ServerInformation) func(page Page, total int64) func(slice A) {
+			return func(page Page, total int64) func(slice A) {
+				return func(slice A) {
+					doc, err := jsonapi.MarshalToStruct(slice, si)
+					if err != nil {
+						l.WithError(err).Errorf("Unable to marshal page.")
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					doc.Meta = map[string]interface{}{"total": total}
+					doc.Links = page.Links(r, si)(total)
+
+					query := r.URL.Query()
+					filtered, errs := jsonapi.FilterSparseFields(doc, jsonapi.ParseQueryFields(&query))
+					if errs != nil {
+						WriteErrors(l)(w, http.StatusBadRequest, errs)
+						return
+					}
+					res, err := json.Marshal(filtered)
+					if err != nil {
+						l.WithError(err).Errorf("Unable to marshal page.")
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					w.Header().Set("Content-Type", ContentType)
+					w.WriteHeader(http.StatusOK)
+					_, err = w.Write(res)
+					if err != nil {
+						l.WithError(err).Errorf("Writing page response.")
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type testServer struct {
+}
+
+func (s testServer) GetBaseURL() string {
+	return ""
+}
+
+func (s testServer) GetPrefix() string {
+	return "/api/"
+}
+
+func TestParsePage(t *testing.T) {
+	var tests = []struct {
+		query string
+		page  Page
+		valid bool
+	}{
+		{"", Page{Offset: 0, Limit: DefaultPageSize}, true},
+		{"?page[offset]=20&page[limit]=10", Page{Offset: 20, Limit: 10}, true},
+		{"?page[limit]=1000", Page{Offset: 0, Limit: MaxPageSize}, true},
+		{"?page[offset]=-1", Page{}, false},
+		{"?page[limit]=0", Page{}, false},
+		{"?page[offset]=a", Page{}, false},
+	}
+	for _, tt := range tests {
+		page, err := parsePage(httptest.NewRequest("GET", "/api/accounts"+tt.query, nil))
+		if tt.valid && (err != nil || page != tt.page) {
+			t.Errorf("[%s] expected page %+v, got %+v %v", tt.query, tt.page, page, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("[%s] expected invalid page parameters", tt.query)
+		}
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/accounts?filter[name]=a&page[offset]=100&page[limit]=50", nil)
+	links := Page{Offset: 100, Limit: 50}.Links(r, testServer{})(320)
+	expected := map[string]string{
+		"first": "/api/accounts?filter[name]=a&page[limit]=50&page[offset]=0",
+		"prev":  "/api/accounts?filter[name]=a&page[limit]=50&page[offset]=50",
+		"next":  "/api/accounts?filter[name]=a&page[limit]=50&page[offset]=150",
+		"last":  "/api/accounts?filter[name]=a&page[limit]=50&page[offset]=270",
+	}
+	if len(links) != len(expected) {
+		t.Fatalf("Expected [%d] links, got [%d].", len(expected), len(links))
+	}
+	for name, href := range expected {
+		if links[name].Href != href {
+			t.Errorf("Expected [%s] link [%s], got [%s].", name, href, links[name].Href)
+		}
+	}
+
+	links = Page{Offset: 0, Limit: 50}.Links(r, testServer{})(50)
+	if len(links) != 0 {
+		t.Fatalf("A single page should carry no links, got [%d].", len(links))
+	}
+}
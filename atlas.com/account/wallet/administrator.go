@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"atlas-account/database"
+	"errors"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+)
+
+// maximumBalance bounds every currency to what the client can display.
+const maximumBalance = math.MaxInt32
+
+var currencyColumns = map[string]string{
+	CurrencyCredit:  "credit",
+	CurrencyPrepaid: "prepaid",
+	CurrencyPoints:  "points",
+}
+
+// adjust applies a signed amount to one currency of an account wallet and appends it to the ledger. The balance is
+// changed by a single conditional update, so concurrent adjustments can neither drive it below zero nor lose an update.
+// When transactionId was already applied, the original ledger entry is returned and replayed is set, provided it made
+// the same adjustment of the same account. Otherwise, ErrTransactionConflict is returned.
+func adjust(db *gorm.DB) func(tenant tenant.Model, accountId uint32, currency string, amount int64, reason string, transactionId string) (Entity, TransactionEntity, bool, error) {
+	return func(tenant tenant.Model, accountId uint32, currency string, amount int64, reason string, transactionId string) (Entity, TransactionEntity, bool, error) {
+		column, ok := currencyColumns[currency]
+		if !ok {
+			return Entity{}, TransactionEntity{}, false, ErrInvalidCurrency
+		}
+
+		var wallet Entity
+		var result TransactionEntity
+		var replayed bool
+		err := database.ExecuteTransaction(db, func(tx *gorm.DB) error {
+			exists, err := accountExists(tenant, accountId)(tx)()
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrAccountNotFound
+			}
+
+			if transactionId != "" {
+				wallet, result, err = replay(tx)(tenant, accountId, currency, amount, transactionId)
+				if err == nil {
+					replayed = true
+					return nil
+				}
+				if !errors.Is(err, gorm.ErrRecordNotFound) {
+					return err
+				}
+			}
+
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Entity{TenantId: tenant.Id(), AccountId: accountId}).Error
+			if err != nil {
+				return err
+			}
+
+			res := tx.Model(&Entity{}).
+				Where("tenant_id = ? AND account_id = ? AND "+column+" + ? BETWEEN 0 AND ?", tenant.Id(), accountId, amount, maximumBalance).
+				Update(column, gorm.Expr(column+" + ?", amount))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				if amount < 0 {
+					return ErrInsufficientBalance
+				}
+				return ErrBalanceLimit
+			}
+
+			wallet, err = entityByAccountId(tenant, accountId)(tx)()
+			if err != nil {
+				return err
+			}
+			m, err := Make(wallet)
+			if err != nil {
+				return err
+			}
+
+			result = TransactionEntity{
+				TenantId:  tenant.Id(),
+				AccountId: accountId,
+				Currency:  currency,
+				Amount:    amount,
+				Balance:   m.Balance(currency),
+				Reason:    reason,
+			}
+			if transactionId != "" {
+				result.TransactionId = &transactionId
+			}
+			return tx.Create(&result).Error
+		})
+		if errors.Is(err, gorm.ErrDuplicatedKey) && transactionId != "" {
+			// A concurrent adjustment with the same transaction id was recorded first, and is replayed in its place.
+			wallet, result, err = replay(db)(tenant, accountId, currency, amount, transactionId)
+			replayed = err == nil
+		}
+		if err != nil {
+			return Entity{}, TransactionEntity{}, false, err
+		}
+		return wallet, result, replayed, nil
+	}
+}
+
+// replay retrieves the ledger entry already recorded for a transaction id, along with the wallet it adjusted. An entry
+// making a different adjustment, or adjusting another account, is reported as ErrTransactionConflict.
+func replay(db *gorm.DB) func(tenant tenant.Model, accountId uint32, currency string, amount int64, transactionId string) (Entity, TransactionEntity, error) {
+	return func(tenant tenant.Model, accountId uint32, currency string, amount int64, transactionId string) (Entity, TransactionEntity, error) {
+		existing, err := transactionEntityByTransactionId(tenant, transactionId)(db)()
+		if err != nil {
+			return Entity{}, TransactionEntity{}, err
+		}
+		if existing.AccountId != accountId || existing.Currency != currency || existing.Amount != amount {
+			return Entity{}, TransactionEntity{}, ErrTransactionConflict
+		}
+		wallet, err := entityByAccountId(tenant, accountId)(db)()
+		if err != nil {
+			return Entity{}, TransactionEntity{}, err
+		}
+		return wallet, existing, nil
+	}
+}
+
+// PurgeByAccountId removes the wallet of an account being purged. Its ledger is retained as a record of the currency it
+// held.
+func PurgeByAccountId(db *gorm.DB) func(tenantId uuid.UUID, accountId uint32) error {
+	return func(tenantId uuid.UUID, accountId uint32) error {
+		return db.Where("tenant_id = ? AND account_id = ?", tenantId, accountId).Delete(&Entity{}).Error
+	}
+}
+
+func Make(e Entity) (Model, error) {
+	return Model{
+		tenantId:  e.TenantId,
+		accountId: e.AccountId,
+		credit:    e.Credit,
+		prepaid:   e.Prepaid,
+		points:    e.Points,
+		updatedAt: e.UpdatedAt,
+	}, nil
+}
+
+func MakeTransaction(e TransactionEntity) (Transaction, error) {
+	r := Transaction{
+		tenantId:  e.TenantId,
+		id:        e.ID,
+		accountId: e.AccountId,
+		currency:  e.Currency,
+		amount:    e.Amount,
+		balance:   e.Balance,
+		reason:    e.Reason,
+		createdAt: e.CreatedAt,
+	}
+	if e.TransactionId != nil {
+		r.transactionId = *e.TransactionId
+	}
+	return r, nil
+}
@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+func Migration(db *gorm.DB) error {
+	return db.AutoMigrate(&Entity{}, &TransactionEntity{})
+}
+
+// Entity holds the balances of an account. It is created on the first adjustment, so an account without one has
+// nothing in any currency.
+type Entity struct {
+	TenantId  uuid.UUID `gorm:"primaryKey;not null"`
+	AccountId uint32    `gorm:"primaryKey;not null;autoIncrement:false"`
+	Credit    uint32    `gorm:"not null;default:0"`
+	Prepaid   uint32    `gorm:"not null;default:0"`
+	Points    uint32    `gorm:"not null;default:0"`
+	UpdatedAt time.Time // Automatically managed by GORM for update time
+}
+
+func (e Entity) TableName() string {
+	return "wallets"
+}
+
+// TransactionEntity is an append-only ledger entry recording one adjustment of a single currency.
+type TransactionEntity struct {
+	TenantId      uuid.UUID `gorm:"not null;index:idx_wallet_transactions_tenant_account;uniqueIndex:idx_wallet_transactions_transaction"`
+	ID            uint64    `gorm:"primaryKey;autoIncrement;not null"`
+	AccountId     uint32    `gorm:"not null;index:idx_wallet_transactions_tenant_account"`
+	Currency      string    `gorm:"not null"`
+	Amount        int64     `gorm:"not null"` // Positive for a credit, negative for a debit.
+	Balance       uint32    `gorm:"not null"` // Balance of the currency once applied.
+	Reason        string    `gorm:"not null;default:''"`
+	TransactionId *string   `gorm:"uniqueIndex:idx_wallet_transactions_transaction"`
+	CreatedAt     time.Time // Automatically managed by GORM for creation time
+}
+
+func (e TransactionEntity) TableName() string {
+	return "wallet_transactions"
+}
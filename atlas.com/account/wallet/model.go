@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	CurrencyCredit  = "CREDIT"  // NX Credit
+	CurrencyPrepaid = "PREPAID" // NX Prepaid
+	CurrencyPoints  = "POINTS"  // Maple Points
+)
+
+type Model struct {
+	tenantId  uuid.UUID
+	accountId uint32
+	credit    uint32
+	prepaid   uint32
+	points    uint32
+	updatedAt time.Time
+}
+
+func (m Model) TenantId() uuid.UUID {
+	return m.tenantId
+}
+
+func (m Model) AccountId() uint32 {
+	return m.accountId
+}
+
+func (m Model) Credit() uint32 {
+	return m.credit
+}
+
+func (m Model) Prepaid() uint32 {
+	return m.prepaid
+}
+
+func (m Model) Points() uint32 {
+	return m.points
+}
+
+func (m Model) UpdatedAt() time.Time {
+	return m.updatedAt
+}
+
+// Balance provides the balance of the given currency.
+func (m Model) Balance(currency string) uint32 {
+	switch currency {
+	case CurrencyCredit:
+		return m.credit
+	case CurrencyPrepaid:
+		return m.prepaid
+	case CurrencyPoints:
+		return m.points
+	}
+	return 0
+}
+
+type Transaction struct {
+	tenantId      uuid.UUID
+	id            uint64
+	accountId     uint32
+	currency      string
+	amount        int64
+	balance       uint32
+	reason        string
+	transactionId string
+	createdAt     time.Time
+}
+
+func (t Transaction) Id() uint64 {
+	return t.id
+}
+
+func (t Transaction) TenantId() uuid.UUID {
+	return t.tenantId
+}
+
+func (t Transaction) AccountId() uint32 {
+	return t.accountId
+}
+
+func (t Transaction) Currency() string {
+	return t.currency
+}
+
+func (t Transaction) Amount() int64 {
+	return t.amount
+}
+
+func (t Transaction) Balance() uint32 {
+	return t.balance
+}
+
+func (t Transaction) Reason() string {
+	return t.reason
+}
+
+func (t Transaction) TransactionId() string {
+	return t.transactionId
+}
+
+func (t Transaction) CreatedAt() time.Time {
+	return t.createdAt
+}
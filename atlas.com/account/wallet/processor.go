@@ -0,0 +1,168 @@
+package wallet
+
+import (
+	"atlas-account/kafka/message"
+	wallet2 "atlas-account/kafka/message/wallet"
+	"atlas-account/kafka/producer"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidAmount = errors.New("amount must be positive")
+var ErrInvalidCurrency = errors.New("unknown currency")
+var ErrInsufficientBalance = errors.New("insufficient balance")
+var ErrBalanceLimit = errors.New("balance limit exceeded")
+var ErrTransactionConflict = errors.New("transaction id already used for another adjustment")
+var ErrAccountNotFound = errors.New("account not found")
+
+type Processor interface {
+	GetByAccountId(accountId uint32) (Model, error)
+	ByAccountIdProvider(accountId uint32) model.Provider[Model]
+	GetTransactionsByAccountId(accountId uint32, offset int, limit int) ([]Transaction, error)
+	TransactionsByAccountIdProvider(accountId uint32, offset int, limit int) model.Provider[[]Transaction]
+	CountTransactionsByAccountId(accountId uint32) (int64, error)
+	CreditAndEmit(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+	Credit(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+	DebitAndEmit(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+	Debit(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+}
+
+type ProcessorImpl struct {
+	l   logrus.FieldLogger
+	ctx context.Context
+	db  *gorm.DB
+	t   tenant.Model
+	p   producer.Provider
+}
+
+func NewProcessor(l logrus.FieldLogger, ctx context.Context, db *gorm.DB) Processor {
+	return &ProcessorImpl{
+		l:   l,
+		ctx: ctx,
+		db:  db,
+		t:   tenant.MustFromContext(ctx),
+		p:   producer.ProviderImpl(l)(ctx),
+	}
+}
+
+func (p *ProcessorImpl) GetByAccountId(accountId uint32) (Model, error) {
+	return p.ByAccountIdProvider(accountId)()
+}
+
+// ByAccountIdProvider provides the wallet of an account. An account which has never held a currency has an empty one.
+func (p *ProcessorImpl) ByAccountIdProvider(accountId uint32) model.Provider[Model] {
+	return model.Map(Make)(entityByAccountId(p.t, accountId)(p.db))
+}
+
+func (p *ProcessorImpl) GetTransactionsByAccountId(accountId uint32, offset int, limit int) ([]Transaction, error) {
+	return p.TransactionsByAccountIdProvider(accountId, offset, limit)()
+}
+
+// TransactionsByAccountIdProvider provides a window of the wallet ledger of an account, most recent first.
+func (p *ProcessorImpl) TransactionsByAccountIdProvider(accountId uint32, offset int, limit int) model.Provider[[]Transaction] {
+	return model.SliceMap(MakeTransaction)(transactionEntitiesByAccountId(p.t, accountId, offset, limit)(p.db))(model.ParallelMap())
+}
+
+func (p *ProcessorImpl) CountTransactionsByAccountId(accountId uint32) (int64, error) {
+	return countTransactionsByAccountId(p.t, accountId)(p.db)()
+}
+
+func (p *ProcessorImpl) CreditAndEmit(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+	return p.adjustAndEmit(p.Credit)(accountId, currency, amount, reason, transactionId)
+}
+
+// Credit adds a positive amount of a currency to the wallet of an account.
+func (p *ProcessorImpl) Credit(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+	return func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+		return p.adjust(mb)(wallet2.EventStatusCreditFailed)(accountId, currency, amount, int64(amount), reason, transactionId)
+	}
+}
+
+func (p *ProcessorImpl) DebitAndEmit(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+	return p.adjustAndEmit(p.Debit)(accountId, currency, amount, reason, transactionId)
+}
+
+// Debit removes a positive amount of a currency from the wallet of an account, such as to pay for a cash shop purchase.
+// The debit fails with ErrInsufficientBalance rather than leave the balance negative.
+func (p *ProcessorImpl) Debit(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+	return func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+		return p.adjust(mb)(wallet2.EventStatusDebitFailed)(accountId, currency, amount, -int64(amount), reason, transactionId)
+	}
+}
+
+type adjustFunc func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+
+// adjustAndEmit emits the outcome of an adjustment. Unlike most operations, a failed adjustment is still announced, so
+// the requester learns why its transaction was refused.
+func (p *ProcessorImpl) adjustAndEmit(f func(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)) adjustFunc {
+	return func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error) {
+		var result Model
+		var aerr error
+		err := message.Emit(p.p)(func(buf *message.Buffer) error {
+			result, aerr = f(buf)(accountId, currency, amount, reason, transactionId)
+			return nil
+		})
+		if aerr != nil {
+			return Model{}, aerr
+		}
+		return result, err
+	}
+}
+
+func (p *ProcessorImpl) adjust(mb *message.Buffer) func(failedStatus string) func(accountId uint32, currency string, amount uint32, delta int64, reason string, transactionId string) (Model, error) {
+	return func(failedStatus string) func(accountId uint32, currency string, amount uint32, delta int64, reason string, transactionId string) (Model, error) {
+		return func(accountId uint32, currency string, amount uint32, delta int64, reason string, transactionId string) (Model, error) {
+			fail := func(err error) (Model, error) {
+				p.l.WithError(err).Errorf("Unable to adjust [%s] of account [%d] by [%d].", currency, accountId, delta)
+				_ = mb.Put(wallet2.EnvEventTopicStatus, adjustFailedEventProvider(accountId, failedStatus, currency, amount, transactionId, errorCode(err)))
+				return Model{}, err
+			}
+
+			if amount == 0 {
+				return fail(ErrInvalidAmount)
+			}
+			w, te, replayed, err := adjust(p.db)(p.t, accountId, currency, delta, reason, transactionId)
+			if err != nil {
+				return fail(err)
+			}
+			m, err := Make(w)
+			if err != nil {
+				return Model{}, err
+			}
+			if replayed {
+				p.l.Debugf("Wallet transaction [%s] for account [%d] already applied.", transactionId, accountId)
+				return m, nil
+			}
+			t, err := MakeTransaction(te)
+			if err != nil {
+				return Model{}, err
+			}
+
+			p.l.Infof("Adjusted [%s] of account [%d] by [%d], now [%d].", currency, accountId, delta, t.Balance())
+			_ = mb.Put(wallet2.EnvEventTopicStatus, adjustedEventProvider(t))
+			return m, nil
+		}
+	}
+}
+
+func errorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidAmount):
+		return wallet2.ErrorInvalidAmount
+	case errors.Is(err, ErrInvalidCurrency):
+		return wallet2.ErrorInvalidCurrency
+	case errors.Is(err, ErrInsufficientBalance):
+		return wallet2.ErrorInsufficientBalance
+	case errors.Is(err, ErrBalanceLimit):
+		return wallet2.ErrorBalanceLimit
+	case errors.Is(err, ErrTransactionConflict):
+		return wallet2.ErrorTransactionConflict
+	case errors.Is(err, ErrAccountNotFound):
+		return wallet2.ErrorAccountNotFound
+	}
+	return wallet2.ErrorSystem
+}
@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"atlas-account/kafka/message"
+	wallet2 "atlas-account/kafka/message/wallet"
+	"context"
+	"errors"
+	"github.com/Chronicle20/atlas-tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus/hooks/test"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// accountEntity stands in for the accounts table of the account package, which may not be imported here.
+type accountEntity struct {
+	TenantId  uuid.UUID
+	ID        uint32 `gorm:"primaryKey;autoIncrement:false"`
+	DeletedAt gorm.DeletedAt
+}
+
+func (e accountEntity) TableName() string {
+	return "accounts"
+}
+
+func setupTestDatabase(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	err = db.AutoMigrate(Entity{}, TransactionEntity{}, accountEntity{})
+	if err != nil {
+		t.Fatalf("Failed to auto migrate: %v", err)
+	}
+	return db
+}
+
+// testProcessor provides a processor for a tenant holding accounts 1 and 2, along with account 3 which is deleted.
+func testProcessor(t *testing.T) Processor {
+	l, _ := test.NewNullLogger()
+	st, _ := tenant.Create(uuid.New(), "GMS", 83, 1)
+	db := setupTestDatabase(t)
+	deleted := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	err := db.Create(&[]accountEntity{{TenantId: st.Id(), ID: 1}, {TenantId: st.Id(), ID: 2}, {TenantId: st.Id(), ID: 3, DeletedAt: deleted}}).Error
+	if err != nil {
+		t.Fatalf("Unable to create accounts: %v", err)
+	}
+	return NewProcessor(l, tenant.WithContext(context.Background(), st), db)
+}
+
+func TestCreditAndDebit(t *testing.T) {
+	p := testProcessor(t)
+
+	w, err := p.GetByAccountId(1)
+	if err != nil {
+		t.Fatalf("Unable to retrieve empty wallet: %v", err)
+	}
+	if w.Credit() != 0 || w.Prepaid() != 0 || w.Points() != 0 {
+		t.Fatalf("New wallet should be empty.")
+	}
+
+	mb := message.NewBuffer()
+	w, err = p.Credit(mb)(1, CurrencyCredit, 1000, "purchase", "")
+	if err != nil {
+		t.Fatalf("Unable to credit wallet: %v", err)
+	}
+	w, err = p.Debit(mb)(1, CurrencyCredit, 400, "item", "")
+	if err != nil {
+		t.Fatalf("Unable to debit wallet: %v", err)
+	}
+	if w.Credit() != 600 || w.Prepaid() != 0 {
+		t.Fatalf("Expected credit of 600, got [%d].", w.Credit())
+	}
+	if len(mb.GetAll()[wallet2.EnvEventTopicStatus]) != 2 {
+		t.Fatalf("Expected an event for each adjustment.")
+	}
+
+	ts, err := p.GetTransactionsByAccountId(1, 0, 10)
+	if err != nil {
+		t.Fatalf("Unable to retrieve ledger: %v", err)
+	}
+	if len(ts) != 2 || ts[0].Amount() != -400 || ts[0].Balance() != 600 || ts[1].Amount() != 1000 {
+		t.Fatalf("Ledger not recorded as expected.")
+	}
+}
+
+func TestDebitInsufficientBalance(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.Credit(message.NewBuffer())(1, CurrencyPoints, 100, "", "")
+	if err != nil {
+		t.Fatalf("Unable to credit wallet: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	_, err = p.Debit(mb)(1, CurrencyPoints, 101, "", "purchase-1")
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected insufficient balance, got %v", err)
+	}
+	if len(mb.GetAll()[wallet2.EnvEventTopicStatus]) != 1 {
+		t.Fatalf("Expected the failure to be announced.")
+	}
+	_, err = p.Debit(message.NewBuffer())(2, CurrencyPoints, 1, "", "")
+	if !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("Expected insufficient balance for an account without a wallet, got %v", err)
+	}
+
+	w, _ := p.GetByAccountId(1)
+	if w.Points() != 100 {
+		t.Fatalf("Failed debit should leave the balance unchanged, got [%d].", w.Points())
+	}
+	total, _ := p.CountTransactionsByAccountId(1)
+	if total != 1 {
+		t.Fatalf("Failed debit should not be recorded in the ledger.")
+	}
+}
+
+func TestTransactionReplay(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.Credit(message.NewBuffer())(1, CurrencyPrepaid, 500, "", "")
+	if err != nil {
+		t.Fatalf("Unable to credit wallet: %v", err)
+	}
+	_, err = p.Debit(message.NewBuffer())(1, CurrencyPrepaid, 200, "", "purchase-1")
+	if err != nil {
+		t.Fatalf("Unable to debit wallet: %v", err)
+	}
+
+	mb := message.NewBuffer()
+	w, err := p.Debit(mb)(1, CurrencyPrepaid, 200, "", "purchase-1")
+	if err != nil {
+		t.Fatalf("Replayed debit should succeed: %v", err)
+	}
+	if w.Prepaid() != 300 {
+		t.Fatalf("Replayed debit should not apply again, got [%d].", w.Prepaid())
+	}
+	if len(mb.GetAll()) != 0 {
+		t.Fatalf("Replayed debit should not emit.")
+	}
+
+	var tests = []struct {
+		name      string
+		adjust    func(mb *message.Buffer) func(accountId uint32, currency string, amount uint32, reason string, transactionId string) (Model, error)
+		accountId uint32
+		currency  string
+		amount    uint32
+	}{
+		{"account", p.Debit, 2, CurrencyPrepaid, 200},
+		{"currency", p.Debit, 1, CurrencyCredit, 200},
+		{"amount", p.Debit, 1, CurrencyPrepaid, 100},
+		{"sign", p.Credit, 1, CurrencyPrepaid, 200},
+	}
+	for _, tt := range tests {
+		_, err = tt.adjust(message.NewBuffer())(tt.accountId, tt.currency, tt.amount, "", "purchase-1")
+		if !errors.Is(err, ErrTransactionConflict) {
+			t.Errorf("[%s] expected transaction conflict, got %v", tt.name, err)
+		}
+	}
+	w, _ = p.GetByAccountId(1)
+	if w.Prepaid() != 300 || w.Credit() != 0 {
+		t.Fatalf("Conflicting adjustments should not apply, got [%d] [%d].", w.Prepaid(), w.Credit())
+	}
+}
+
+func TestAccountNotFound(t *testing.T) {
+	p := testProcessor(t)
+
+	for _, accountId := range []uint32{3, 4} {
+		mb := message.NewBuffer()
+		_, err := p.Credit(mb)(accountId, CurrencyCredit, 100, "", "")
+		if !errors.Is(err, ErrAccountNotFound) {
+			t.Fatalf("Expected account [%d] not to be found, got %v", accountId, err)
+		}
+		if len(mb.GetAll()[wallet2.EnvEventTopicStatus]) != 1 {
+			t.Fatalf("Expected the failure to be announced.")
+		}
+		total, _ := p.CountTransactionsByAccountId(accountId)
+		if total != 0 {
+			t.Fatalf("Adjustments of account [%d] should not be recorded.", accountId)
+		}
+	}
+}
+
+func TestInvalidAdjustment(t *testing.T) {
+	p := testProcessor(t)
+
+	_, err := p.Credit(message.NewBuffer())(1, "MESOS", 1, "", "")
+	if !errors.Is(err, ErrInvalidCurrency) {
+		t.Fatalf("Expected invalid currency, got %v", err)
+	}
+	_, err = p.Credit(message.NewBuffer())(1, CurrencyCredit, 0, "", "")
+	if !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("Expected invalid amount, got %v", err)
+	}
+	_, err = p.Credit(message.NewBuffer())(1, CurrencyCredit, maximumBalance, "", "")
+	if err != nil {
+		t.Fatalf("Unable to credit wallet: %v", err)
+	}
+	_, err = p.Credit(message.NewBuffer())(1, CurrencyCredit, 1, "", "")
+	if !errors.Is(err, ErrBalanceLimit) {
+		t.Fatalf("Expected balance limit, got %v", err)
+	}
+}
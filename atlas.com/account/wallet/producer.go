@@ -0,0 +1,45 @@
+package wallet
+
+import (
+	wallet2 "atlas-account/kafka/message/wallet"
+	"github.com/Chronicle20/atlas-kafka/producer"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/segmentio/kafka-go"
+)
+
+func adjustedEventProvider(t Transaction) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(t.AccountId()))
+	eventType := wallet2.EventStatusCredited
+	amount := t.Amount()
+	if amount < 0 {
+		eventType = wallet2.EventStatusDebited
+		amount = -amount
+	}
+	value := &wallet2.StatusEvent[wallet2.AdjustedStatusEventBody]{
+		AccountId: t.AccountId(),
+		Type:      eventType,
+		Body: wallet2.AdjustedStatusEventBody{
+			Currency:      t.Currency(),
+			Amount:        uint32(amount),
+			Balance:       t.Balance(),
+			Reason:        t.Reason(),
+			TransactionId: t.TransactionId(),
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
+
+func adjustFailedEventProvider(accountId uint32, eventType string, currency string, amount uint32, transactionId string, errorCode string) model.Provider[[]kafka.Message] {
+	key := producer.CreateKey(int(accountId))
+	value := &wallet2.StatusEvent[wallet2.AdjustFailedStatusEventBody]{
+		AccountId: accountId,
+		Type:      eventType,
+		Body: wallet2.AdjustFailedStatusEventBody{
+			Currency:      currency,
+			Amount:        amount,
+			Error:         errorCode,
+			TransactionId: transactionId,
+		},
+	}
+	return producer.SingleMessageProvider(key, value)
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"atlas-account/database"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-tenant"
+	"gorm.io/gorm"
+)
+
+func entityByAccountId(tenant tenant.Model, accountId uint32) database.EntityProvider[Entity] {
+	return func(db *gorm.DB) model.Provider[Entity] {
+		var result Entity
+		err := db.Where(&Entity{TenantId: tenant.Id(), AccountId: accountId}).First(&result).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.FixedProvider(Entity{TenantId: tenant.Id(), AccountId: accountId})
+		}
+		if err != nil {
+			return model.ErrorProvider[Entity](err)
+		}
+		return model.FixedProvider[Entity](result)
+	}
+}
+
+func transactionEntitiesByAccountId(tenant tenant.Model, accountId uint32, offset int, limit int) database.EntityProvider[[]TransactionEntity] {
+	return func(db *gorm.DB) model.Provider[[]TransactionEntity] {
+		var results []TransactionEntity
+		err := db.Where(&TransactionEntity{TenantId: tenant.Id(), AccountId: accountId}).Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&results).Error
+		if err != nil {
+			return model.ErrorProvider[[]TransactionEntity](err)
+		}
+		return model.FixedProvider[[]TransactionEntity](results)
+	}
+}
+
+func countTransactionsByAccountId(tenant tenant.Model, accountId uint32) database.EntityProvider[int64] {
+	return func(db *gorm.DB) model.Provider[int64] {
+		var result int64
+		err := db.Model(&TransactionEntity{}).Where(&TransactionEntity{TenantId: tenant.Id(), AccountId: accountId}).Count(&result).Error
+		if err != nil {
+			return model.ErrorProvider[int64](err)
+		}
+		return model.FixedProvider[int64](result)
+	}
+}
+
+func transactionEntityByTransactionId(tenant tenant.Model, transactionId string) database.EntityProvider[TransactionEntity] {
+	return func(db *gorm.DB) model.Provider[TransactionEntity] {
+		var result TransactionEntity
+		err := db.Where(&TransactionEntity{TenantId: tenant.Id(), TransactionId: &transactionId}).First(&result).Error
+		if err != nil {
+			return model.ErrorProvider[TransactionEntity](err)
+		}
+		return model.FixedProvider(result)
+	}
+}
+
+// accountExists reports whether the account is present and not deleted. The accounts table is queried directly, as the
+// account package depends on this one.
+func accountExists(tenant tenant.Model, accountId uint32) database.EntityProvider[bool] {
+	return func(db *gorm.DB) model.Provider[bool] {
+		var count int64
+		err := db.Table("accounts").Where("tenant_id = ? AND id = ? AND deleted_at IS NULL", tenant.Id(), accountId).Count(&count).Error
+		if err != nil {
+			return model.ErrorProvider[bool](err)
+		}
+		return model.FixedProvider(count > 0)
+	}
+}
@@ -0,0 +1,116 @@
+package wallet
+
+import (
+	"atlas-account/rest"
+	"errors"
+	"github.com/Chronicle20/atlas-model/model"
+	"github.com/Chronicle20/atlas-rest/server"
+	"github.com/gorilla/mux"
+	"github.com/jtumidanski/api2go/jsonapi"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+func InitResource(si jsonapi.ServerInformation) func(db *gorm.DB) server.RouteInitializer {
+	return func(db *gorm.DB) server.RouteInitializer {
+		return func(router *mux.Router, l logrus.FieldLogger) {
+			register := rest.RegisterHandler(l)(db)(si)
+			registerInput := rest.RegisterInputHandler[TransactionRestModel](l)(db)(si)
+
+			r := router.PathPrefix("/accounts/{accountId}/wallet").Subrouter()
+			r.HandleFunc("", register("get_account_wallet", handleGetWallet)).Methods(http.MethodGet)
+			r.HandleFunc("/transactions", registerInput("adjust_account_wallet", handleAdjustWallet)).Methods(http.MethodPost)
+			r.HandleFunc("/transactions", register("get_account_wallet_transactions", handleGetWalletTransactions)).Methods(http.MethodGet)
+		}
+	}
+}
+
+func handleGetWallet(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			res, err := model.Map(Transform)(NewProcessor(d.Logger(), d.Context(), d.DB()).ByAccountIdProvider(accountId))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Unable to retrieve wallet for account [%d].", accountId)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+func handleAdjustWallet(d *rest.HandlerDependency, c *rest.HandlerContext, input TransactionRestModel) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if input.Amount == 0 || input.Amount > maximumBalance || input.Amount < -maximumBalance {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			p := NewProcessor(d.Logger(), d.Context(), d.DB())
+			var m Model
+			var err error
+			if input.Amount > 0 {
+				m, err = p.CreditAndEmit(accountId, input.Currency, uint32(input.Amount), input.Reason, input.TransactionId)
+			} else {
+				m, err = p.DebitAndEmit(accountId, input.Currency, uint32(-input.Amount), input.Reason, input.TransactionId)
+			}
+			if errors.Is(err, ErrInvalidAmount) || errors.Is(err, ErrInvalidCurrency) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, ErrInsufficientBalance) || errors.Is(err, ErrBalanceLimit) || errors.Is(err, ErrTransactionConflict) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if errors.Is(err, ErrAccountNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			res, err := model.Map(Transform)(model.FixedProvider(m))()
+			if err != nil {
+				d.Logger().WithError(err).Errorf("Creating REST model.")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			query := r.URL.Query()
+			queryParams := jsonapi.ParseQueryFields(&query)
+			server.MarshalResponse[RestModel](d.Logger())(w)(c.ServerInformation())(queryParams)(res)
+		}
+	})
+}
+
+func handleGetWalletTransactions(d *rest.HandlerDependency, c *rest.HandlerContext) http.HandlerFunc {
+	return rest.ParseAccountId(d.Logger(), func(accountId uint32) http.HandlerFunc {
+		return rest.ParsePage(d.Logger(), func(page rest.Page) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				p := NewProcessor(d.Logger(), d.Context(), d.DB())
+				total, err := p.CountTransactionsByAccountId(accountId)
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to count wallet transactions for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				res, err := model.SliceMap(TransformTransaction)(p.TransactionsByAccountIdProvider(accountId, page.Offset, page.Limit))(model.ParallelMap())()
+				if err != nil {
+					d.Logger().WithError(err).Errorf("Unable to retrieve wallet transactions for account [%d].", accountId)
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				rest.MarshalPage[[]TransactionRestModel](d.Logger())(w, r)(c.ServerInformation())(page, total)(res)
+			}
+		})
+	})
+}
@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"strconv"
+	"time"
+)
+
+type RestModel struct {
+	Id      uint32 `json:"-"`
+	Credit  uint32 `json:"credit"`
+	Prepaid uint32 `json:"prepaid"`
+	Points  uint32 `json:"points"`
+}
+
+func (r RestModel) GetName() string {
+	return "wallets"
+}
+
+func (r RestModel) GetID() string {
+	return strconv.Itoa(int(r.Id))
+}
+
+func (r *RestModel) SetID(idStr string) error {
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return err
+	}
+	r.Id = uint32(id)
+	return nil
+}
+
+func Transform(m Model) (RestModel, error) {
+	return RestModel{
+		Id:      m.accountId,
+		Credit:  m.credit,
+		Prepaid: m.prepaid,
+		Points:  m.points,
+	}, nil
+}
+
+// TransactionRestModel is a ledger entry. As input, a positive Amount credits the wallet and a negative one debits it.
+type TransactionRestModel struct {
+	Id            uint64    `json:"-"`
+	AccountId     uint32    `json:"accountId"`
+	Currency      string    `json:"currency"`
+	Amount        int64     `json:"amount"`
+	Balance       uint32    `json:"balance"`
+	Reason        string    `json:"reason"`
+	TransactionId string    `json:"transactionId"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (r TransactionRestModel) GetName() string {
+	return "wallet-transactions"
+}
+
+func (r TransactionRestModel) GetID() string {
+	return strconv.FormatUint(r.Id, 10)
+}
+
+func (r *TransactionRestModel) SetID(idStr string) error {
+	if idStr == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return err
+	}
+	r.Id = id
+	return nil
+}
+
+func TransformTransaction(t Transaction) (TransactionRestModel, error) {
+	return TransactionRestModel{
+		Id:            t.id,
+		AccountId:     t.accountId,
+		Currency:      t.currency,
+		Amount:        t.amount,
+		Balance:       t.balance,
+		Reason:        t.reason,
+		TransactionId: t.transactionId,
+		CreatedAt:     t.createdAt,
+	}, nil
+}